@@ -0,0 +1,308 @@
+package plist
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// A BinaryDocument is a binary plist which is decoded lazily. Opening it only
+// reads its trailer and offset table, and objects are decoded when they are
+// accessed, so that a few values can be read from a huge plist without
+// decoding all of it. Objects shared within an access are decoded once, and
+// all decoded objects are dropped when the next access begins, so that the
+// memory held by the document doesn't grow with the objects accessed.
+//
+// A BinaryDocument is not safe for concurrent use.
+type BinaryDocument struct {
+	parser *binaryParser
+	limits DecoderLimits
+}
+
+// OpenBinary opens the binary plist of the given size read from r.
+func OpenBinary(r io.ReaderAt, size int64) (*BinaryDocument, error) {
+	parser, err := newBinaryParserAt(r, size)
+	if err != nil {
+		return nil, err
+	}
+	parser.track = true
+	return &BinaryDocument{parser: parser}, nil
+}
+
+// SetLimits sets the limits on the resources spent on each access to the
+// document, like Decoder.SetLimits.
+func (doc *BinaryDocument) SetLimits(limits DecoderLimits) {
+	doc.limits = limits
+}
+
+// Root returns the root object of the document.
+func (doc *BinaryDocument) Root() BinaryObject {
+	return BinaryObject{doc: doc, index: doc.parser.RootObject}
+}
+
+// begin returns the parser of the document, with fresh limits for an access
+// and without the objects decoded by earlier ones.
+func (doc *BinaryDocument) begin() *binaryParser {
+	doc.parser.forget()
+	doc.parser.limits = newLimiter(doc.limits)
+	return doc.parser
+}
+
+// A BinaryObject is an object of a BinaryDocument. Looking up a key or index
+// only reads the collections on the way to it. An error in a lookup is kept
+// in the BinaryObject which is returned, so that lookups can be chained:
+//
+//	var product Product
+//	err := doc.Root().Key("Products").Index(3).Decode(&product)
+type BinaryObject struct {
+	doc   *BinaryDocument
+	index uint64 // index of the object in the object table
+	path  string // keys and indices leading to the object
+	err   error
+}
+
+// Err returns the error of the lookup of o, if any.
+func (o BinaryObject) Err() error {
+	return o.err
+}
+
+// Kind returns the kind of o, or Invalid if its lookup failed.
+func (o BinaryObject) Kind() Kind {
+	if o.err != nil {
+		return Invalid
+	}
+	marker, _, err := o.doc.begin().objectHeader(o.index)
+	if err != nil {
+		return Invalid
+	}
+	return binaryKind(marker)
+}
+
+// Len returns the number of elements of an array or set, or the number of
+// entries of a dictionary. It returns 0 for other kinds and failed lookups.
+func (o BinaryObject) Len() int {
+	if o.err != nil {
+		return 0
+	}
+	bp := o.doc.begin()
+	marker, refs, err := bp.objectHeader(o.index)
+	if err != nil {
+		return 0
+	}
+	n := len(refs) / int(bp.ObjectRefSize)
+	if binaryKind(marker) == Dictionary {
+		n /= 2
+	}
+	return n
+}
+
+// Keys returns the sorted keys of a dictionary.
+func (o BinaryObject) Keys() ([]string, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	bp := o.doc.begin()
+	keys, _, err := o.dictRefs(bp)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(keys))
+	for i, ref := range keys {
+		if names[i], err = bp.parseKey(ref); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Key returns the value for key of a dictionary. The lookup fails with
+// a *NotFoundError if the dictionary doesn't have the key.
+func (o BinaryObject) Key(key string) BinaryObject {
+	child := BinaryObject{doc: o.doc, path: formatPath([]string{o.path, key}), err: o.err}
+	if child.err != nil {
+		return child
+	}
+	bp := o.doc.begin()
+	keys, values, err := o.dictRefs(bp)
+	if err != nil {
+		child.err = err
+		return child
+	}
+	for i, ref := range keys {
+		k, err := bp.parseKey(ref)
+		if err != nil {
+			child.err = err
+			return child
+		}
+		if k == key {
+			child.index = values[i]
+			return child
+		}
+	}
+	child.err = &NotFoundError{child.path}
+	return child
+}
+
+// Index returns the i'th element of an array or set. The lookup fails with
+// a *NotFoundError if i is out of range.
+func (o BinaryObject) Index(i int) BinaryObject {
+	child := BinaryObject{doc: o.doc, path: formatPath([]string{o.path, indexPath(i)}), err: o.err}
+	if child.err != nil {
+		return child
+	}
+	bp := o.doc.begin()
+	marker, refs, err := bp.objectHeader(o.index)
+	switch kind := binaryKind(marker); {
+	case err != nil:
+		child.err = err
+	case kind != Array && kind != SetKind && kind != OrderedSetKind:
+		child.err = o.kindError(kind, "an array")
+	case i < 0 || i >= len(refs)/int(bp.ObjectRefSize):
+		child.err = &NotFoundError{child.path}
+	default:
+		size := int(bp.ObjectRefSize)
+		child.index = uintBE(refs[i*size : (i+1)*size])
+	}
+	return child
+}
+
+// Decode decodes o into the value pointed to by v, like Decoder.Decode.
+func (o BinaryObject) Decode(v interface{}) error {
+	if o.err != nil {
+		return o.err
+	}
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr {
+		return errors.New("plist: non-pointer passed to Decode")
+	}
+	pval, err := o.doc.begin().parseObjectRef(o.index)
+	if err != nil {
+		return err
+	}
+	d := &Decoder{format: BinaryFormat}
+	if o.path != "" {
+		d.path = []string{o.path}
+	}
+	return d.unmarshal(pval, val.Elem())
+}
+
+// dictRefs returns the refs of the keys and values of a dictionary.
+func (o BinaryObject) dictRefs(bp *binaryParser) (keys, values []uint64, err error) {
+	marker, refs, err := bp.objectHeader(o.index)
+	if err != nil {
+		return nil, nil, err
+	}
+	if kind := binaryKind(marker); kind != Dictionary {
+		return nil, nil, o.kindError(kind, "a dictionary")
+	}
+	size := int(bp.ObjectRefSize)
+	n := len(refs) / size / 2
+	keys, values = make([]uint64, n), make([]uint64, n)
+	for i := 0; i < n; i++ {
+		keys[i] = uintBE(refs[i*size : (i+1)*size])
+		values[i] = uintBE(refs[(n+i)*size : (n+i+1)*size])
+	}
+	return keys, values, nil
+}
+
+func (o BinaryObject) kindError(kind Kind, want string) error {
+	name := o.path
+	if name == "" {
+		name = "the root object"
+	}
+	return fmt.Errorf("plist: %s is a %v, not %s", name, kind, want)
+}
+
+// A NotFoundError is returned by the lookups of BinaryObject when a key or
+// index doesn't exist.
+type NotFoundError struct {
+	Path string // keys and indices of the missing value, as in "Items[2].Name"
+}
+
+func (e *NotFoundError) Error() string {
+	return "plist: " + e.Path + " not found"
+}
+
+// objectHeader reads the marker byte of an object, and the object refs of
+// arrays, sets and dictionaries, without decoding any other object.
+func (bp *binaryParser) objectHeader(index uint64) (byte, []byte, error) {
+	if index >= uint64(len(bp.OffsetTable)) {
+		return 0, nil, bp.formatError(-1, ErrBadOffset, "object ref %d out of range (%d objects)", index, len(bp.OffsetTable))
+	}
+	bp.frames = append(bp.frames, binaryFrame{object: index, marker: -1})
+	defer func() { bp.frames = bp.frames[:len(bp.frames)-1] }()
+
+	off := bp.OffsetTable[index]
+	b, err := bp.slice(off, 1, 1)
+	if err != nil {
+		return 0, nil, err
+	}
+	marker := b[0]
+	bp.frames[len(bp.frames)-1].marker = int(marker)
+	perElem := uint64(1)
+	switch binaryKind(marker) {
+	case Array, SetKind, OrderedSetKind:
+	case Dictionary:
+		perElem = 2
+	default:
+		return marker, nil, nil
+	}
+	count, off, err := bp.readCount(marker, off+1)
+	if err != nil {
+		return 0, nil, err
+	}
+	refs, err := bp.readObjectRefs(off, count, perElem)
+	if err != nil {
+		return 0, nil, err
+	}
+	return marker, refs, nil
+}
+
+// parseKey decodes the dictionary key with the given ref.
+func (bp *binaryParser) parseKey(ref uint64) (string, error) {
+	pval, err := bp.parseObjectRef(ref)
+	if err != nil {
+		return "", err
+	}
+	if pval.kind != String {
+		return "", bp.formatError(int64(bp.OffsetTable[ref]), ErrBadKey, "dictionary key is a %v, not a string", pval.kind)
+	}
+	return pval.value.(string), nil
+}
+
+// binaryKind returns the kind of the objects with the given marker byte.
+func binaryKind(marker byte) Kind {
+	switch marker >> 4 {
+	case 0x0:
+		switch marker & 0xf {
+		case 0x0:
+			return NullKind
+		case 0x8, 0x9:
+			return Boolean
+		}
+	case 0x1:
+		return Integer
+	case 0x2:
+		return Real
+	case 0x3:
+		return Date
+	case 0x4:
+		return Data
+	case 0x5, 0x6:
+		return String
+	case 0x8:
+		return UIDKind
+	case 0xa:
+		return Array
+	case 0xb:
+		return OrderedSetKind
+	case 0xc:
+		return SetKind
+	case 0xd:
+		return Dictionary
+	}
+	return Invalid
+}
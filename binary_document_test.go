@@ -0,0 +1,147 @@
+package plist
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// countingReaderAt counts the bytes read from a reader.
+type countingReaderAt struct {
+	r *bytes.Reader
+	n int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.n += n
+	return n, err
+}
+
+func TestBinaryDocument(t *testing.T) {
+	type product struct {
+		Name  string
+		Price int
+		Blob  []byte
+	}
+	type catalog struct {
+		Version  int
+		Products []product
+	}
+	in := catalog{Version: 2}
+	for i := 0; i < 10; i++ {
+		in.Products = append(in.Products, product{
+			Name:  string(rune('a' + i)),
+			Price: i * 100,
+			Blob:  bytes.Repeat([]byte{byte(i)}, 1000),
+		})
+	}
+	data, err := MarshalBinary(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &countingReaderAt{r: bytes.NewReader(data)}
+	doc, err := OpenBinary(r, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := doc.Root()
+	if kind := root.Kind(); kind != Dictionary {
+		t.Errorf("root kind = %v, want Dictionary", kind)
+	}
+	keys, err := root.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Products", "Version"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+	products := root.Key("Products")
+	if n := products.Len(); n != 10 {
+		t.Errorf("len = %d, want 10", n)
+	}
+
+	var p product
+	if err := products.Index(3).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, in.Products[3]) {
+		t.Errorf("product = %+v, want %+v", p, in.Products[3])
+	}
+	if r.n > len(data)/2 {
+		t.Errorf("read %d of %d bytes, want only the objects on the way", r.n, len(data))
+	}
+
+	var name string
+	if err := root.Key("Products").Index(7).Key("Name").Decode(&name); err != nil || name != "h" {
+		t.Errorf("name = %q, %v, want \"h\"", name, err)
+	}
+
+	var nf *NotFoundError
+	err = root.Key("Products").Index(10).Key("Name").Decode(&name)
+	if !errors.As(err, &nf) || nf.Path != "Products[10]" {
+		t.Errorf("out of range error = %v, want Products[10] not found", err)
+	}
+	err = root.Key("Missing").Index(0).Decode(&name)
+	if !errors.As(err, &nf) || nf.Path != "Missing" {
+		t.Errorf("missing key error = %v, want Missing not found", err)
+	}
+	if err := root.Key("Version").Index(0).Err(); err == nil {
+		t.Error("Index of an integer succeeded")
+	}
+
+	var price string
+	err = root.Key("Products").Index(2).Key("Price").Decode(&price)
+	var typeErr UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Path != "Products[2].Price" {
+		t.Errorf("type error = %v, want one at Products[2].Price", err)
+	}
+}
+
+// TestBinaryDocumentForgets checks that a document doesn't keep the objects
+// decoded by earlier accesses.
+func TestBinaryDocumentForgets(t *testing.T) {
+	in := make([]map[string]string, 100)
+	for i := range in {
+		in[i] = map[string]string{"Name": string(rune('a' + i%26)), "Index": indexPath(i)}
+	}
+	data, err := MarshalBinary(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := OpenBinary(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := func() int {
+		n := 0
+		for _, obj := range doc.parser.objects {
+			if obj.pval != nil {
+				n++
+			}
+		}
+		return n
+	}
+
+	var all []map[string]string
+	if err := doc.Root().Decode(&all); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(all, in) {
+		t.Errorf("decoded %v, want %v", all, in)
+	}
+	if n := decoded(); n < len(in) {
+		t.Fatalf("%d objects decoded after decoding the root, want at least %d", n, len(in))
+	}
+
+	var name string
+	if err := doc.Root().Index(42).Key("Name").Decode(&name); err != nil || name != "q" {
+		t.Errorf("name = %q, %v, want \"q\"", name, err)
+	}
+	// The last access decoded the key and value it looked up, and the name.
+	if n := decoded(); n > 3 {
+		t.Errorf("%d objects kept after looking up a name, want at most 3", n)
+	}
+}
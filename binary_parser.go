@@ -1,10 +1,11 @@
 package plist
 
 import (
-	"bytes"
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"strings"
 	"time"
 	"unicode/utf16"
 )
@@ -22,138 +23,253 @@ type plistTrailer struct {
 	OffsetTableOffset uint64  // offset of the offset table
 }
 
+// binaryParser decodes a binary plist. It slices the plist directly when it
+// is in memory, and reads only the parts it needs with ReadAt otherwise.
+// Every object is decoded once, however often it is referenced, and all
+// references share the decoded value.
 type binaryParser struct {
-	OffsetTable   []uint64 // array of offsets for each object in plist
-	plistTrailer           // last 32 bytes of plist
-	io.ReadSeeker          // reader for plist data
+	OffsetTable  []uint64 // array of offsets for each object in plist
+	plistTrailer          // last 32 bytes of plist
+
+	data []byte      // the plist, if it is in memory
+	r    io.ReaderAt // the plist, if data is nil
+	size int64       // length of the plist
+
+	objects []parsedObject // decoded objects, by index
+	decoded []uint64       // indices of the decoded objects, if tracked
+	track   bool           // true if decoded is kept, see forget
+	frames  []binaryFrame  // objects being decoded, outermost first
+	strict  bool           // true if duplicate dictionary keys are rejected
+	limits  *limiter
 }
 
-const numObjectsMax = 4 << 20
+// parsedObject is an object of the object table once it has been decoded.
+// It records what the object counted against the limits, so that further
+// references to it count the same.
+type parsedObject struct {
+	state  uint8 // objectUnparsed, objectParsing or objectParsed
+	pval   *plistValue
+	values int   // number of values in the object, itself included
+	bytes  int64 // length of the strings, keys and data in the object
+	height int   // levels of nesting in the object, 1 for scalars
+}
 
-// newBinaryParser takes in a ReadSeeker for the bytes of a binary plist and
-// returns a parser after reading the offset table and trailer.
-func newBinaryParser(r io.ReadSeeker) (*binaryParser, error) {
-	var bp binaryParser
-	bp.ReadSeeker = r
+const (
+	objectUnparsed = iota
+	objectParsing
+	objectParsed
+)
 
-	// Read the trailer.
-	if _, err := bp.Seek(-32, io.SeekEnd); err != nil {
-		return nil, fmt.Errorf("plist: couldn't seek to start of trailer: %v", err)
+// binaryFrame is an object being decoded, for error reporting.
+type binaryFrame struct {
+	object uint64
+	marker int // -1 until the marker byte is read
+}
+
+// newBinaryParser returns a parser for the binary plist data, after reading
+// its offset table and trailer. Objects are sliced from data without copying.
+func newBinaryParser(data []byte) (*binaryParser, error) {
+	bp := &binaryParser{data: data, size: int64(len(data))}
+	if err := bp.readTables(); err != nil {
+		return nil, err
 	}
-	if err := binary.Read(bp, binary.BigEndian, &bp.plistTrailer); err != nil {
-		return nil, fmt.Errorf("plist: couldn't read trailer: %v", err)
+	return bp, nil
+}
+
+// newBinaryParserAt returns a parser for the binary plist of the given size
+// read from r, after reading its offset table and trailer.
+func newBinaryParserAt(r io.ReaderAt, size int64) (*binaryParser, error) {
+	bp := &binaryParser{r: r, size: size}
+	if err := bp.readTables(); err != nil {
+		return nil, err
 	}
+	return bp, nil
+}
 
-	// Read the offset table.
-	if _, err := bp.Seek(int64(bp.OffsetTableOffset), io.SeekStart); err != nil {
-		return nil, fmt.Errorf("plist: couldn't seek to start of offset table: %v", err)
+// readTables reads and checks the trailer and the offset table.
+func (bp *binaryParser) readTables() error {
+	bp.limits = newLimiter(DecoderLimits{})
+
+	// The header, at least one object and the trailer.
+	if bp.size < int64(len("bplist00"))+1+32 {
+		return bp.formatError(0, ErrTruncated, "%d bytes is too short for a binary plist", bp.size)
 	}
 
-	// numObjectsMax is arbitrary. Please fix.
-	// TODO(github.com/groob/plist/issues/28)
-	if bp.NumObjects > numObjectsMax {
-		return nil, fmt.Errorf("plist: offset size larger than expected %d", numObjectsMax)
+	// Read the trailer.
+	trailerOffset := bp.size - 32
+	trailer, err := bp.readAt(trailerOffset, 32)
+	if err != nil {
+		return err
+	}
+	bp.SortVersion = trailer[5]
+	bp.OffsetIntSize = trailer[6]
+	bp.ObjectRefSize = trailer[7]
+	bp.NumObjects = uintBE(trailer[8:16])
+	bp.RootObject = uintBE(trailer[16:24])
+	bp.OffsetTableOffset = uintBE(trailer[24:32])
+	switch {
+	case bp.OffsetIntSize == 0 || bp.OffsetIntSize > 8:
+		return bp.formatError(trailerOffset, ErrBadTrailer, "invalid offset int size %d", bp.OffsetIntSize)
+	case bp.ObjectRefSize == 0 || bp.ObjectRefSize > 8:
+		return bp.formatError(trailerOffset, ErrBadTrailer, "invalid object ref size %d", bp.ObjectRefSize)
+	case bp.NumObjects == 0:
+		return bp.formatError(trailerOffset, ErrBadTrailer, "no objects")
+	case bp.RootObject >= bp.NumObjects:
+		return bp.formatError(trailerOffset, ErrBadTrailer, "root object %d out of range (%d objects)", bp.RootObject, bp.NumObjects)
+	case bp.OffsetTableOffset < 9 || bp.OffsetTableOffset > uint64(trailerOffset) ||
+		bp.NumObjects > (uint64(trailerOffset)-bp.OffsetTableOffset)/uint64(bp.OffsetIntSize):
+		return bp.formatError(trailerOffset, ErrBadTrailer, "offset table at %d doesn't fit before the trailer", bp.OffsetTableOffset)
 	}
 
-	bp.OffsetTable = make([]uint64, bp.NumObjects)
-	if bp.OffsetIntSize > 8 {
-		return nil, fmt.Errorf("plist: can't decode when offset int size (%d) is greater than 8", bp.OffsetIntSize)
+	// Read the offset table.
+	size := uint64(bp.OffsetIntSize)
+	table, err := bp.readAt(int64(bp.OffsetTableOffset), int64(bp.NumObjects*size))
+	if err != nil {
+		return err
 	}
-	for i := uint64(0); i < bp.NumObjects; i++ {
-		buf := make([]byte, 8)
-		if _, err := bp.Read(buf[8-bp.OffsetIntSize:]); err != nil {
-			return nil, fmt.Errorf("plist: couldn't read offset table: %v", err)
+	bp.OffsetTable = make([]uint64, bp.NumObjects)
+	for i := range bp.OffsetTable {
+		offset := uintBE(table[uint64(i)*size : uint64(i+1)*size])
+		// Objects lie between the header and the offset table.
+		if offset < 8 || offset >= bp.OffsetTableOffset {
+			e := bp.formatError(int64(bp.OffsetTableOffset), ErrBadOffset, "object %d has offset %d outside of the object table", i, offset)
+			e.Object = int64(i)
+			return e
 		}
-		bp.OffsetTable[i] = uint64(binary.BigEndian.Uint64(buf))
+		bp.OffsetTable[i] = offset
 	}
-
-	return &bp, nil
+	bp.objects = make([]parsedObject, bp.NumObjects)
+	return nil
 }
 
 // parseDocument parses the entire binary plist starting from the root object
 // and returns a plistValue representing the root object.
 func (bp *binaryParser) parseDocument() (*plistValue, error) {
+	if bp.NumObjects > uint64(bp.limits.MaxObjects) {
+		err := &LimitError{"MaxObjects", int64(bp.limits.MaxObjects)}
+		return nil, bp.formatError(bp.size-32, err, "%d objects: %s", bp.NumObjects, limitMsg(err))
+	}
 	// Decode and return the root object.
 	return bp.parseObjectRef(bp.RootObject)
 }
 
 // parseObjectRef decodes and returns the plist object with the given index.
 // Index 0 is the first object in the object table, 1 is the second, etc.
-// This function restores the current plist offset when it's done so that you
-// may call it while decoding a collection object without losing your place.
-func (bp *binaryParser) parseObjectRef(index uint64) (val *plistValue, err error) {
-	// Save the current offset.
-	offset, err := bp.Seek(0, io.SeekCurrent)
+// Objects which were decoded before are returned as they are.
+func (bp *binaryParser) parseObjectRef(index uint64) (*plistValue, error) {
+	if index >= uint64(len(bp.OffsetTable)) {
+		return nil, bp.objectError(ErrBadOffset, "object ref %d out of range (%d objects)", index, len(bp.OffsetTable))
+	}
+	obj := &bp.objects[index]
+	switch obj.state {
+	case objectParsed:
+		if err := bp.limits.reuse(obj.values, obj.bytes, obj.height); err != nil {
+			return nil, bp.limitError(err)
+		}
+		return obj.pval, nil
+	case objectParsing:
+		// Objects may be shared, but no object may contain itself.
+		return nil, bp.objectError(ErrCycle, "refers to object %d, which contains it", index)
+	}
+
+	obj.state = objectParsing
+	bp.frames = append(bp.frames, binaryFrame{object: index, marker: -1})
+	l := bp.limits
+	objects, total, depth, deepest := l.objects, l.total, l.depth, l.deepest
+	l.deepest = depth
+	pval, err := bp.parseObject(bp.OffsetTable[index])
+	bp.frames = bp.frames[:len(bp.frames)-1]
 	if err != nil {
+		// BinaryDocument may parse the object again.
+		obj.state = objectUnparsed
 		return nil, err
 	}
-	// Then restore the original offset in a defer.
-	defer func() {
-		_, err2 := bp.Seek(offset, io.SeekStart)
-		if err2 != nil {
-			err = err2
-		}
-	}()
+	if bp.track {
+		bp.decoded = append(bp.decoded, index)
+	}
+	*obj = parsedObject{
+		state:  objectParsed,
+		pval:   pval,
+		values: l.objects - objects,
+		bytes:  l.total - total,
+		height: l.deepest - depth,
+	}
+	if deepest > l.deepest {
+		l.deepest = deepest
+	}
+	return pval, nil
+}
 
-	if index > uint64(len(bp.OffsetTable)) {
-		return nil, fmt.Errorf("plist: offset too large: %d", index)
+// forget drops the objects decoded since the last call, so that their values
+// can be garbage collected. The parser must track them.
+func (bp *binaryParser) forget() {
+	for _, index := range bp.decoded {
+		bp.objects[index] = parsedObject{}
 	}
-	// Move to the start of the object we want to decode.
-	if _, err := bp.Seek(int64(bp.OffsetTable[index]), io.SeekStart); err != nil {
-		return nil, err
+	bp.decoded = bp.decoded[:0]
+}
+
+// parseObject decodes the object at offset off.
+func (bp *binaryParser) parseObject(off uint64) (*plistValue, error) {
+	if err := bp.limits.enter(); err != nil {
+		return nil, bp.limitError(err)
 	}
+	defer bp.limits.leave()
+
 	// The first byte of the object is its marker byte.
 	// High 4 bits of marker byte indicates the object type.
 	// Low 4 bits contain additional info, typically a count.
 	// Defined here: https://opensource.apple.com/source/CF/CF-550.29/CFBinaryPList.c
-	// (using Read instead of ReadByte so that we can accept a ReadSeeker)
-	b := make([]byte, 1)
-	if _, err := bp.Read(b); err != nil {
+	b, err := bp.slice(off, 1, 1)
+	if err != nil {
 		return nil, err
 	}
 	marker := b[0]
+	bp.frames[len(bp.frames)-1].marker = int(marker)
+	off++
 	switch marker >> 4 {
 	case 0x0: // null, bool, or fill
 		return bp.parseSingleton(marker)
 	case 0x1: // integer
-		return bp.parseInteger(marker)
+		return bp.parseInteger(marker, off)
 	case 0x2: // real
-		return bp.parseReal(marker)
+		return bp.parseReal(marker, off)
 	case 0x3: // date
-		return bp.parseDate(marker)
+		return bp.parseDate(marker, off)
 	case 0x4: // data
-		return bp.parseData(marker)
+		return bp.parseData(marker, off)
 	case 0x5: // ascii string
-		return bp.parseASCII(marker)
+		return bp.parseASCII(marker, off)
 	case 0x6: // unicode (utf-16) string
-		return bp.parseUTF16(marker)
-	case 0x8: // uid (not supported)
-		return &plistValue{Invalid, nil}, nil
+		return bp.parseUTF16(marker, off)
+	case 0x8: // uid
+		return bp.parseUID(marker, off)
 	case 0xa: // array
-		return bp.parseArray(marker)
-	case 0xc: // set (not supported)
-		return &plistValue{Invalid, nil}, nil
+		return bp.parseArray(marker, off, Array)
+	case 0xb: // ordered set
+		return bp.parseArray(marker, off, OrderedSetKind)
+	case 0xc: // set
+		return bp.parseArray(marker, off, SetKind)
 	case 0xd: // dictionary
-		return bp.parseDict(marker)
+		return bp.parseDict(marker, off)
 	}
-	return nil, fmt.Errorf("plist: unknown object type %x", marker>>4)
+	return nil, bp.objectError(ErrBadMarker, "unknown object type %x", marker>>4)
 }
 
 func (bp *binaryParser) parseSingleton(marker byte) (*plistValue, error) {
 	switch marker & 0xf {
-	case 0x0: // null (not supported)
-		return &plistValue{Invalid, nil}, nil
+	case 0x0: // null
+		return &plistValue{NullKind, nil}, nil
 	case 0x8: // bool false
 		return &plistValue{Boolean, false}, nil
 	case 0x9: // bool true
 		return &plistValue{Boolean, true}, nil
-	case 0xf: // fill (not supported)
-		return &plistValue{Invalid, nil}, nil
 	}
-	return nil, fmt.Errorf("plist: unrecognized singleton type %x", marker&0xf)
+	// 0xf is a fill byte, which isn't an object.
+	return nil, bp.objectError(ErrBadMarker, "unrecognized singleton type %x", marker&0xf)
 }
 
-func (bp *binaryParser) parseInteger(marker byte) (*plistValue, error) {
+func (bp *binaryParser) parseInteger(marker byte, off uint64) (*plistValue, error) {
 	// Integers are always stored as signed 64-bit integer, with leading zeros
 	// removed, so that the serialized form is either 1, 2, 4, or 8 bytes in
 	// length.
@@ -229,49 +345,66 @@ func (bp *binaryParser) parseInteger(marker byte) (*plistValue, error) {
 	// xmlParser.parseInteger implementation.
 	//
 	// See: https://bugs.python.org/issue14455
-	nbytes := 1 << (marker & 0xf)
+	nbytes := uint64(1) << (marker & 0xf)
 	if nbytes > 16 {
-		return nil, fmt.Errorf("plist: cannot decode integers longer than 16 bytes (%d)", nbytes)
+		return nil, bp.objectError(ErrBadMarker, "cannot decode integers longer than 16 bytes (%d)", nbytes)
 	}
-	// Read into the right-most bytes of a 16-byte zero-valued buffer.
-	buf := make([]byte, 16)
-	_, err := bp.Read(buf[16-nbytes:])
+	buf, err := bp.slice(off, nbytes, 1)
 	if err != nil {
 		return nil, err
 	}
 	// Truncate values to 64 bits (8 bytes), and treat them all as "unsigned",
 	// so they can be unmarshaled to unsigned and signed integers alike as
 	// discussed above.
-	result := signedInt{binary.BigEndian.Uint64(buf[8:]), false}
+	if nbytes == 16 {
+		buf = buf[8:]
+	}
+	result := signedInt{uintBE(buf), false}
 
 	return &plistValue{Integer, result}, nil
 }
 
-func (bp *binaryParser) parseReal(marker byte) (*plistValue, error) {
-	nbytes := 1 << (marker & 0xf)
-	buf := make([]byte, nbytes)
-	if _, err := bp.Read(buf); err != nil {
-		return nil, err
+func (bp *binaryParser) parseUID(marker byte, off uint64) (*plistValue, error) {
+	// The low 4 bits of the marker are the length of the UID minus one.
+	nbytes := uint64(marker&0xf) + 1
+	if nbytes > 8 {
+		return nil, bp.objectError(ErrBadMarker, "cannot decode UIDs longer than 8 bytes (%d)", nbytes)
 	}
-	var r float64
-	if err := binary.Read(bytes.NewReader(buf), binary.BigEndian, &r); err != nil {
+	buf, err := bp.slice(off, nbytes, 1)
+	if err != nil {
 		return nil, err
 	}
-	return &plistValue{Real, sizedFloat{r, nbytes * 8}}, nil
+	return &plistValue{UIDKind, UID(uintBE(buf))}, nil
 }
 
-func (bp *binaryParser) parseDate(marker byte) (*plistValue, error) {
-	if marker&0xf != 0x3 {
-		return nil, fmt.Errorf("plist: invalid marker byte for date: %x", marker)
+func (bp *binaryParser) parseReal(marker byte, off uint64) (*plistValue, error) {
+	nbytes := uint64(1) << (marker & 0xf)
+	if nbytes != 4 && nbytes != 8 {
+		return nil, bp.objectError(ErrBadMarker, "cannot decode real of %d bytes", nbytes)
 	}
-	buf := make([]byte, 8)
-	if _, err := bp.Read(buf); err != nil {
+	buf, err := bp.slice(off, nbytes, 1)
+	if err != nil {
 		return nil, err
 	}
-	var t float64
-	if err := binary.Read(bytes.NewReader(buf), binary.BigEndian, &t); err != nil {
+	var r float64
+	switch nbytes {
+	case 4:
+		r = float64(math.Float32frombits(uint32(uintBE(buf))))
+	case 8:
+		r = math.Float64frombits(uintBE(buf))
+	}
+	return &plistValue{Real, sizedFloat{r, int(nbytes) * 8}}, nil
+}
+
+func (bp *binaryParser) parseDate(marker byte, off uint64) (*plistValue, error) {
+	if marker&0xf != 0x3 {
+		return nil, bp.objectError(ErrBadMarker, "invalid marker byte for date: %x", marker)
+	}
+	buf, err := bp.slice(off, 8, 1)
+	if err != nil {
 		return nil, err
 	}
+	t := math.Float64frombits(uintBE(buf))
 	// The float time is Apple Epoch time (secs since Jan 1, 2001 GMT) but we
 	// need to convert it to Unix Epoch time (secs since Jan 1, 1970 GMT)
 	t += 978307200
@@ -280,99 +413,129 @@ func (bp *binaryParser) parseDate(marker byte) (*plistValue, error) {
 	return &plistValue{Date, time.Unix(secs, nsecs)}, nil
 }
 
-func (bp *binaryParser) parseData(marker byte) (*plistValue, error) {
-	count, err := bp.readCount(marker)
+// parseBytes returns the count bytes at off of a data value or string.
+func (bp *binaryParser) parseBytes(off, count uint64) ([]byte, error) {
+	if err := bp.checkCount(off, count, 1); err != nil {
+		return nil, err
+	}
+	if err := bp.limits.bytes(count); err != nil {
+		return nil, bp.limitError(err)
+	}
+	return bp.slice(off, count, 1)
+}
+
+func (bp *binaryParser) parseData(marker byte, off uint64) (*plistValue, error) {
+	count, off, err := bp.readCount(marker, off)
 	if err != nil {
 		return nil, err
 	}
-	buf := make([]byte, count)
-	if _, err := bp.Read(buf); err != nil {
+	// The data shares memory with the plist. Decoder.dataBytes copies it
+	// before handing it out.
+	data, err := bp.parseBytes(off, count)
+	if err != nil {
 		return nil, err
 	}
-	return &plistValue{Data, buf}, nil
+	return &plistValue{Data, data}, nil
 }
 
-func (bp *binaryParser) parseASCII(marker byte) (*plistValue, error) {
-	count, err := bp.readCount(marker)
+func (bp *binaryParser) parseASCII(marker byte, off uint64) (*plistValue, error) {
+	count, off, err := bp.readCount(marker, off)
 	if err != nil {
 		return nil, err
 	}
-	buf := make([]byte, count)
-	if _, err := bp.Read(buf); err != nil {
+	buf, err := bp.parseBytes(off, count)
+	if err != nil {
 		return nil, err
 	}
 	return &plistValue{String, string(buf)}, nil
 }
 
-func (bp *binaryParser) parseUTF16(marker byte) (*plistValue, error) {
-	count, err := bp.readCount(marker)
+func (bp *binaryParser) parseUTF16(marker byte, off uint64) (*plistValue, error) {
+	count, off, err := bp.readCount(marker, off)
 	if err != nil {
 		return nil, err
 	}
-	// Each character in the UTF16 string is 2 bytes.  First we read everything
-	// into a byte slice, then convert this into a slice of uint16, then this
-	// gets converted into a slice of rune, which gets converted to a string.
-	buf := make([]byte, 2*count)
-	if _, err := bp.Read(buf); err != nil {
+	// Each character in the UTF16 string is 2 bytes.
+	if err := bp.checkCount(off, count, 2); err != nil {
 		return nil, err
 	}
-	uni := make([]uint16, count)
-	if err := binary.Read(bytes.NewReader(buf), binary.BigEndian, uni); err != nil {
+	buf, err := bp.parseBytes(off, 2*count)
+	if err != nil {
 		return nil, err
 	}
+	uni := make([]uint16, count)
+	for i := range uni {
+		uni[i] = uint16(buf[2*i])<<8 | uint16(buf[2*i+1])
+	}
 	return &plistValue{String, string(utf16.Decode(uni))}, nil
 }
 
-func (bp *binaryParser) parseArray(marker byte) (*plistValue, error) {
-	count, err := bp.readCount(marker)
+// parseArray parses an array, or a set of the given kind, which is stored the
+// same way.
+func (bp *binaryParser) parseArray(marker byte, off uint64, kind Kind) (*plistValue, error) {
+	count, off, err := bp.readCount(marker, off)
 	if err != nil {
 		return nil, err
 	}
 	// A list of count object refs representing the items in the array follow.
-	list, err := bp.readObjectList(count)
+	refs, err := bp.readObjectRefs(off, count, 1)
+	if err != nil {
+		return nil, err
+	}
+	list, err := bp.parseObjectList(refs)
 	if err != nil {
 		return nil, err
 	}
-	return &plistValue{Array, list}, nil
+	return &plistValue{kind, list}, nil
 }
 
-func (bp *binaryParser) parseDict(marker byte) (*plistValue, error) {
-	count, err := bp.readCount(marker)
+func (bp *binaryParser) parseDict(marker byte, off uint64) (*plistValue, error) {
+	count, off, err := bp.readCount(marker, off)
 	if err != nil {
 		return nil, err
 	}
 	// A list of 2*count object refs follow.  All of the keys are listed first,
 	// followed by all of the values.
-	keys, err := bp.readObjectList(count)
+	refs, err := bp.readObjectRefs(off, count, 2)
+	if err != nil {
+		return nil, err
+	}
+	half := count * uint64(bp.ObjectRefSize)
+	keys, err := bp.parseObjectList(refs[:half])
 	if err != nil {
 		return nil, err
 	}
-	vals, err := bp.readObjectList(count)
+	vals, err := bp.parseObjectList(refs[half:])
 	if err != nil {
 		return nil, err
 	}
-	m := make(map[string]*plistValue)
+	dict := &dictionary{
+		m:     make(map[string]*plistValue, count),
+		order: make([]string, 0, count),
+	}
 	for i := uint64(0); i < count; i++ {
 		if keys[i].kind != String {
-			return nil, fmt.Errorf("plist: dictionary key is not a string: %v", keys[i])
+			return nil, bp.objectError(ErrBadKey, "dictionary key is a %v, not a string", keys[i].kind)
+		}
+		if _, ok := dict.m[keys[i].value.(string)]; ok && bp.strict {
+			return nil, bp.objectError(nil, "duplicate key %q", keys[i].value.(string))
 		}
-		m[keys[i].value.(string)] = vals[i]
+		dict.set(keys[i].value.(string), vals[i])
 	}
-	return &plistValue{Dictionary, &dictionary{m: m}}, nil
+	return &plistValue{Dictionary, dict}, nil
 }
 
-// readCount reads the variable-length encoded integer count
-// used by data, strings, arrays, and dicts
-func (bp *binaryParser) readCount(marker byte) (uint64, error) {
+// readCount reads the variable-length encoded integer count used by data,
+// strings, arrays, and dicts, and returns it with the offset which follows it.
+func (bp *binaryParser) readCount(marker byte, off uint64) (uint64, uint64, error) {
 	// Check marker for count < 15 in lower 4 bits.
 	if marker&0xf != 0xf {
-		return uint64(marker & 0xf), nil
+		return uint64(marker & 0xf), off, nil
 	}
-	// Otherwise must read additional bytes to get count.  Read first byte:
-	// (using Read instead of ReadByte so that we can accept a ReadSeeker)
-	b := make([]byte, 1)
-	if _, err := bp.Read(b); err != nil {
-		return 0, err
+	// Otherwise must read additional bytes to get count.
+	b, err := bp.slice(off, 1, 1)
+	if err != nil {
+		return 0, 0, err
 	}
 	first := b[0]
 	// The lower 4 bits of indicate how many additional bytes to read:
@@ -380,42 +543,39 @@ func (bp *binaryParser) readCount(marker byte) (uint64, error) {
 	//   1 means 2 additional bytes
 	//   2 means 4 additional bytes
 	//   3 means 8 additional bytes
-	nbytes := 1 << (first & 0x0f)
+	nbytes := uint64(1) << (first & 0x0f)
 	// Number of bytes in count should be at most 8.
 	if nbytes > 8 {
-		return 0, fmt.Errorf("plist: invalid nbytes (%d) in readCount", nbytes)
+		return 0, 0, bp.objectError(ErrBadMarker, "invalid nbytes (%d) in readCount", nbytes)
 	}
-	buf := make([]byte, 8)
-	// Shove these bytes into the low end of an 8-byte buffer.
-	if _, err := bp.Read(buf[8-nbytes:]); err != nil {
-		return 0, err
+	buf, err := bp.slice(off+1, nbytes, 1)
+	if err != nil {
+		return 0, 0, err
 	}
-	return binary.BigEndian.Uint64(buf), nil
+	return uintBE(buf), off + 1 + nbytes, nil
 }
 
-// readObjectRef reads the next ObjectRefSize bytes from the binary plist
-// and returns the bytes decoded into an integer value.
-func (bp *binaryParser) readObjectRef() (uint64, error) {
-	buf := make([]byte, 8)
-	if _, err := bp.Read(buf[8-bp.ObjectRefSize:]); err != nil {
-		return 0, err
+// readObjectRefs returns the object refs of a collection with count elements
+// at off, which has perElem refs for each of its elements.
+func (bp *binaryParser) readObjectRefs(off, count, perElem uint64) ([]byte, error) {
+	if err := bp.checkCount(off, count, perElem*uint64(bp.ObjectRefSize)); err != nil {
+		return nil, err
 	}
-	return binary.BigEndian.Uint64(buf), nil
+	if err := bp.limits.collection(count); err != nil {
+		return nil, bp.limitError(err)
+	}
+	return bp.slice(off, perElem*count, uint64(bp.ObjectRefSize))
 }
 
-// readObjectList is a helper function for parseArray and parseDict.
-// It decodes a sequence of object refs from the current offset in the plist
-// and returns the decoded objects in a slice.
-func (bp *binaryParser) readObjectList(count uint64) ([]*plistValue, error) {
-	list := make([]*plistValue, count)
-	for i := uint64(0); i < count; i++ {
-		// Read index of object in offset table.
-		ref, err := bp.readObjectRef()
-		if err != nil {
-			return nil, err
-		}
+// parseObjectList is a helper function for parseArray and parseDict.
+// It decodes the objects of a sequence of object refs and returns them in a
+// slice.
+func (bp *binaryParser) parseObjectList(refs []byte) ([]*plistValue, error) {
+	size := int(bp.ObjectRefSize)
+	list := make([]*plistValue, len(refs)/size)
+	for i := range list {
 		// Find and decode the object in object table, then add it to list.
-		v, err := bp.parseObjectRef(ref)
+		v, err := bp.parseObjectRef(uintBE(refs[i*size : (i+1)*size]))
 		if err != nil {
 			return nil, err
 		}
@@ -423,3 +583,130 @@ func (bp *binaryParser) readObjectList(count uint64) ([]*plistValue, error) {
 	}
 	return list, nil
 }
+
+// slice returns count items of size bytes at off, which must lie before the
+// offset table. The slice shares memory with the plist if it is in memory.
+func (bp *binaryParser) slice(off, count, size uint64) ([]byte, error) {
+	if err := bp.checkCount(off, count, size); err != nil {
+		return nil, err
+	}
+	return bp.readAt(int64(off), int64(count*size))
+}
+
+// checkCount returns ErrTruncated if count items of the given size at off
+// don't fit before the offset table, before allocating room for them.
+func (bp *binaryParser) checkCount(off, count, size uint64) error {
+	// Objects end where the offset table starts.
+	var left uint64
+	if off < bp.OffsetTableOffset {
+		left = bp.OffsetTableOffset - off
+	}
+	if count > left/size {
+		return bp.objectError(ErrTruncated, "%d items of %d bytes don't fit in the remaining %d bytes", count, size, left)
+	}
+	return nil
+}
+
+// readAt returns the n bytes at off, which lie within the plist.
+func (bp *binaryParser) readAt(off, n int64) ([]byte, error) {
+	if bp.data != nil {
+		return bp.data[off : off+n : off+n], nil
+	}
+	buf := make([]byte, n)
+	if m, err := bp.r.ReadAt(buf, off); m < len(buf) {
+		if err == io.EOF {
+			return nil, bp.formatError(off, ErrTruncated, "unexpected end of data reading %d", off)
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// uintBE returns the big-endian unsigned integer in b, of at most 8 bytes.
+func uintBE(b []byte) uint64 {
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u
+}
+
+// formatError returns a *BinaryFormatError for the object being decoded,
+// if any, or for the given offset.
+func (bp *binaryParser) formatError(offset int64, sentinel error, format string, args ...interface{}) *BinaryFormatError {
+	e := &BinaryFormatError{
+		Object: -1,
+		Offset: offset,
+		Marker: -1,
+		Msg:    fmt.Sprintf(format, args...),
+		Err:    sentinel,
+	}
+	if n := len(bp.frames); n > 0 {
+		top := bp.frames[n-1]
+		e.Object = int64(top.object)
+		e.Offset = int64(bp.OffsetTable[top.object])
+		e.Marker = top.marker
+		for _, f := range bp.frames[:n-1] {
+			e.Parents = append(e.Parents, f.object)
+		}
+	}
+	return e
+}
+
+// objectError returns a *BinaryFormatError for the object being decoded.
+func (bp *binaryParser) objectError(sentinel error, format string, args ...interface{}) error {
+	return bp.formatError(-1, sentinel, format, args...)
+}
+
+// limitError returns a *BinaryFormatError wrapping the *LimitError err, for
+// the object being decoded.
+func (bp *binaryParser) limitError(err error) error {
+	return bp.objectError(err, "%s", limitMsg(err))
+}
+
+func limitMsg(err error) string {
+	return strings.TrimPrefix(err.Error(), "plist: ")
+}
+
+// Errors of BinaryFormatError, which can be tested with errors.Is.
+var (
+	// ErrTruncated means that the data ends before the object or table that
+	// was being read.
+	ErrTruncated = errors.New("plist: truncated binary plist")
+	// ErrBadTrailer means that the trailer holds impossible values.
+	ErrBadTrailer = errors.New("plist: invalid binary plist trailer")
+	// ErrBadOffset means that an object offset or reference is out of range.
+	ErrBadOffset = errors.New("plist: invalid binary plist offset")
+	// ErrBadMarker means that an object has an unknown or invalid marker byte.
+	ErrBadMarker = errors.New("plist: invalid binary plist marker")
+	// ErrBadKey means that a dictionary key isn't a string.
+	ErrBadKey = errors.New("plist: binary plist dictionary key is not a string")
+	// ErrCycle means that an array, set or dictionary contains itself.
+	ErrCycle = errors.New("plist: binary plist object contains itself")
+)
+
+// A BinaryFormatError describes a corrupt binary plist.
+type BinaryFormatError struct {
+	Object  int64    // index of the object being decoded, or -1
+	Offset  int64    // byte offset of the object, or of the table with the error
+	Marker  int      // marker byte of the object, or -1 if it wasn't read
+	Parents []uint64 // indices of the collections holding the object, outermost first
+	Msg     string   // description of the error
+	Err     error    // one of the Err variables above, a *LimitError, or nil
+}
+
+func (e *BinaryFormatError) Error() string {
+	switch {
+	case e.Object < 0:
+		return fmt.Sprintf("plist: binary plist at offset %d: %s", e.Offset, e.Msg)
+	case e.Marker < 0:
+		return fmt.Sprintf("plist: binary object %d at offset %d: %s", e.Object, e.Offset, e.Msg)
+	default:
+		return fmt.Sprintf("plist: binary object %d (marker 0x%02x) at offset %d: %s", e.Object, e.Marker, e.Offset, e.Msg)
+	}
+}
+
+// Unwrap returns the sentinel error of e.
+func (e *BinaryFormatError) Unwrap() error {
+	return e.Err
+}
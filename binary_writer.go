@@ -0,0 +1,275 @@
+package plist
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+	"unicode/utf16"
+)
+
+// binaryEncoder writes a plistValue tree as a bplist00 document.
+// The tree is first flattened into an object table, sharing a single object
+// between all identical values and subtrees, and then written out followed by
+// the offset table and the trailer read by newBinaryParser.
+type binaryEncoder struct {
+	writer   io.Writer
+	keyOrder KeyOrder
+
+	objects []*binaryObject
+	uniques map[string]uint64
+}
+
+// binaryObject is a single entry of the object table.
+// Scalars are serialized up front, collections keep the indexes of their
+// members until the object reference size is known.
+type binaryObject struct {
+	marker byte     // marker byte for collections
+	data   []byte   // serialized scalar, nil for collections
+	refs   []uint64 // member indexes for collections
+}
+
+func newBinaryEncoder(w io.Writer) *binaryEncoder {
+	return &binaryEncoder{writer: w, uniques: make(map[string]uint64)}
+}
+
+func (e *binaryEncoder) generateDocument(pval *plistValue) error {
+	root, err := e.flatten(pval)
+	if err != nil {
+		return err
+	}
+
+	// Objects were added children first, which leaves the root at the end of
+	// the table. Reverse the table so that the root is the first object, as
+	// CoreFoundation writes it.
+	numObjects := uint64(len(e.objects))
+	index := func(ref uint64) uint64 { return numObjects - 1 - ref }
+
+	trailer := plistTrailer{
+		ObjectRefSize: intSize(numObjects - 1),
+		NumObjects:    numObjects,
+		RootObject:    index(root),
+	}
+
+	w := bufio.NewWriter(e.writer)
+	offsets := make([]uint64, numObjects)
+	offset := uint64(0)
+	write := func(b []byte) error {
+		n, err := w.Write(b)
+		offset += uint64(n)
+		return err
+	}
+
+	if err := write([]byte("bplist00")); err != nil {
+		return err
+	}
+	for i := len(e.objects) - 1; i >= 0; i-- {
+		obj := e.objects[i]
+		offsets[index(uint64(i))] = offset
+		if obj.refs == nil {
+			if err := write(obj.data); err != nil {
+				return err
+			}
+			continue
+		}
+		count := uint64(len(obj.refs))
+		if obj.marker == 0xd0 {
+			count /= 2
+		}
+		buf := appendCount(nil, obj.marker, count)
+		for _, ref := range obj.refs {
+			buf = appendSizedInt(buf, index(ref), trailer.ObjectRefSize)
+		}
+		if err := write(buf); err != nil {
+			return err
+		}
+	}
+
+	trailer.OffsetTableOffset = offset
+	trailer.OffsetIntSize = intSize(offset)
+	var table []byte
+	for _, off := range offsets {
+		table = appendSizedInt(table, off, trailer.OffsetIntSize)
+	}
+	if err := write(table); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, &trailer); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// flatten adds pval and all of its children to the object table and returns
+// the index of pval. Values which serialize identically are only added once.
+func (e *binaryEncoder) flatten(pval *plistValue) (uint64, error) {
+	obj := &binaryObject{}
+	switch pval.kind {
+	case Dictionary:
+		dict := pval.value.(*dictionary)
+		dict.populateArrays(e.keyOrder)
+		obj.marker = 0xd0
+		obj.refs = make([]uint64, 0, 2*len(dict.keys))
+		for _, k := range dict.keys {
+			ref, err := e.flatten(&plistValue{String, k})
+			if err != nil {
+				return 0, err
+			}
+			obj.refs = append(obj.refs, ref)
+		}
+		for _, v := range dict.values {
+			ref, err := e.flatten(v)
+			if err != nil {
+				return 0, err
+			}
+			obj.refs = append(obj.refs, ref)
+		}
+	case Array, SetKind, OrderedSetKind:
+		values := pval.value.([]*plistValue)
+		switch pval.kind {
+		case Array:
+			obj.marker = 0xa0
+		case OrderedSetKind:
+			obj.marker = 0xb0
+		default:
+			obj.marker = 0xc0
+		}
+		obj.refs = make([]uint64, 0, len(values))
+		for _, v := range values {
+			ref, err := e.flatten(v)
+			if err != nil {
+				return 0, err
+			}
+			obj.refs = append(obj.refs, ref)
+		}
+	case String:
+		obj.data = appendString(nil, pval.value.(string))
+	case Integer:
+		obj.data = appendInteger(nil, pval.value.(signedInt))
+	case Real:
+		obj.data = appendReal(nil, pval.value.(sizedFloat))
+	case Boolean:
+		if pval.value.(bool) {
+			obj.data = []byte{0x09}
+		} else {
+			obj.data = []byte{0x08}
+		}
+	case Data:
+		data := pval.value.([]byte)
+		obj.data = append(appendCount(nil, 0x40, uint64(len(data))), data...)
+	case Date:
+		obj.data = appendDate(nil, pval.value.(time.Time))
+	case UIDKind:
+		uid := uint64(pval.value.(UID))
+		size := intSize(uid)
+		obj.data = appendSizedInt([]byte{0x80 | (size - 1)}, uid, size)
+	case NullKind:
+		obj.data = []byte{0x00}
+	default:
+		return 0, kindError(pval)
+	}
+	return e.add(obj), nil
+}
+
+// add appends obj to the object table unless an identical object is already
+// present, and returns its index.
+func (e *binaryEncoder) add(obj *binaryObject) uint64 {
+	key := obj.data
+	if obj.refs != nil {
+		key = []byte{obj.marker}
+		for _, ref := range obj.refs {
+			key = appendSizedInt(key, ref, 8)
+		}
+	}
+	if ref, ok := e.uniques[string(key)]; ok {
+		return ref
+	}
+	ref := uint64(len(e.objects))
+	e.objects = append(e.objects, obj)
+	e.uniques[string(key)] = ref
+	return ref
+}
+
+// intSize returns the smallest number of bytes (1, 2, 4 or 8) which can hold n.
+func intSize(n uint64) uint8 {
+	switch {
+	case n <= math.MaxUint8:
+		return 1
+	case n <= math.MaxUint16:
+		return 2
+	case n <= math.MaxUint32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// appendSizedInt appends the low size bytes of n in big-endian order.
+func appendSizedInt(b []byte, n uint64, size uint8) []byte {
+	for i := int(size) - 1; i >= 0; i-- {
+		b = append(b, byte(n>>(8*uint(i))))
+	}
+	return b
+}
+
+// appendCount appends marker with count encoded the way readCount expects it.
+func appendCount(b []byte, marker byte, count uint64) []byte {
+	if count < 0xf {
+		return append(b, marker|byte(count))
+	}
+	b = append(b, marker|0xf)
+	return appendInteger(b, signedInt{count, false})
+}
+
+func appendInteger(b []byte, i signedInt) []byte {
+	// Negative values are always written in 8 bytes, like CoreFoundation
+	// does. Unsigned values which don't fit into a signed 64-bit integer are
+	// written as 16-byte integers with the upper 8 bytes zeroed; see
+	// binaryParser.parseInteger.
+	if i.signed && int64(i.value) < 0 {
+		return appendSizedInt(append(b, 0x13), i.value, 8)
+	}
+	if i.value > math.MaxInt64 {
+		b = appendSizedInt(append(b, 0x14), 0, 8)
+		return appendSizedInt(b, i.value, 8)
+	}
+	size := intSize(i.value)
+	var exp byte
+	for s := size; s > 1; s >>= 1 {
+		exp++
+	}
+	return appendSizedInt(append(b, 0x10|exp), i.value, size)
+}
+
+func appendReal(b []byte, f sizedFloat) []byte {
+	if f.bits == 32 {
+		return appendSizedInt(append(b, 0x22), uint64(math.Float32bits(float32(f.value))), 4)
+	}
+	return appendSizedInt(append(b, 0x23), math.Float64bits(f.value), 8)
+}
+
+func appendDate(b []byte, t time.Time) []byte {
+	// Dates are stored as seconds since the Apple epoch (Jan 1, 2001 GMT).
+	secs := float64(t.Unix()-978307200) + float64(t.Nanosecond())/1e9
+	return appendSizedInt(append(b, 0x33), math.Float64bits(secs), 8)
+}
+
+func appendString(b []byte, s string) []byte {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return append(appendCount(b, 0x50, uint64(len(s))), s...)
+	}
+	uni := utf16.Encode([]rune(s))
+	b = appendCount(b, 0x60, uint64(len(uni)))
+	for _, u := range uni {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return b
+}
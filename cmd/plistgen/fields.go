@@ -0,0 +1,244 @@
+package main
+
+import (
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// field is a struct field as plist.Encoder and plist.Decoder see it. The
+// rules for finding the fields of a struct are those of typeFields in
+// package plist, applied to go/types instead of reflect.
+type field struct {
+	name      string
+	tag       bool
+	index     []int
+	vars      []*types.Var // the field and the embedded fields leading to it
+	typ       types.Type
+	omitEmpty bool
+}
+
+type byName []field
+
+func (x byName) Len() int { return len(x) }
+
+func (x byName) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+
+func (x byName) Less(i, j int) bool {
+	if x[i].name != x[j].name {
+		return x[i].name < x[j].name
+	}
+	if len(x[i].index) != len(x[j].index) {
+		return len(x[i].index) < len(x[j].index)
+	}
+	if x[i].tag != x[j].tag {
+		return x[i].tag
+	}
+	return byIndex(x).Less(i, j)
+}
+
+type byIndex []field
+
+func (x byIndex) Len() int { return len(x) }
+
+func (x byIndex) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+
+func (x byIndex) Less(i, j int) bool {
+	for k, xik := range x[i].index {
+		if k >= len(x[j].index) {
+			return false
+		}
+		if xik != x[j].index[k] {
+			return xik < x[j].index[k]
+		}
+	}
+	return len(x[i].index) < len(x[j].index)
+}
+
+// typeFields returns the fields plist recognizes for the struct type t, in
+// index order.
+func typeFields(t types.Type) []field {
+	// Anonymous fields to explore at the current level and the next.
+	current := []field{}
+	next := []field{{typ: t}}
+
+	// Count of queued names for current level and the next.
+	count := map[types.Type]int{}
+	nextCount := map[types.Type]int{}
+
+	// Types already visited at an earlier level.
+	visited := map[types.Type]bool{}
+
+	// Fields found.
+	var fields []field
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[types.Type]int{}
+
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+
+			// Scan f.typ for fields to include.
+			st := f.typ.Underlying().(*types.Struct)
+			for i := 0; i < st.NumFields(); i++ {
+				sf := st.Field(i)
+				if !sf.Exported() && !sf.Anonymous() { // unexported
+					continue
+				}
+				tag := reflect.StructTag(st.Tag(i)).Get("plist")
+				if tag == "-" {
+					continue
+				}
+				name, opts := parseTag(tag)
+				if !isValidTag(name) {
+					name = ""
+				}
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+				vars := make([]*types.Var, len(f.vars)+1)
+				copy(vars, f.vars)
+				vars[len(f.vars)] = sf
+
+				ft := sf.Type()
+				if p, ok := ft.(*types.Pointer); ok {
+					// Follow pointer.
+					ft = p.Elem()
+				}
+				_, isStruct := ft.Underlying().(*types.Struct)
+
+				// Record found field and index sequence.
+				if name != "" || !sf.Anonymous() || !isStruct {
+					tagged := name != ""
+					if name == "" {
+						name = sf.Name()
+					}
+					fields = append(fields, field{
+						name:      name,
+						tag:       tagged,
+						index:     index,
+						vars:      vars,
+						typ:       ft,
+						omitEmpty: opts.contains("omitempty"),
+					})
+					if count[f.typ] > 1 {
+						// If there were multiple instances, add a second,
+						// so that the annihilation code will see a duplicate.
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+
+				// Record new anonymous struct to explore in next round.
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, field{index: index, vars: vars, typ: ft})
+				}
+			}
+		}
+	}
+
+	sort.Sort(byName(fields))
+
+	// Delete all fields that are hidden by the Go rules for embedded fields,
+	// except that fields with plist tags are promoted.
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		// One iteration per name.
+		fi := fields[i]
+		name := fi.name
+		for advance = 1; i+advance < len(fields); advance++ {
+			fj := fields[i+advance]
+			if fj.name != name {
+				break
+			}
+		}
+		if advance == 1 { // Only one field with this name
+			out = append(out, fi)
+			continue
+		}
+		dominant, ok := dominantField(fields[i : i+advance])
+		if ok {
+			out = append(out, dominant)
+		}
+	}
+
+	fields = out
+	sort.Sort(byIndex(fields))
+
+	return fields
+}
+
+func dominantField(fields []field) (field, bool) {
+	// The fields are sorted in increasing index-length order. The winner
+	// must therefore be one with the shortest index length.
+	length := len(fields[0].index)
+	tagged := -1 // Index of first tagged field.
+	for i, f := range fields {
+		if len(f.index) > length {
+			fields = fields[:i]
+			break
+		}
+		if f.tag {
+			if tagged >= 0 {
+				// Multiple tagged fields at the same level: conflict.
+				return field{}, false
+			}
+			tagged = i
+		}
+	}
+	if tagged >= 0 {
+		return fields[tagged], true
+	}
+	// All remaining fields have the same length. If there's more than one,
+	// we have a conflict.
+	if len(fields) > 1 {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+// tagOptions is the string following a comma in a struct field's "plist"
+// tag, or the empty string.
+type tagOptions string
+
+func parseTag(tag string) (string, tagOptions) {
+	if idx := strings.Index(tag, ","); idx != -1 {
+		return tag[:idx], tagOptions(tag[idx+1:])
+	}
+	return tag, tagOptions("")
+}
+
+func (o tagOptions) contains(optionName string) bool {
+	for _, s := range strings.Split(string(o), ",") {
+		if s == optionName {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:<=>?@[]^_{|}~ ", c):
+			// Backslash and quote chars are reserved, but
+			// otherwise any punctuation chars are allowed
+			// in a tag name.
+		default:
+			if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+				return false
+			}
+		}
+	}
+	return true
+}
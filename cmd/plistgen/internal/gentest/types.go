@@ -0,0 +1,200 @@
+// Package gentest holds types with methods written by plistgen, to test them
+// against the Encoder and Decoder.
+package gentest
+
+import (
+	"strings"
+	"time"
+
+	"github.com/groob/plist"
+)
+
+//go:generate go run github.com/groob/plist/cmd/plistgen -type=Header,Unused,Sample,Sets,Node,Outer,Omit,Named,Command,Setting,Maps,Arrays -output=types_plist.go
+
+// Header is the sparse bundle header of the encode tests.
+type Header struct {
+	InfoDictionaryVersion string `plist:"CFBundleInfoDictionaryVersion"`
+	BandSize              uint64 `plist:"band-size"`
+	BackingStoreVersion   int    `plist:"bundle-backingstore-version"`
+	DiskImageBundleType   string `plist:"diskimage-bundle-type"`
+	Size                  uint64 `plist:"size"`
+	Unused                Unused `plist:"useless"`
+}
+
+type Unused struct {
+	UnusedString string `plist:"unused-string"`
+	UnusedByte   []byte `plist:"unused-byte,omitempty"`
+}
+
+// Sample has a field of every kind of plist value.
+type Sample struct {
+	Strings  []string           `plist:"strings"`
+	Ints     []int64            `plist:"ints"`
+	Uint64   uint64             `plist:"uint64"`
+	Float32  float32            `plist:"float32"`
+	Float64  float64            `plist:"float64"`
+	Bool     bool               `plist:"bool"`
+	Data     []byte             `plist:"data"`
+	Date     time.Time          `plist:"date"`
+	Nested   map[string]string  `plist:"nested"`
+	Repeated []map[string]int64 `plist:"repeated"`
+	Matrix   [][]int            `plist:"matrix"`
+}
+
+type Sets struct {
+	Set        plist.Set        `plist:"set"`
+	OrderedSet plist.OrderedSet `plist:"ordered"`
+	UID        plist.UID        `plist:"uid"`
+	Null       *string          `plist:"null"`
+	Any        interface{}      `plist:"any"`
+	Value      *plist.Value     `plist:"value"`
+}
+
+type Node struct {
+	Name     string
+	Children []Node `plist:",omitempty"`
+	Next     *Node  `plist:",omitempty"`
+}
+
+type Base struct {
+	ID    string
+	Shade string `plist:"Color"`
+	Extra string
+}
+
+type Mixin struct {
+	Color string // hidden by the tagged Base.Shade
+	Extra string // conflicts with Base.Extra
+	Depth int
+}
+
+type Deep struct {
+	Depth int // conflicts with Mixin.Depth
+	Leaf  bool
+}
+
+type Inner struct {
+	Deep
+	Count int
+}
+
+type Outer struct {
+	Base
+	*Mixin
+	Inner    `plist:"inner"`
+	*Deep    // a second Deep
+	Name     string
+	Skipped  string `plist:"-"`
+	Dash     string `plist:"-,"`
+	internal string
+}
+
+type Point struct {
+	X, Y int
+}
+
+type Tags struct {
+	List []string
+}
+
+type Omit struct {
+	String    string            `plist:",omitempty"`
+	Int       int8              `plist:",omitempty"`
+	Uint      uint16            `plist:",omitempty"`
+	Float     float32           `plist:",omitempty"`
+	Bool      bool              `plist:",omitempty"`
+	Data      []byte            `plist:",omitempty"`
+	Slice     []string          `plist:",omitempty"`
+	Map       map[string]string `plist:",omitempty"`
+	Array     [0]int            `plist:",omitempty"`
+	Pointer   *int              `plist:",omitempty"`
+	Interface interface{}       `plist:",omitempty"`
+	Point     Point             `plist:",omitempty"`
+	Tags      Tags              `plist:",omitempty"`
+	Date      time.Time         `plist:",omitempty"`
+	Upper     Upper             `plist:",omitempty"`
+	Kept      int
+}
+
+// Upper is a string which is upper case in plists.
+type Upper string
+
+func (u Upper) MarshalPlist() (interface{}, error) {
+	return strings.ToUpper(string(u)), nil
+}
+
+func (u *Upper) UnmarshalPlist(f func(interface{}) error) error {
+	var s string
+	if err := f(&s); err != nil {
+		return err
+	}
+	*u = Upper(strings.ToLower(s))
+	return nil
+}
+
+type (
+	Celsius float32
+	Level   int8
+	Label   string
+	Blob    []byte
+	Flag    bool
+	Code    uint8
+	Codes   []Code
+)
+
+// Named has fields of named types, which take conversions.
+type Named struct {
+	Celsius Celsius
+	Level   Level
+	Labels  []Label
+	Blob    Blob
+	Flags   []Flag
+	Codes   Codes
+	Uppers  []Upper
+	Points  []*Point
+	Nodes   []*Node
+	Bad     []interface{}
+}
+
+// Command looks like the MDM commands of the benchmarks.
+type Command struct {
+	CommandUUID string
+	Command     struct {
+		RequestType string
+		Queries     []string
+		Identifier  string `plist:",omitempty"`
+		Payload     []byte `plist:",omitempty"`
+		Settings    []Setting
+	}
+	Meta map[string]interface{}
+}
+
+type Setting struct {
+	Item    string
+	Enabled bool
+	Count   int
+}
+
+// Maps has values which aren't addressable, so that the Encoder doesn't use
+// their Marshalers with pointer receivers.
+type Maps struct {
+	Nodes  map[string]Node
+	Points map[Label]*Point
+	Uppers map[string]Upper
+	Tenths map[string]Tenths
+	Tenth  Tenths
+}
+
+// Tenths is written in tenths by a method with a pointer receiver.
+type Tenths int
+
+func (t *Tenths) MarshalPlist() (interface{}, error) {
+	return int(*t) * 10, nil
+}
+
+// Arrays has arrays, which the Encoder encodes like slices and the Decoder
+// doesn't decode.
+type Arrays struct {
+	Fixed [2]Point
+	Map   map[string][2]Point
+}
@@ -0,0 +1,1667 @@
+// Code generated by "plistgen -type=Header,Unused,Sample,Sets,Node,Outer,Omit,Named,Command,Setting,Maps,Arrays"; DO NOT EDIT.
+
+package gentest
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/groob/plist"
+)
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *Header) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return plist.NewNull(), nil
+	}
+	dict1 := plist.NewStructDict(6)
+	dict1.Set("CFBundleInfoDictionaryVersion", plist.NewString(t.InfoDictionaryVersion))
+	dict1.Set("band-size", plist.NewUint(t.BandSize))
+	dict1.Set("bundle-backingstore-version", plist.NewInt(int64(t.BackingStoreVersion)))
+	dict1.Set("diskimage-bundle-type", plist.NewString(t.DiskImageBundleType))
+	dict1.Set("size", plist.NewUint(t.Size))
+	m2, err := t.Unused.MarshalPlist()
+	if err != nil {
+		return nil, plist.PrependPath(err, "useless")
+	}
+	dict1.Set("useless", m2.(*plist.Value))
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *Header) UnmarshalPlist(f func(interface{}) error) error {
+	var d plist.ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != plist.Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("CFBundleInfoDictionaryVersion", "band-size", "bundle-backingstore-version", "diskimage-bundle-type", "size", "useless"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("CFBundleInfoDictionaryVersion"); ok {
+		if x, ok := v1.AsString(); ok {
+			t.InfoDictionaryVersion = x
+		} else if err := v1.Decode(&t.InfoDictionaryVersion); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("band-size"); ok {
+		if x, ok := v2.AsUint(); ok {
+			t.BandSize = x
+		} else if err := v2.Decode(&t.BandSize); err != nil {
+			return err
+		}
+	}
+	if v3, ok := d.Key("bundle-backingstore-version"); ok {
+		if x, ok := v3.AsInt(); ok {
+			t.BackingStoreVersion = int(x)
+		} else if err := v3.Decode(&t.BackingStoreVersion); err != nil {
+			return err
+		}
+	}
+	if v4, ok := d.Key("diskimage-bundle-type"); ok {
+		if x, ok := v4.AsString(); ok {
+			t.DiskImageBundleType = x
+		} else if err := v4.Decode(&t.DiskImageBundleType); err != nil {
+			return err
+		}
+	}
+	if v5, ok := d.Key("size"); ok {
+		if x, ok := v5.AsUint(); ok {
+			t.Size = x
+		} else if err := v5.Decode(&t.Size); err != nil {
+			return err
+		}
+	}
+	if v6, ok := d.Key("useless"); ok {
+		if v6.Kind() == plist.Dictionary {
+			if err := v6.CheckFields("unused-string", "unused-byte"); err != nil {
+				return err
+			}
+			if v7, ok := v6.Key("unused-string"); ok {
+				if x, ok := v7.AsString(); ok {
+					t.Unused.UnusedString = x
+				} else if err := v7.Decode(&t.Unused.UnusedString); err != nil {
+					return err
+				}
+			}
+			if v8, ok := v6.Key("unused-byte"); ok {
+				if x, ok := v8.AsData(); ok {
+					t.Unused.UnusedByte = x
+				} else if err := v8.Decode(&t.Unused.UnusedByte); err != nil {
+					return err
+				}
+			}
+		} else if err := v6.Decode(&t.Unused); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *Unused) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return plist.NewNull(), nil
+	}
+	dict1 := plist.NewStructDict(2)
+	dict1.Set("unused-string", plist.NewString(t.UnusedString))
+	if len(t.UnusedByte) != 0 {
+		dict1.Set("unused-byte", plist.NewData(t.UnusedByte))
+	}
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *Unused) UnmarshalPlist(f func(interface{}) error) error {
+	var d plist.ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != plist.Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("unused-string", "unused-byte"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("unused-string"); ok {
+		if x, ok := v1.AsString(); ok {
+			t.UnusedString = x
+		} else if err := v1.Decode(&t.UnusedString); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("unused-byte"); ok {
+		if x, ok := v2.AsData(); ok {
+			t.UnusedByte = x
+		} else if err := v2.Decode(&t.UnusedByte); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *Sample) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return plist.NewNull(), nil
+	}
+	dict1 := plist.NewStructDict(11)
+	a2 := plist.MakeArray(len(t.Strings))
+	for i3 := range t.Strings {
+		a2.SetIndex(i3, plist.NewString(t.Strings[i3]))
+	}
+	dict1.Set("strings", a2)
+	a4 := plist.MakeArray(len(t.Ints))
+	for i5 := range t.Ints {
+		a4.SetIndex(i5, plist.NewInt(t.Ints[i5]))
+	}
+	dict1.Set("ints", a4)
+	dict1.Set("uint64", plist.NewUint(t.Uint64))
+	dict1.Set("float32", plist.NewReal32(t.Float32))
+	dict1.Set("float64", plist.NewReal(t.Float64))
+	dict1.Set("bool", plist.NewBool(t.Bool))
+	dict1.Set("data", plist.NewData(t.Data))
+	dict1.Set("date", plist.NewDate(t.Date))
+	dict6 := plist.NewMapDict(len(t.Nested))
+	for k7, e8 := range t.Nested {
+		dict6.Set(k7, plist.NewString(e8))
+	}
+	dict1.Set("nested", dict6)
+	a9 := plist.MakeArray(len(t.Repeated))
+	for i10 := range t.Repeated {
+		dict11 := plist.NewMapDict(len(t.Repeated[i10]))
+		for k12, e13 := range t.Repeated[i10] {
+			dict11.Set(k12, plist.NewInt(e13))
+		}
+		a9.SetIndex(i10, dict11)
+	}
+	dict1.Set("repeated", a9)
+	a14 := plist.MakeArray(len(t.Matrix))
+	for i15 := range t.Matrix {
+		a16 := plist.MakeArray(len(t.Matrix[i15]))
+		for i17 := range t.Matrix[i15] {
+			a16.SetIndex(i17, plist.NewInt(int64(t.Matrix[i15][i17])))
+		}
+		a14.SetIndex(i15, a16)
+	}
+	dict1.Set("matrix", a14)
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *Sample) UnmarshalPlist(f func(interface{}) error) error {
+	var d plist.ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != plist.Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("strings", "ints", "uint64", "float32", "float64", "bool", "data", "date", "nested", "repeated", "matrix"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("strings"); ok {
+		if v1.Kind() == plist.Array {
+			n2 := v1.Len()
+			if n2 >= cap(t.Strings) {
+				c := 2 * n2
+				if c < 4 {
+					c = 4
+				}
+				s := make([]string, len(t.Strings), c)
+				copy(s, t.Strings)
+				t.Strings = s
+			}
+			t.Strings = t.Strings[:n2]
+			for i3 := 0; i3 < n2; i3++ {
+				e4 := v1.Index(i3)
+				if x, ok := e4.AsString(); ok {
+					t.Strings[i3] = x
+				} else if err := e4.Decode(&t.Strings[i3]); err != nil {
+					return err
+				}
+			}
+		} else if err := v1.Decode(&t.Strings); err != nil {
+			return err
+		}
+	}
+	if v5, ok := d.Key("ints"); ok {
+		if v5.Kind() == plist.Array {
+			n6 := v5.Len()
+			if n6 >= cap(t.Ints) {
+				c := 2 * n6
+				if c < 4 {
+					c = 4
+				}
+				s := make([]int64, len(t.Ints), c)
+				copy(s, t.Ints)
+				t.Ints = s
+			}
+			t.Ints = t.Ints[:n6]
+			for i7 := 0; i7 < n6; i7++ {
+				e8 := v5.Index(i7)
+				if x, ok := e8.AsInt(); ok {
+					t.Ints[i7] = x
+				} else if err := e8.Decode(&t.Ints[i7]); err != nil {
+					return err
+				}
+			}
+		} else if err := v5.Decode(&t.Ints); err != nil {
+			return err
+		}
+	}
+	if v9, ok := d.Key("uint64"); ok {
+		if x, ok := v9.AsUint(); ok {
+			t.Uint64 = x
+		} else if err := v9.Decode(&t.Uint64); err != nil {
+			return err
+		}
+	}
+	if v10, ok := d.Key("float32"); ok {
+		if x, ok := v10.AsReal(); ok {
+			t.Float32 = float32(x)
+		} else if err := v10.Decode(&t.Float32); err != nil {
+			return err
+		}
+	}
+	if v11, ok := d.Key("float64"); ok {
+		if x, ok := v11.AsReal(); ok {
+			t.Float64 = x
+		} else if err := v11.Decode(&t.Float64); err != nil {
+			return err
+		}
+	}
+	if v12, ok := d.Key("bool"); ok {
+		if x, ok := v12.AsBool(); ok {
+			t.Bool = x
+		} else if err := v12.Decode(&t.Bool); err != nil {
+			return err
+		}
+	}
+	if v13, ok := d.Key("data"); ok {
+		if x, ok := v13.AsData(); ok {
+			t.Data = x
+		} else if err := v13.Decode(&t.Data); err != nil {
+			return err
+		}
+	}
+	if v14, ok := d.Key("date"); ok {
+		if x, ok := v14.AsDate(); ok {
+			t.Date = x
+		} else if err := v14.Decode(&t.Date); err != nil {
+			return err
+		}
+	}
+	if v15, ok := d.Key("nested"); ok {
+		if err := v15.Decode(&t.Nested); err != nil {
+			return err
+		}
+	}
+	if v16, ok := d.Key("repeated"); ok {
+		if v16.Kind() == plist.Array {
+			n17 := v16.Len()
+			if n17 >= cap(t.Repeated) {
+				c := 2 * n17
+				if c < 4 {
+					c = 4
+				}
+				s := make([]map[string]int64, len(t.Repeated), c)
+				copy(s, t.Repeated)
+				t.Repeated = s
+			}
+			t.Repeated = t.Repeated[:n17]
+			for i18 := 0; i18 < n17; i18++ {
+				e19 := v16.Index(i18)
+				if err := e19.Decode(&t.Repeated[i18]); err != nil {
+					return err
+				}
+			}
+		} else if err := v16.Decode(&t.Repeated); err != nil {
+			return err
+		}
+	}
+	if v20, ok := d.Key("matrix"); ok {
+		if v20.Kind() == plist.Array {
+			n21 := v20.Len()
+			if n21 >= cap(t.Matrix) {
+				c := 2 * n21
+				if c < 4 {
+					c = 4
+				}
+				s := make([][]int, len(t.Matrix), c)
+				copy(s, t.Matrix)
+				t.Matrix = s
+			}
+			t.Matrix = t.Matrix[:n21]
+			for i22 := 0; i22 < n21; i22++ {
+				e23 := v20.Index(i22)
+				if e23.Kind() == plist.Array {
+					n24 := e23.Len()
+					if n24 >= cap(t.Matrix[i22]) {
+						c := 2 * n24
+						if c < 4 {
+							c = 4
+						}
+						s := make([]int, len(t.Matrix[i22]), c)
+						copy(s, t.Matrix[i22])
+						t.Matrix[i22] = s
+					}
+					t.Matrix[i22] = t.Matrix[i22][:n24]
+					for i25 := 0; i25 < n24; i25++ {
+						e26 := e23.Index(i25)
+						if x, ok := e26.AsInt(); ok {
+							t.Matrix[i22][i25] = int(x)
+						} else if err := e26.Decode(&t.Matrix[i22][i25]); err != nil {
+							return err
+						}
+					}
+				} else if err := e23.Decode(&t.Matrix[i22]); err != nil {
+					return err
+				}
+			}
+		} else if err := v20.Decode(&t.Matrix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *Sets) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return plist.NewNull(), nil
+	}
+	dict1 := plist.NewStructDict(6)
+	a2 := make([]*plist.Value, len(t.Set))
+	for i3 := range t.Set {
+		v, err := plist.MarshalInterface(t.Set[i3])
+		if err != nil {
+			return nil, plist.PrependPath(plist.PrependPath(err, "["+strconv.Itoa(i3)+"]"), "set")
+		}
+		a2[i3] = v
+	}
+	dict1.Set("set", plist.NewSet(a2...))
+	a4 := make([]*plist.Value, len(t.OrderedSet))
+	for i5 := range t.OrderedSet {
+		v, err := plist.MarshalInterface(t.OrderedSet[i5])
+		if err != nil {
+			return nil, plist.PrependPath(plist.PrependPath(err, "["+strconv.Itoa(i5)+"]"), "ordered")
+		}
+		a4[i5] = v
+	}
+	dict1.Set("ordered", plist.NewOrderedSet(a4...))
+	dict1.Set("uid", plist.NewUID(t.UID))
+	v6, err := plist.MarshalValue(&t.Null)
+	if err != nil {
+		return nil, plist.PrependPath(err, "null")
+	}
+	dict1.Set("null", v6)
+	v7, err := plist.MarshalInterface(t.Any)
+	if err != nil {
+		return nil, plist.PrependPath(err, "any")
+	}
+	dict1.Set("any", v7)
+	v8, err := plist.MarshalValue(&t.Value)
+	if err != nil {
+		return nil, plist.PrependPath(err, "value")
+	}
+	dict1.Set("value", v8)
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *Sets) UnmarshalPlist(f func(interface{}) error) error {
+	var d plist.ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != plist.Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("set", "ordered", "uid", "null", "any", "value"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("set"); ok {
+		if v1.Kind() == plist.Array {
+			n2 := v1.Len()
+			if n2 >= cap(t.Set) {
+				c := 2 * n2
+				if c < 4 {
+					c = 4
+				}
+				s := make(plist.Set, len(t.Set), c)
+				copy(s, t.Set)
+				t.Set = s
+			}
+			t.Set = t.Set[:n2]
+			for i3 := 0; i3 < n2; i3++ {
+				e4 := v1.Index(i3)
+				if err := e4.Decode(&t.Set[i3]); err != nil {
+					return err
+				}
+			}
+		} else if err := v1.Decode(&t.Set); err != nil {
+			return err
+		}
+	}
+	if v5, ok := d.Key("ordered"); ok {
+		if v5.Kind() == plist.Array {
+			n6 := v5.Len()
+			if n6 >= cap(t.OrderedSet) {
+				c := 2 * n6
+				if c < 4 {
+					c = 4
+				}
+				s := make(plist.OrderedSet, len(t.OrderedSet), c)
+				copy(s, t.OrderedSet)
+				t.OrderedSet = s
+			}
+			t.OrderedSet = t.OrderedSet[:n6]
+			for i7 := 0; i7 < n6; i7++ {
+				e8 := v5.Index(i7)
+				if err := e8.Decode(&t.OrderedSet[i7]); err != nil {
+					return err
+				}
+			}
+		} else if err := v5.Decode(&t.OrderedSet); err != nil {
+			return err
+		}
+	}
+	if v9, ok := d.Key("uid"); ok {
+		if x, ok := v9.AsUint(); ok {
+			t.UID = plist.UID(x)
+		} else if err := v9.Decode(&t.UID); err != nil {
+			return err
+		}
+	}
+	if v10, ok := d.Key("null"); ok {
+		if err := v10.Decode(&t.Null); err != nil {
+			return err
+		}
+	}
+	if v11, ok := d.Key("any"); ok {
+		if err := v11.Decode(&t.Any); err != nil {
+			return err
+		}
+	}
+	if v12, ok := d.Key("value"); ok {
+		if err := v12.Decode(&t.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *Node) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return plist.NewNull(), nil
+	}
+	dict1 := plist.NewStructDict(3)
+	dict1.Set("Name", plist.NewString(t.Name))
+	if len(t.Children) != 0 {
+		a2 := plist.MakeArray(len(t.Children))
+		for i3 := range t.Children {
+			m4, err := t.Children[i3].MarshalPlist()
+			if err != nil {
+				return nil, plist.PrependPath(plist.PrependPath(err, "["+strconv.Itoa(i3)+"]"), "Children")
+			}
+			a2.SetIndex(i3, m4.(*plist.Value))
+		}
+		dict1.Set("Children", a2)
+	}
+	if t.Next != nil {
+		m5, err := t.Next.MarshalPlist()
+		if err != nil {
+			return nil, plist.PrependPath(err, "Next")
+		}
+		dict1.Set("Next", m5.(*plist.Value))
+	}
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *Node) UnmarshalPlist(f func(interface{}) error) error {
+	var d plist.ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != plist.Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("Name", "Children", "Next"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("Name"); ok {
+		if x, ok := v1.AsString(); ok {
+			t.Name = x
+		} else if err := v1.Decode(&t.Name); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("Children"); ok {
+		if v2.Kind() == plist.Array {
+			n3 := v2.Len()
+			if n3 >= cap(t.Children) {
+				c := 2 * n3
+				if c < 4 {
+					c = 4
+				}
+				s := make([]Node, len(t.Children), c)
+				copy(s, t.Children)
+				t.Children = s
+			}
+			t.Children = t.Children[:n3]
+			for i4 := 0; i4 < n3; i4++ {
+				e5 := v2.Index(i4)
+				if err := e5.Decode(&t.Children[i4]); err != nil {
+					return err
+				}
+			}
+		} else if err := v2.Decode(&t.Children); err != nil {
+			return err
+		}
+	}
+	if v6, ok := d.Key("Next"); ok {
+		if err := v6.Decode(&t.Next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *Outer) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return plist.NewNull(), nil
+	}
+	dict1 := plist.NewStructDict(6)
+	dict1.Set("ID", plist.NewString(t.Base.ID))
+	dict1.Set("Color", plist.NewString(t.Base.Shade))
+	dict2 := plist.NewStructDict(3)
+	dict2.Set("Depth", plist.NewInt(int64(t.Inner.Deep.Depth)))
+	dict2.Set("Leaf", plist.NewBool(t.Inner.Deep.Leaf))
+	dict2.Set("Count", plist.NewInt(int64(t.Inner.Count)))
+	dict1.Set("inner", dict2)
+	if t.Deep == nil {
+		t.Deep = new(Deep)
+	}
+	dict1.Set("Leaf", plist.NewBool(t.Deep.Leaf))
+	dict1.Set("Name", plist.NewString(t.Name))
+	dict1.Set("-", plist.NewString(t.Dash))
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *Outer) UnmarshalPlist(f func(interface{}) error) error {
+	var d plist.ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != plist.Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("ID", "Color", "inner", "Leaf", "Name", "-"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("ID"); ok {
+		if x, ok := v1.AsString(); ok {
+			t.Base.ID = x
+		} else if err := v1.Decode(&t.Base.ID); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("Color"); ok {
+		if x, ok := v2.AsString(); ok {
+			t.Base.Shade = x
+		} else if err := v2.Decode(&t.Base.Shade); err != nil {
+			return err
+		}
+	}
+	if v3, ok := d.Key("inner"); ok {
+		if v3.Kind() == plist.Dictionary {
+			if err := v3.CheckFields("Depth", "Leaf", "Count"); err != nil {
+				return err
+			}
+			if v4, ok := v3.Key("Depth"); ok {
+				if x, ok := v4.AsInt(); ok {
+					t.Inner.Deep.Depth = int(x)
+				} else if err := v4.Decode(&t.Inner.Deep.Depth); err != nil {
+					return err
+				}
+			}
+			if v5, ok := v3.Key("Leaf"); ok {
+				if x, ok := v5.AsBool(); ok {
+					t.Inner.Deep.Leaf = x
+				} else if err := v5.Decode(&t.Inner.Deep.Leaf); err != nil {
+					return err
+				}
+			}
+			if v6, ok := v3.Key("Count"); ok {
+				if x, ok := v6.AsInt(); ok {
+					t.Inner.Count = int(x)
+				} else if err := v6.Decode(&t.Inner.Count); err != nil {
+					return err
+				}
+			}
+		} else if err := v3.Decode(&t.Inner); err != nil {
+			return err
+		}
+	}
+	if v7, ok := d.Key("Leaf"); ok {
+		if t.Deep == nil {
+			t.Deep = new(Deep)
+		}
+		if x, ok := v7.AsBool(); ok {
+			t.Deep.Leaf = x
+		} else if err := v7.Decode(&t.Deep.Leaf); err != nil {
+			return err
+		}
+	}
+	if v8, ok := d.Key("Name"); ok {
+		if x, ok := v8.AsString(); ok {
+			t.Name = x
+		} else if err := v8.Decode(&t.Name); err != nil {
+			return err
+		}
+	}
+	if v9, ok := d.Key("-"); ok {
+		if x, ok := v9.AsString(); ok {
+			t.Dash = x
+		} else if err := v9.Decode(&t.Dash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *Omit) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return plist.NewNull(), nil
+	}
+	dict1 := plist.NewStructDict(16)
+	if len(t.String) != 0 {
+		dict1.Set("String", plist.NewString(t.String))
+	}
+	if t.Int != 0 {
+		dict1.Set("Int", plist.NewInt(int64(t.Int)))
+	}
+	if t.Uint != 0 {
+		dict1.Set("Uint", plist.NewUint(uint64(t.Uint)))
+	}
+	if t.Float != 0 {
+		dict1.Set("Float", plist.NewReal32(t.Float))
+	}
+	if t.Bool {
+		dict1.Set("Bool", plist.NewBool(t.Bool))
+	}
+	if len(t.Data) != 0 {
+		dict1.Set("Data", plist.NewData(t.Data))
+	}
+	if len(t.Slice) != 0 {
+		a2 := plist.MakeArray(len(t.Slice))
+		for i3 := range t.Slice {
+			a2.SetIndex(i3, plist.NewString(t.Slice[i3]))
+		}
+		dict1.Set("Slice", a2)
+	}
+	if len(t.Map) != 0 {
+		dict4 := plist.NewMapDict(len(t.Map))
+		for k5, e6 := range t.Map {
+			dict4.Set(k5, plist.NewString(e6))
+		}
+		dict1.Set("Map", dict4)
+	}
+	if len(t.Array) != 0 {
+		a7 := plist.MakeArray(len(t.Array))
+		for i8 := range t.Array {
+			a7.SetIndex(i8, plist.NewInt(int64(t.Array[i8])))
+		}
+		dict1.Set("Array", a7)
+	}
+	if t.Pointer != nil {
+		v9, err := plist.MarshalValue(&t.Pointer)
+		if err != nil {
+			return nil, plist.PrependPath(err, "Pointer")
+		}
+		dict1.Set("Pointer", v9)
+	}
+	if t.Interface != nil {
+		v10, err := plist.MarshalInterface(t.Interface)
+		if err != nil {
+			return nil, plist.PrependPath(err, "Interface")
+		}
+		dict1.Set("Interface", v10)
+	}
+	if t.Point != (Point{}) {
+		dict11 := plist.NewStructDict(2)
+		dict11.Set("X", plist.NewInt(int64(t.Point.X)))
+		dict11.Set("Y", plist.NewInt(int64(t.Point.Y)))
+		dict1.Set("Point", dict11)
+	}
+	if !reflect.DeepEqual(t.Tags, Tags{}) {
+		dict12 := plist.NewStructDict(1)
+		a13 := plist.MakeArray(len(t.Tags.List))
+		for i14 := range t.Tags.List {
+			a13.SetIndex(i14, plist.NewString(t.Tags.List[i14]))
+		}
+		dict12.Set("List", a13)
+		dict1.Set("Tags", dict12)
+	}
+	if t.Date != (time.Time{}) {
+		dict1.Set("Date", plist.NewDate(t.Date))
+	}
+	if len(t.Upper) != 0 {
+		v15, err := plist.MarshalValue(&t.Upper)
+		if err != nil {
+			return nil, plist.PrependPath(err, "Upper")
+		}
+		dict1.Set("Upper", v15)
+	}
+	dict1.Set("Kept", plist.NewInt(int64(t.Kept)))
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *Omit) UnmarshalPlist(f func(interface{}) error) error {
+	var d plist.ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != plist.Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("String", "Int", "Uint", "Float", "Bool", "Data", "Slice", "Map", "Array", "Pointer", "Interface", "Point", "Tags", "Date", "Upper", "Kept"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("String"); ok {
+		if x, ok := v1.AsString(); ok {
+			t.String = x
+		} else if err := v1.Decode(&t.String); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("Int"); ok {
+		if x, ok := v2.AsInt(); ok {
+			t.Int = int8(x)
+		} else if err := v2.Decode(&t.Int); err != nil {
+			return err
+		}
+	}
+	if v3, ok := d.Key("Uint"); ok {
+		if x, ok := v3.AsUint(); ok {
+			t.Uint = uint16(x)
+		} else if err := v3.Decode(&t.Uint); err != nil {
+			return err
+		}
+	}
+	if v4, ok := d.Key("Float"); ok {
+		if x, ok := v4.AsReal(); ok {
+			t.Float = float32(x)
+		} else if err := v4.Decode(&t.Float); err != nil {
+			return err
+		}
+	}
+	if v5, ok := d.Key("Bool"); ok {
+		if x, ok := v5.AsBool(); ok {
+			t.Bool = x
+		} else if err := v5.Decode(&t.Bool); err != nil {
+			return err
+		}
+	}
+	if v6, ok := d.Key("Data"); ok {
+		if x, ok := v6.AsData(); ok {
+			t.Data = x
+		} else if err := v6.Decode(&t.Data); err != nil {
+			return err
+		}
+	}
+	if v7, ok := d.Key("Slice"); ok {
+		if v7.Kind() == plist.Array {
+			n8 := v7.Len()
+			if n8 >= cap(t.Slice) {
+				c := 2 * n8
+				if c < 4 {
+					c = 4
+				}
+				s := make([]string, len(t.Slice), c)
+				copy(s, t.Slice)
+				t.Slice = s
+			}
+			t.Slice = t.Slice[:n8]
+			for i9 := 0; i9 < n8; i9++ {
+				e10 := v7.Index(i9)
+				if x, ok := e10.AsString(); ok {
+					t.Slice[i9] = x
+				} else if err := e10.Decode(&t.Slice[i9]); err != nil {
+					return err
+				}
+			}
+		} else if err := v7.Decode(&t.Slice); err != nil {
+			return err
+		}
+	}
+	if v11, ok := d.Key("Map"); ok {
+		if err := v11.Decode(&t.Map); err != nil {
+			return err
+		}
+	}
+	if v12, ok := d.Key("Array"); ok {
+		if err := v12.Decode(&t.Array); err != nil {
+			return err
+		}
+	}
+	if v13, ok := d.Key("Pointer"); ok {
+		if err := v13.Decode(&t.Pointer); err != nil {
+			return err
+		}
+	}
+	if v14, ok := d.Key("Interface"); ok {
+		if err := v14.Decode(&t.Interface); err != nil {
+			return err
+		}
+	}
+	if v15, ok := d.Key("Point"); ok {
+		if v15.Kind() == plist.Dictionary {
+			if err := v15.CheckFields("X", "Y"); err != nil {
+				return err
+			}
+			if v16, ok := v15.Key("X"); ok {
+				if x, ok := v16.AsInt(); ok {
+					t.Point.X = int(x)
+				} else if err := v16.Decode(&t.Point.X); err != nil {
+					return err
+				}
+			}
+			if v17, ok := v15.Key("Y"); ok {
+				if x, ok := v17.AsInt(); ok {
+					t.Point.Y = int(x)
+				} else if err := v17.Decode(&t.Point.Y); err != nil {
+					return err
+				}
+			}
+		} else if err := v15.Decode(&t.Point); err != nil {
+			return err
+		}
+	}
+	if v18, ok := d.Key("Tags"); ok {
+		if v18.Kind() == plist.Dictionary {
+			if err := v18.CheckFields("List"); err != nil {
+				return err
+			}
+			if v19, ok := v18.Key("List"); ok {
+				if v19.Kind() == plist.Array {
+					n20 := v19.Len()
+					if n20 >= cap(t.Tags.List) {
+						c := 2 * n20
+						if c < 4 {
+							c = 4
+						}
+						s := make([]string, len(t.Tags.List), c)
+						copy(s, t.Tags.List)
+						t.Tags.List = s
+					}
+					t.Tags.List = t.Tags.List[:n20]
+					for i21 := 0; i21 < n20; i21++ {
+						e22 := v19.Index(i21)
+						if x, ok := e22.AsString(); ok {
+							t.Tags.List[i21] = x
+						} else if err := e22.Decode(&t.Tags.List[i21]); err != nil {
+							return err
+						}
+					}
+				} else if err := v19.Decode(&t.Tags.List); err != nil {
+					return err
+				}
+			}
+		} else if err := v18.Decode(&t.Tags); err != nil {
+			return err
+		}
+	}
+	if v23, ok := d.Key("Date"); ok {
+		if x, ok := v23.AsDate(); ok {
+			t.Date = x
+		} else if err := v23.Decode(&t.Date); err != nil {
+			return err
+		}
+	}
+	if v24, ok := d.Key("Upper"); ok {
+		if err := v24.Decode(&t.Upper); err != nil {
+			return err
+		}
+	}
+	if v25, ok := d.Key("Kept"); ok {
+		if x, ok := v25.AsInt(); ok {
+			t.Kept = int(x)
+		} else if err := v25.Decode(&t.Kept); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *Named) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return plist.NewNull(), nil
+	}
+	dict1 := plist.NewStructDict(10)
+	dict1.Set("Celsius", plist.NewReal32(float32(t.Celsius)))
+	dict1.Set("Level", plist.NewInt(int64(t.Level)))
+	a2 := plist.MakeArray(len(t.Labels))
+	for i3 := range t.Labels {
+		a2.SetIndex(i3, plist.NewString(string(t.Labels[i3])))
+	}
+	dict1.Set("Labels", a2)
+	dict1.Set("Blob", plist.NewData([]byte(t.Blob)))
+	a4 := plist.MakeArray(len(t.Flags))
+	for i5 := range t.Flags {
+		a4.SetIndex(i5, plist.NewBool(bool(t.Flags[i5])))
+	}
+	dict1.Set("Flags", a4)
+	v6, err := plist.MarshalValue(&t.Codes)
+	if err != nil {
+		return nil, plist.PrependPath(err, "Codes")
+	}
+	dict1.Set("Codes", v6)
+	a7 := plist.MakeArray(len(t.Uppers))
+	for i8 := range t.Uppers {
+		v9, err := plist.MarshalValue(&t.Uppers[i8])
+		if err != nil {
+			return nil, plist.PrependPath(plist.PrependPath(err, "["+strconv.Itoa(i8)+"]"), "Uppers")
+		}
+		a7.SetIndex(i8, v9)
+	}
+	dict1.Set("Uppers", a7)
+	a10 := plist.MakeArray(len(t.Points))
+	for i11 := range t.Points {
+		var v12 *plist.Value
+		if t.Points[i11] == nil {
+			v12 = plist.NewNull()
+		} else {
+			dict13 := plist.NewStructDict(2)
+			dict13.Set("X", plist.NewInt(int64((*t.Points[i11]).X)))
+			dict13.Set("Y", plist.NewInt(int64((*t.Points[i11]).Y)))
+			v12 = dict13
+		}
+		a10.SetIndex(i11, v12)
+	}
+	dict1.Set("Points", a10)
+	a14 := plist.MakeArray(len(t.Nodes))
+	for i15 := range t.Nodes {
+		m16, err := t.Nodes[i15].MarshalPlist()
+		if err != nil {
+			return nil, plist.PrependPath(plist.PrependPath(err, "["+strconv.Itoa(i15)+"]"), "Nodes")
+		}
+		a14.SetIndex(i15, m16.(*plist.Value))
+	}
+	dict1.Set("Nodes", a14)
+	a17 := plist.MakeArray(len(t.Bad))
+	for i18 := range t.Bad {
+		v19, err := plist.MarshalInterface(t.Bad[i18])
+		if err != nil {
+			return nil, plist.PrependPath(plist.PrependPath(err, "["+strconv.Itoa(i18)+"]"), "Bad")
+		}
+		a17.SetIndex(i18, v19)
+	}
+	dict1.Set("Bad", a17)
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *Named) UnmarshalPlist(f func(interface{}) error) error {
+	var d plist.ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != plist.Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("Celsius", "Level", "Labels", "Blob", "Flags", "Codes", "Uppers", "Points", "Nodes", "Bad"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("Celsius"); ok {
+		if x, ok := v1.AsReal(); ok {
+			t.Celsius = Celsius(x)
+		} else if err := v1.Decode(&t.Celsius); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("Level"); ok {
+		if x, ok := v2.AsInt(); ok {
+			t.Level = Level(x)
+		} else if err := v2.Decode(&t.Level); err != nil {
+			return err
+		}
+	}
+	if v3, ok := d.Key("Labels"); ok {
+		if v3.Kind() == plist.Array {
+			n4 := v3.Len()
+			if n4 >= cap(t.Labels) {
+				c := 2 * n4
+				if c < 4 {
+					c = 4
+				}
+				s := make([]Label, len(t.Labels), c)
+				copy(s, t.Labels)
+				t.Labels = s
+			}
+			t.Labels = t.Labels[:n4]
+			for i5 := 0; i5 < n4; i5++ {
+				e6 := v3.Index(i5)
+				if x, ok := e6.AsString(); ok {
+					t.Labels[i5] = Label(x)
+				} else if err := e6.Decode(&t.Labels[i5]); err != nil {
+					return err
+				}
+			}
+		} else if err := v3.Decode(&t.Labels); err != nil {
+			return err
+		}
+	}
+	if v7, ok := d.Key("Blob"); ok {
+		if x, ok := v7.AsData(); ok {
+			t.Blob = Blob(x)
+		} else if err := v7.Decode(&t.Blob); err != nil {
+			return err
+		}
+	}
+	if v8, ok := d.Key("Flags"); ok {
+		if v8.Kind() == plist.Array {
+			n9 := v8.Len()
+			if n9 >= cap(t.Flags) {
+				c := 2 * n9
+				if c < 4 {
+					c = 4
+				}
+				s := make([]Flag, len(t.Flags), c)
+				copy(s, t.Flags)
+				t.Flags = s
+			}
+			t.Flags = t.Flags[:n9]
+			for i10 := 0; i10 < n9; i10++ {
+				e11 := v8.Index(i10)
+				if x, ok := e11.AsBool(); ok {
+					t.Flags[i10] = Flag(x)
+				} else if err := e11.Decode(&t.Flags[i10]); err != nil {
+					return err
+				}
+			}
+		} else if err := v8.Decode(&t.Flags); err != nil {
+			return err
+		}
+	}
+	if v12, ok := d.Key("Codes"); ok {
+		if v12.Kind() == plist.Array {
+			n13 := v12.Len()
+			if n13 >= cap(t.Codes) {
+				c := 2 * n13
+				if c < 4 {
+					c = 4
+				}
+				s := make(Codes, len(t.Codes), c)
+				copy(s, t.Codes)
+				t.Codes = s
+			}
+			t.Codes = t.Codes[:n13]
+			for i14 := 0; i14 < n13; i14++ {
+				e15 := v12.Index(i14)
+				if x, ok := e15.AsUint(); ok {
+					t.Codes[i14] = Code(x)
+				} else if err := e15.Decode(&t.Codes[i14]); err != nil {
+					return err
+				}
+			}
+		} else if err := v12.Decode(&t.Codes); err != nil {
+			return err
+		}
+	}
+	if v16, ok := d.Key("Uppers"); ok {
+		if v16.Kind() == plist.Array {
+			n17 := v16.Len()
+			if n17 >= cap(t.Uppers) {
+				c := 2 * n17
+				if c < 4 {
+					c = 4
+				}
+				s := make([]Upper, len(t.Uppers), c)
+				copy(s, t.Uppers)
+				t.Uppers = s
+			}
+			t.Uppers = t.Uppers[:n17]
+			for i18 := 0; i18 < n17; i18++ {
+				e19 := v16.Index(i18)
+				if err := e19.Decode(&t.Uppers[i18]); err != nil {
+					return err
+				}
+			}
+		} else if err := v16.Decode(&t.Uppers); err != nil {
+			return err
+		}
+	}
+	if v20, ok := d.Key("Points"); ok {
+		if v20.Kind() == plist.Array {
+			n21 := v20.Len()
+			if n21 >= cap(t.Points) {
+				c := 2 * n21
+				if c < 4 {
+					c = 4
+				}
+				s := make([]*Point, len(t.Points), c)
+				copy(s, t.Points)
+				t.Points = s
+			}
+			t.Points = t.Points[:n21]
+			for i22 := 0; i22 < n21; i22++ {
+				e23 := v20.Index(i22)
+				if e23.Kind() == plist.Dictionary {
+					if t.Points[i22] == nil {
+						t.Points[i22] = new(Point)
+					}
+					if err := e23.CheckFields("X", "Y"); err != nil {
+						return err
+					}
+					if v24, ok := e23.Key("X"); ok {
+						if x, ok := v24.AsInt(); ok {
+							t.Points[i22].X = int(x)
+						} else if err := v24.Decode(&t.Points[i22].X); err != nil {
+							return err
+						}
+					}
+					if v25, ok := e23.Key("Y"); ok {
+						if x, ok := v25.AsInt(); ok {
+							t.Points[i22].Y = int(x)
+						} else if err := v25.Decode(&t.Points[i22].Y); err != nil {
+							return err
+						}
+					}
+				} else if err := e23.Decode(&t.Points[i22]); err != nil {
+					return err
+				}
+			}
+		} else if err := v20.Decode(&t.Points); err != nil {
+			return err
+		}
+	}
+	if v26, ok := d.Key("Nodes"); ok {
+		if v26.Kind() == plist.Array {
+			n27 := v26.Len()
+			if n27 >= cap(t.Nodes) {
+				c := 2 * n27
+				if c < 4 {
+					c = 4
+				}
+				s := make([]*Node, len(t.Nodes), c)
+				copy(s, t.Nodes)
+				t.Nodes = s
+			}
+			t.Nodes = t.Nodes[:n27]
+			for i28 := 0; i28 < n27; i28++ {
+				e29 := v26.Index(i28)
+				if e29.Kind() == plist.Dictionary {
+					if t.Nodes[i28] == nil {
+						t.Nodes[i28] = new(Node)
+					}
+					if err := e29.CheckFields("Name", "Children", "Next"); err != nil {
+						return err
+					}
+					if v30, ok := e29.Key("Name"); ok {
+						if x, ok := v30.AsString(); ok {
+							t.Nodes[i28].Name = x
+						} else if err := v30.Decode(&t.Nodes[i28].Name); err != nil {
+							return err
+						}
+					}
+					if v31, ok := e29.Key("Children"); ok {
+						if v31.Kind() == plist.Array {
+							n32 := v31.Len()
+							if n32 >= cap(t.Nodes[i28].Children) {
+								c := 2 * n32
+								if c < 4 {
+									c = 4
+								}
+								s := make([]Node, len(t.Nodes[i28].Children), c)
+								copy(s, t.Nodes[i28].Children)
+								t.Nodes[i28].Children = s
+							}
+							t.Nodes[i28].Children = t.Nodes[i28].Children[:n32]
+							for i33 := 0; i33 < n32; i33++ {
+								e34 := v31.Index(i33)
+								if err := e34.Decode(&t.Nodes[i28].Children[i33]); err != nil {
+									return err
+								}
+							}
+						} else if err := v31.Decode(&t.Nodes[i28].Children); err != nil {
+							return err
+						}
+					}
+					if v35, ok := e29.Key("Next"); ok {
+						if err := v35.Decode(&t.Nodes[i28].Next); err != nil {
+							return err
+						}
+					}
+				} else if err := e29.Decode(&t.Nodes[i28]); err != nil {
+					return err
+				}
+			}
+		} else if err := v26.Decode(&t.Nodes); err != nil {
+			return err
+		}
+	}
+	if v36, ok := d.Key("Bad"); ok {
+		if v36.Kind() == plist.Array {
+			n37 := v36.Len()
+			if n37 >= cap(t.Bad) {
+				c := 2 * n37
+				if c < 4 {
+					c = 4
+				}
+				s := make([]interface{}, len(t.Bad), c)
+				copy(s, t.Bad)
+				t.Bad = s
+			}
+			t.Bad = t.Bad[:n37]
+			for i38 := 0; i38 < n37; i38++ {
+				e39 := v36.Index(i38)
+				if err := e39.Decode(&t.Bad[i38]); err != nil {
+					return err
+				}
+			}
+		} else if err := v36.Decode(&t.Bad); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *Command) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return plist.NewNull(), nil
+	}
+	dict1 := plist.NewStructDict(3)
+	dict1.Set("CommandUUID", plist.NewString(t.CommandUUID))
+	dict2 := plist.NewStructDict(5)
+	dict2.Set("RequestType", plist.NewString(t.Command.RequestType))
+	a3 := plist.MakeArray(len(t.Command.Queries))
+	for i4 := range t.Command.Queries {
+		a3.SetIndex(i4, plist.NewString(t.Command.Queries[i4]))
+	}
+	dict2.Set("Queries", a3)
+	if len(t.Command.Identifier) != 0 {
+		dict2.Set("Identifier", plist.NewString(t.Command.Identifier))
+	}
+	if len(t.Command.Payload) != 0 {
+		dict2.Set("Payload", plist.NewData(t.Command.Payload))
+	}
+	a5 := plist.MakeArray(len(t.Command.Settings))
+	for i6 := range t.Command.Settings {
+		m7, err := t.Command.Settings[i6].MarshalPlist()
+		if err != nil {
+			return nil, plist.PrependPath(plist.PrependPath(plist.PrependPath(err, "["+strconv.Itoa(i6)+"]"), "Settings"), "Command")
+		}
+		a5.SetIndex(i6, m7.(*plist.Value))
+	}
+	dict2.Set("Settings", a5)
+	dict1.Set("Command", dict2)
+	dict8 := plist.NewMapDict(len(t.Meta))
+	for k9, e10 := range t.Meta {
+		v11, err := plist.MarshalInterface(e10)
+		if err != nil {
+			return nil, plist.PrependPath(plist.PrependPath(err, k9), "Meta")
+		}
+		dict8.Set(k9, v11)
+	}
+	dict1.Set("Meta", dict8)
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *Command) UnmarshalPlist(f func(interface{}) error) error {
+	var d plist.ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != plist.Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("CommandUUID", "Command", "Meta"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("CommandUUID"); ok {
+		if x, ok := v1.AsString(); ok {
+			t.CommandUUID = x
+		} else if err := v1.Decode(&t.CommandUUID); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("Command"); ok {
+		if v2.Kind() == plist.Dictionary {
+			if err := v2.CheckFields("RequestType", "Queries", "Identifier", "Payload", "Settings"); err != nil {
+				return err
+			}
+			if v3, ok := v2.Key("RequestType"); ok {
+				if x, ok := v3.AsString(); ok {
+					t.Command.RequestType = x
+				} else if err := v3.Decode(&t.Command.RequestType); err != nil {
+					return err
+				}
+			}
+			if v4, ok := v2.Key("Queries"); ok {
+				if v4.Kind() == plist.Array {
+					n5 := v4.Len()
+					if n5 >= cap(t.Command.Queries) {
+						c := 2 * n5
+						if c < 4 {
+							c = 4
+						}
+						s := make([]string, len(t.Command.Queries), c)
+						copy(s, t.Command.Queries)
+						t.Command.Queries = s
+					}
+					t.Command.Queries = t.Command.Queries[:n5]
+					for i6 := 0; i6 < n5; i6++ {
+						e7 := v4.Index(i6)
+						if x, ok := e7.AsString(); ok {
+							t.Command.Queries[i6] = x
+						} else if err := e7.Decode(&t.Command.Queries[i6]); err != nil {
+							return err
+						}
+					}
+				} else if err := v4.Decode(&t.Command.Queries); err != nil {
+					return err
+				}
+			}
+			if v8, ok := v2.Key("Identifier"); ok {
+				if x, ok := v8.AsString(); ok {
+					t.Command.Identifier = x
+				} else if err := v8.Decode(&t.Command.Identifier); err != nil {
+					return err
+				}
+			}
+			if v9, ok := v2.Key("Payload"); ok {
+				if x, ok := v9.AsData(); ok {
+					t.Command.Payload = x
+				} else if err := v9.Decode(&t.Command.Payload); err != nil {
+					return err
+				}
+			}
+			if v10, ok := v2.Key("Settings"); ok {
+				if v10.Kind() == plist.Array {
+					n11 := v10.Len()
+					if n11 >= cap(t.Command.Settings) {
+						c := 2 * n11
+						if c < 4 {
+							c = 4
+						}
+						s := make([]Setting, len(t.Command.Settings), c)
+						copy(s, t.Command.Settings)
+						t.Command.Settings = s
+					}
+					t.Command.Settings = t.Command.Settings[:n11]
+					for i12 := 0; i12 < n11; i12++ {
+						e13 := v10.Index(i12)
+						if e13.Kind() == plist.Dictionary {
+							if err := e13.CheckFields("Item", "Enabled", "Count"); err != nil {
+								return err
+							}
+							if v14, ok := e13.Key("Item"); ok {
+								if x, ok := v14.AsString(); ok {
+									t.Command.Settings[i12].Item = x
+								} else if err := v14.Decode(&t.Command.Settings[i12].Item); err != nil {
+									return err
+								}
+							}
+							if v15, ok := e13.Key("Enabled"); ok {
+								if x, ok := v15.AsBool(); ok {
+									t.Command.Settings[i12].Enabled = x
+								} else if err := v15.Decode(&t.Command.Settings[i12].Enabled); err != nil {
+									return err
+								}
+							}
+							if v16, ok := e13.Key("Count"); ok {
+								if x, ok := v16.AsInt(); ok {
+									t.Command.Settings[i12].Count = int(x)
+								} else if err := v16.Decode(&t.Command.Settings[i12].Count); err != nil {
+									return err
+								}
+							}
+						} else if err := e13.Decode(&t.Command.Settings[i12]); err != nil {
+							return err
+						}
+					}
+				} else if err := v10.Decode(&t.Command.Settings); err != nil {
+					return err
+				}
+			}
+		} else if err := v2.Decode(&t.Command); err != nil {
+			return err
+		}
+	}
+	if v17, ok := d.Key("Meta"); ok {
+		if err := v17.Decode(&t.Meta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *Setting) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return plist.NewNull(), nil
+	}
+	dict1 := plist.NewStructDict(3)
+	dict1.Set("Item", plist.NewString(t.Item))
+	dict1.Set("Enabled", plist.NewBool(t.Enabled))
+	dict1.Set("Count", plist.NewInt(int64(t.Count)))
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *Setting) UnmarshalPlist(f func(interface{}) error) error {
+	var d plist.ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != plist.Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("Item", "Enabled", "Count"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("Item"); ok {
+		if x, ok := v1.AsString(); ok {
+			t.Item = x
+		} else if err := v1.Decode(&t.Item); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("Enabled"); ok {
+		if x, ok := v2.AsBool(); ok {
+			t.Enabled = x
+		} else if err := v2.Decode(&t.Enabled); err != nil {
+			return err
+		}
+	}
+	if v3, ok := d.Key("Count"); ok {
+		if x, ok := v3.AsInt(); ok {
+			t.Count = int(x)
+		} else if err := v3.Decode(&t.Count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *Maps) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return plist.NewNull(), nil
+	}
+	dict1 := plist.NewStructDict(5)
+	dict2 := plist.NewMapDict(len(t.Nodes))
+	for k3, e4 := range t.Nodes {
+		dict5 := plist.NewStructDict(3)
+		dict5.Set("Name", plist.NewString(e4.Name))
+		if len(e4.Children) != 0 {
+			a6 := plist.MakeArray(len(e4.Children))
+			for i7 := range e4.Children {
+				m8, err := e4.Children[i7].MarshalPlist()
+				if err != nil {
+					return nil, plist.PrependPath(plist.PrependPath(plist.PrependPath(plist.PrependPath(err, "["+strconv.Itoa(i7)+"]"), "Children"), k3), "Nodes")
+				}
+				a6.SetIndex(i7, m8.(*plist.Value))
+			}
+			dict5.Set("Children", a6)
+		}
+		if e4.Next != nil {
+			m9, err := e4.Next.MarshalPlist()
+			if err != nil {
+				return nil, plist.PrependPath(plist.PrependPath(plist.PrependPath(err, "Next"), k3), "Nodes")
+			}
+			dict5.Set("Next", m9.(*plist.Value))
+		}
+		dict2.Set(k3, dict5)
+	}
+	dict1.Set("Nodes", dict2)
+	dict10 := plist.NewMapDict(len(t.Points))
+	for k11, e12 := range t.Points {
+		var v13 *plist.Value
+		if e12 == nil {
+			v13 = plist.NewNull()
+		} else {
+			dict14 := plist.NewStructDict(2)
+			dict14.Set("X", plist.NewInt(int64((*e12).X)))
+			dict14.Set("Y", plist.NewInt(int64((*e12).Y)))
+			v13 = dict14
+		}
+		dict10.Set(string(k11), v13)
+	}
+	dict1.Set("Points", dict10)
+	dict15 := plist.NewMapDict(len(t.Uppers))
+	for k16, e17 := range t.Uppers {
+		v18, err := plist.MarshalMapValue(e17)
+		if err != nil {
+			return nil, plist.PrependPath(plist.PrependPath(err, k16), "Uppers")
+		}
+		dict15.Set(k16, v18)
+	}
+	dict1.Set("Uppers", dict15)
+	dict19 := plist.NewMapDict(len(t.Tenths))
+	for k20, e21 := range t.Tenths {
+		v22, err := plist.MarshalMapValue(e21)
+		if err != nil {
+			return nil, plist.PrependPath(plist.PrependPath(err, k20), "Tenths")
+		}
+		dict19.Set(k20, v22)
+	}
+	dict1.Set("Tenths", dict19)
+	v23, err := plist.MarshalValue(&t.Tenth)
+	if err != nil {
+		return nil, plist.PrependPath(err, "Tenth")
+	}
+	dict1.Set("Tenth", v23)
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *Maps) UnmarshalPlist(f func(interface{}) error) error {
+	var d plist.ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != plist.Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("Nodes", "Points", "Uppers", "Tenths", "Tenth"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("Nodes"); ok {
+		if err := v1.Decode(&t.Nodes); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("Points"); ok {
+		if err := v2.Decode(&t.Points); err != nil {
+			return err
+		}
+	}
+	if v3, ok := d.Key("Uppers"); ok {
+		if err := v3.Decode(&t.Uppers); err != nil {
+			return err
+		}
+	}
+	if v4, ok := d.Key("Tenths"); ok {
+		if err := v4.Decode(&t.Tenths); err != nil {
+			return err
+		}
+	}
+	if v5, ok := d.Key("Tenth"); ok {
+		if x, ok := v5.AsInt(); ok {
+			t.Tenth = Tenths(x)
+		} else if err := v5.Decode(&t.Tenth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *Arrays) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return plist.NewNull(), nil
+	}
+	dict1 := plist.NewStructDict(2)
+	a2 := plist.MakeArray(len(t.Fixed))
+	for i3 := range t.Fixed {
+		dict4 := plist.NewStructDict(2)
+		dict4.Set("X", plist.NewInt(int64(t.Fixed[i3].X)))
+		dict4.Set("Y", plist.NewInt(int64(t.Fixed[i3].Y)))
+		a2.SetIndex(i3, dict4)
+	}
+	dict1.Set("Fixed", a2)
+	dict5 := plist.NewMapDict(len(t.Map))
+	for k6, e7 := range t.Map {
+		a8 := plist.MakeArray(len(e7))
+		for i9 := range e7 {
+			dict10 := plist.NewStructDict(2)
+			dict10.Set("X", plist.NewInt(int64(e7[i9].X)))
+			dict10.Set("Y", plist.NewInt(int64(e7[i9].Y)))
+			a8.SetIndex(i9, dict10)
+		}
+		dict5.Set(k6, a8)
+	}
+	dict1.Set("Map", dict5)
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *Arrays) UnmarshalPlist(f func(interface{}) error) error {
+	var d plist.ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != plist.Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("Fixed", "Map"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("Fixed"); ok {
+		if err := v1.Decode(&t.Fixed); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("Map"); ok {
+		if err := v2.Decode(&t.Map); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package gentest
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/groob/plist"
+)
+
+// The plain types have the fields of the generated types, without their
+// methods, so the Encoder and Decoder handle them with reflection.
+type (
+	plainHeader  Header
+	plainSample  Sample
+	plainSets    Sets
+	plainNode    Node
+	plainOuter   Outer
+	plainOmit    Omit
+	plainNamed   Named
+	plainCommand Command
+	plainMaps    Maps
+	plainArrays  Arrays
+)
+
+type pair struct {
+	name  string
+	gen   interface{} // pointer to a generated type
+	plain interface{} // the same pointer as a plain type
+}
+
+func pairs() []pair {
+	header := &Header{
+		InfoDictionaryVersion: "6.0",
+		BandSize:              8388608,
+		Size:                  4 * 1048576 * 1024 * 1024,
+		DiskImageBundleType:   "com.apple.diskimage.sparsebundle",
+		BackingStoreVersion:   1,
+		Unused:                Unused{UnusedString: "unused"},
+	}
+	sample := &Sample{
+		Strings:  []string{"short", "こんにちは世界", "short"},
+		Ints:     []int64{0, 42, -42, -9223372036854775808, 9223372036854775807},
+		Uint64:   ^uint64(0),
+		Float32:  3.25,
+		Float64:  -1234.5678,
+		Bool:     true,
+		Data:     []byte("data"),
+		Date:     time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Nested:   map[string]string{"a": "b"},
+		Repeated: []map[string]int64{{"x": 1}, {"y": -2}},
+		Matrix:   [][]int{{1, 2}, nil, {3}},
+	}
+	s := "null"
+	sets := &Sets{
+		Set:        plist.Set{"a", "b"},
+		OrderedSet: plist.OrderedSet{int64(2), int64(1)},
+		UID:        7,
+		Null:       &s,
+		Any:        []interface{}{"any", 1.5},
+		Value:      plist.NewArray(plist.NewString("value")),
+	}
+	node := &Node{
+		Name:     "root",
+		Children: []Node{{Name: "a"}, {Name: "b", Next: &Node{Name: "c"}}},
+	}
+	outer := &Outer{
+		Base:    Base{ID: "id", Shade: "red", Extra: "base"},
+		Inner:   Inner{Deep: Deep{Depth: 2, Leaf: true}, Count: 3},
+		Name:    "outer",
+		Skipped: "skipped",
+		Dash:    "dash",
+	}
+	omitAll := &Omit{}
+	n := 1
+	omitNone := &Omit{
+		String:    "s",
+		Int:       -1,
+		Uint:      1,
+		Float:     0.5,
+		Bool:      true,
+		Data:      []byte{0},
+		Slice:     []string{""},
+		Map:       map[string]string{"": ""},
+		Pointer:   &n,
+		Interface: "i",
+		Point:     Point{Y: 1},
+		Tags:      Tags{List: []string{}},
+		Date:      time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC),
+		Upper:     "upper",
+		Kept:      0,
+	}
+	named := &Named{
+		Celsius: -40.5,
+		Level:   -3,
+		Labels:  []Label{"x", "y"},
+		Blob:    Blob("blob"),
+		Flags:   []Flag{true, false},
+		Codes:   Codes{1, 2},
+		Uppers:  []Upper{"a", "b"},
+		Points:  []*Point{{1, 2}, nil},
+		Nodes:   []*Node{{Name: "n"}, nil},
+		Bad:     []interface{}{"fine"},
+	}
+	command := &Command{CommandUUID: "0001-0002-0003"}
+	command.Command.RequestType = "DeviceInformation"
+	command.Command.Queries = []string{"UDID", "DeviceName"}
+	command.Command.Settings = []Setting{{Item: "Setting", Enabled: true, Count: 2}}
+	command.Meta = map[string]interface{}{"Attempt": 1, "Source": "test"}
+
+	maps := &Maps{
+		Nodes:  map[string]Node{"a": {Name: "a", Next: &Node{Name: "b"}}, "c": {}},
+		Points: map[Label]*Point{"p": {1, 2}, "nil": nil},
+		Uppers: map[string]Upper{"u": "upper"},
+		Tenths: map[string]Tenths{"one": 1},
+		Tenth:  2,
+	}
+	arrays := &Arrays{
+		Fixed: [2]Point{{5, 6}},
+		Map:   map[string][2]Point{"a": {{1, 2}, {3, 4}}},
+	}
+
+	return []pair{
+		{"header", header, (*plainHeader)(header)},
+		{"sample", sample, (*plainSample)(sample)},
+		{"sets", sets, (*plainSets)(sets)},
+		{"node", node, (*plainNode)(node)},
+		{"outer", outer, (*plainOuter)(outer)},
+		{"omit all", omitAll, (*plainOmit)(omitAll)},
+		{"omit none", omitNone, (*plainOmit)(omitNone)},
+		{"named", named, (*plainNamed)(named)},
+		{"command", command, (*plainCommand)(command)},
+		{"maps", maps, (*plainMaps)(maps)},
+		{"arrays", arrays, (*plainArrays)(arrays)},
+	}
+}
+
+var encoders = []struct {
+	name string
+	new  func(*bytes.Buffer) *plist.Encoder
+}{
+	{"xml", func(b *bytes.Buffer) *plist.Encoder {
+		enc := plist.NewEncoder(b)
+		enc.Indent("  ")
+		return enc
+	}},
+	{"xml in declaration order", func(b *bytes.Buffer) *plist.Encoder {
+		enc := plist.NewEncoder(b)
+		enc.SetKeyOrder(plist.DeclarationOrder)
+		return enc
+	}},
+	{"xml in insertion order", func(b *bytes.Buffer) *plist.Encoder {
+		enc := plist.NewEncoder(b)
+		enc.SetKeyOrder(plist.InsertionOrder)
+		return enc
+	}},
+	{"binary", func(b *bytes.Buffer) *plist.Encoder { return plist.NewBinaryEncoder(b) }},
+	{"openstep", func(b *bytes.Buffer) *plist.Encoder { return plist.NewOpenStepEncoder(b) }},
+	{"gnustep", func(b *bytes.Buffer) *plist.Encoder { return plist.NewGNUStepEncoder(b) }},
+	{"json", func(b *bytes.Buffer) *plist.Encoder { return plist.NewJSONEncoder(b) }},
+	{"typed json", func(b *bytes.Buffer) *plist.Encoder { return plist.NewTypedJSONEncoder(b) }},
+}
+
+func encode(new func(*bytes.Buffer) *plist.Encoder, v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := new(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func TestMarshal(t *testing.T) {
+	for _, p := range pairs() {
+		for _, enc := range encoders {
+			have, haveErr := encode(enc.new, p.gen)
+			want, wantErr := encode(enc.new, p.plain)
+			if (haveErr == nil) != (wantErr == nil) || haveErr != nil && haveErr.Error() != wantErr.Error() {
+				t.Errorf("%s, %s: error %v, want %v", p.name, enc.name, haveErr, wantErr)
+				continue
+			}
+			if !bytes.Equal(have, want) {
+				t.Errorf("%s, %s:\n%s\nwant\n%s", p.name, enc.name, have, want)
+			}
+		}
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	named := &Named{Nodes: []*Node{{Name: "a", Next: &Node{}}}, Bad: []interface{}{"fine", complex(1, 2)}}
+	node := &Node{Name: "a", Children: []Node{{}, {Next: &Node{Children: []Node{{Name: "b"}}}}}}
+	sample := &Sample{Repeated: []map[string]int64{nil}, Nested: map[string]string{}}
+	for _, p := range []pair{
+		{"named", named, (*plainNamed)(named)},
+		{"node", node, (*plainNode)(node)},
+		{"sample", sample, (*plainSample)(sample)},
+	} {
+		_, haveErr := plist.Marshal(p.gen)
+		_, wantErr := plist.Marshal(p.plain)
+		if (haveErr == nil) != (wantErr == nil) || haveErr != nil && haveErr.Error() != wantErr.Error() {
+			t.Errorf("%s: error %v, want %v", p.name, haveErr, wantErr)
+		}
+	}
+	_, err := plist.Marshal(named)
+	if err == nil || !strings.Contains(err.Error(), "Bad[1]") {
+		t.Errorf("error %v, want the path Bad[1]", err)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	for _, p := range pairs() {
+		for _, enc := range encoders {
+			data, err := encode(enc.new, p.plain)
+			if err != nil {
+				continue
+			}
+			gen := reflect.New(reflect.TypeOf(p.gen).Elem())
+			plain := reflect.New(reflect.TypeOf(p.plain).Elem())
+			// UIDs don't survive text and JSON plists.
+			haveErr := plist.Unmarshal(data, gen.Interface())
+			wantErr := plist.Unmarshal(data, plain.Interface())
+			if (haveErr == nil) != (wantErr == nil) || haveErr != nil && haveErr.Error() != wantErr.Error() {
+				t.Errorf("%s, %s: error %v, want %v", p.name, enc.name, haveErr, wantErr)
+				continue
+			}
+			if have := gen.Convert(plain.Type()).Interface(); !reflect.DeepEqual(have, plain.Interface()) {
+				t.Errorf("%s, %s:\nhave %#v\nwant %#v", p.name, enc.name, have, plain.Interface())
+			}
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		setup func(*plist.Decoder)
+	}{
+		{
+			name: "string into int",
+			data: `<plist><dict><key>Level</key><string>high</string></dict></plist>`,
+		},
+		{
+			name: "integer into string element",
+			data: `<plist><dict><key>Labels</key><array><string>a</string><integer>1</integer></array></dict></plist>`,
+		},
+		{
+			name: "negative integer into uint element",
+			data: `<plist><dict><key>Codes</key><array><integer>-1</integer></array></dict></plist>`,
+		},
+		{
+			name: "nested type error",
+			data: `<plist><dict><key>Nodes</key><array><dict><key>Name</key><true/></dict></array></dict></plist>`,
+		},
+		{
+			name: "date into float",
+			data: `<plist><dict><key>Celsius</key><date>2020-01-01T00:00:00Z</date></dict></plist>`,
+		},
+		{
+			name:  "collected type errors",
+			data:  `<plist><dict><key>Level</key><real>1.5</real><key>Flags</key><array><true/><string>no</string></array><key>Blob</key><integer>1</integer></dict></plist>`,
+			setup: (*plist.Decoder).CollectErrors,
+		},
+		{
+			name:  "unknown field",
+			data:  `<plist><dict><key>Level</key><integer>1</integer><key>Nodes</key><array><dict><key>Other</key><true/></dict></array></dict></plist>`,
+			setup: (*plist.Decoder).DisallowUnknownFields,
+		},
+		{
+			name: "null",
+			data: `<plist><dict><key>Labels</key><array><null/></array><key>Nodes</key><array><null/></array></dict></plist>`,
+		},
+		{
+			name: "not a dictionary",
+			data: `<plist><dict><key>Nodes</key><array><string>node</string></array></dict></plist>`,
+		},
+	}
+	for _, tt := range tests {
+		decode := func(v interface{}) (interface{}, error) {
+			dec := plist.NewDecoder(strings.NewReader(tt.data))
+			if tt.setup != nil {
+				tt.setup(dec)
+			}
+			err := dec.Decode(v)
+			return v, err
+		}
+		have, haveErr := decode(&Named{Labels: []Label{"old"}})
+		want, wantErr := decode(&plainNamed{Labels: []Label{"old"}})
+		if (haveErr == nil) != (wantErr == nil) || haveErr != nil && haveErr.Error() != wantErr.Error() {
+			t.Errorf("%s: error %v, want %v", tt.name, haveErr, wantErr)
+			continue
+		}
+		if have := (*plainNamed)(have.(*Named)); !reflect.DeepEqual(have, want) {
+			t.Errorf("%s:\nhave %#v\nwant %#v", tt.name, have, want)
+		}
+	}
+}
+
+func TestUnmarshalTypeError(t *testing.T) {
+	var header Header
+	err := plist.Unmarshal([]byte(`<plist><array><dict><key>useless</key><string>x</string></dict></array></plist>`), &[]Header{header})
+	terr, ok := err.(plist.UnmarshalTypeError)
+	if !ok {
+		t.Fatalf("error %v, want an UnmarshalTypeError", err)
+	}
+	if terr.Value != "x" || terr.Path != "[0].useless" || terr.Type != reflect.TypeOf(Unused{}) {
+		t.Errorf("error %#v", terr)
+	}
+}
+
+// benchmarked are the pairs the benchmarks encode and decode.
+var benchmarked = map[string]bool{"header": true, "command": true}
+
+func BenchmarkMarshal(b *testing.B) {
+	for _, p := range pairs() {
+		if !benchmarked[p.name] {
+			continue
+		}
+		for _, v := range []struct {
+			name string
+			v    interface{}
+		}{{"generated", p.gen}, {"reflect", p.plain}} {
+			b.Run(p.name+"/"+v.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := plist.MarshalBinary(v.v); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkMarshalValue measures only the encoding into a plist.Value which
+// the generated methods replace.
+func BenchmarkMarshalValue(b *testing.B) {
+	for _, p := range pairs() {
+		if !benchmarked[p.name] {
+			continue
+		}
+		for _, v := range []struct {
+			name string
+			v    interface{}
+		}{{"generated", p.gen}, {"reflect", p.plain}} {
+			b.Run(p.name+"/"+v.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := plist.MarshalValue(v.v); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	for _, p := range pairs() {
+		if !benchmarked[p.name] {
+			continue
+		}
+		data, err := plist.MarshalBinary(p.plain)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, v := range []struct {
+			name string
+			typ  reflect.Type
+		}{{"generated", reflect.TypeOf(p.gen).Elem()}, {"reflect", reflect.TypeOf(p.plain).Elem()}} {
+			b.Run(p.name+"/"+v.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if err := plist.Unmarshal(data, reflect.New(v.typ).Interface()); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
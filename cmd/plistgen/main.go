@@ -0,0 +1,663 @@
+// Plistgen writes MarshalPlist and UnmarshalPlist methods for struct types,
+// which encode and decode them like plist.Encoder and plist.Decoder do,
+// mostly without reflection.
+//
+// Usage:
+//
+//	plistgen -type=T[,T...] [-output=file] [directory]
+//
+// Plistgen finds the fields of each type with the rules of package plist:
+// plist tags name fields and set omitempty, "-" skips them, and the fields of
+// embedded structs are promoted unless a field dominates them. The methods
+// encode strings, numbers, booleans, []byte, time.Time, plist.UID, and the
+// slices, arrays, maps with string keys, pointers and structs of these
+// directly. Empty interfaces are encoded with plist.MarshalInterface, which
+// handles the values the Decoder stores in them without reflection. The same
+// types are decoded directly, except maps and interfaces. Any other field,
+// such as one whose type has its own Marshaler, is handed to package plist,
+// which uses reflection, so the result is the same as the Encoder's and the
+// Decoder's. Omitting an empty struct which can't be compared with == uses
+// package reflect too.
+//
+// The methods are written to directory/<type>_plist.go, for the first type,
+// unless -output names another file. If it is a test file, the types may be
+// declared in the test files of the package. Plistgen is meant for
+// go:generate:
+//
+//	//go:generate plistgen -type=Command,Payload
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const plistPath = "github.com/groob/plist"
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of type names; must be set")
+	output    = flag.String("output", "", "output file name; default srcdir/<type>_plist.go")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of plistgen:\n")
+	fmt.Fprintf(os.Stderr, "\tplistgen -type=T[,T...] [-output=file] [directory]\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("plistgen: ")
+	flag.Usage = usage
+	flag.Parse()
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	names := strings.Split(*typeNames, ",")
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	outputName := *output
+	if outputName == "" {
+		outputName = filepath.Join(dir, strings.ToLower(names[0])+"_plist.go")
+	}
+
+	src, err := generate(dir, outputName, names)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(outputName, src, 0644); err != nil {
+		log.Fatalf("writing output: %s", err)
+	}
+}
+
+// generate returns the source of the methods of the named types of the
+// package in dir. The output file is left out of the package, since it may
+// be stale.
+func generate(dir, outputName string, names []string) ([]byte, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	outputPath, err := filepath.Abs(outputName)
+	if err != nil {
+		return nil, err
+	}
+	// Types declared in test files can only have their methods in test files.
+	goFiles := bp.GoFiles
+	if strings.HasSuffix(outputName, "_test.go") {
+		goFiles = append(goFiles[:len(goFiles):len(goFiles)], bp.TestGoFiles...)
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range goFiles {
+		path := filepath.Join(bp.Dir, name)
+		if abs, err := filepath.Abs(path); err == nil && abs == outputPath {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	// Type errors are ignored: code using the methods doesn't compile
+	// until they are generated.
+	imp := importer.ForCompiler(fset, "source", nil)
+	conf := types.Config{Importer: imp, Error: func(error) {}}
+	pkg, _ := conf.Check(bp.ImportPath, fset, files, nil)
+
+	g := &generator{pkg: pkg, plist: "plist.", imports: map[string]string{}, generated: map[*types.TypeName]bool{}}
+	plistPkg, err := g.findPlist(imp, bp.Dir)
+	if err != nil {
+		return nil, err
+	}
+	timePkg, err := imp.Import("time")
+	if err != nil {
+		return nil, err
+	}
+	g.plistPkg = plistPkg
+	g.timeType = timePkg.Scope().Lookup("Time").Type()
+	g.uidType = plistPkg.Scope().Lookup("UID").Type()
+	g.valueType = plistPkg.Scope().Lookup("Value").Type()
+	g.orderedMapType = plistPkg.Scope().Lookup("OrderedMap").Type()
+	g.marshaler = plistPkg.Scope().Lookup("Marshaler").Type().Underlying().(*types.Interface)
+	g.unmarshaler = plistPkg.Scope().Lookup("Unmarshaler").Type().Underlying().(*types.Interface)
+
+	var named []*types.TypeName
+	for _, name := range names {
+		obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			return nil, fmt.Errorf("no type %s in %s", name, bp.Dir)
+		}
+		if _, ok := obj.Type().Underlying().(*types.Struct); !ok {
+			return nil, fmt.Errorf("%s isn't a struct type", name)
+		}
+		g.generated[obj] = true
+		named = append(named, obj)
+	}
+	for _, obj := range named {
+		g.writeMarshal(obj)
+		g.writeUnmarshal(obj)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by \"plistgen -type=%s\"; DO NOT EDIT.\n\n", strings.Join(names, ","))
+	fmt.Fprintf(&out, "package %s\n\n", pkg.Name())
+	var paths []string
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	if len(paths) > 0 {
+		// Standard packages come first, like goimports sorts them.
+		fmt.Fprintf(&out, "import (\n")
+		for _, std := range []bool{true, false} {
+			if !std {
+				fmt.Fprintf(&out, "\n")
+			}
+			for _, path := range paths {
+				if !strings.Contains(strings.SplitN(path, "/", 2)[0], ".") == std {
+					fmt.Fprintf(&out, "\t%q\n", path)
+				}
+			}
+		}
+		fmt.Fprintf(&out, ")\n")
+	}
+	out.Write(g.buf.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting output: %s", err)
+	}
+	return src, nil
+}
+
+// A generator writes the methods of the types of a package.
+type generator struct {
+	buf       bytes.Buffer
+	pkg       *types.Package
+	plist     string            // qualifier of package plist, "plist." or ""
+	imports   map[string]string // paths and names of the imported packages
+	generated map[*types.TypeName]bool
+	tmp       int          // count of temporary variables
+	inlined   []types.Type // struct types being written inline
+
+	plistPkg       *types.Package
+	timeType       types.Type
+	uidType        types.Type
+	valueType      types.Type
+	orderedMapType types.Type
+	marshaler      *types.Interface
+	unmarshaler    *types.Interface
+}
+
+// findPlist returns package plist, which is the package being generated
+// for if it is in the same directory.
+func (g *generator) findPlist(imp types.Importer, dir string) (*types.Package, error) {
+	bp, err := build.Import(plistPath, dir, build.FindOnly)
+	if err == nil && sameDir(bp.Dir, dir) {
+		g.plist = ""
+		return g.pkg, nil
+	}
+	g.imports[plistPath] = "plist"
+	return imp.Import(plistPath)
+}
+
+func sameDir(a, b string) bool {
+	a, errA := filepath.Abs(a)
+	b, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && a == b
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// typeString returns the Go syntax of t in the generated file, importing
+// the packages it needs.
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == g.pkg {
+			return ""
+		}
+		g.imports[p.Path()] = p.Name()
+		return p.Name()
+	})
+}
+
+// isGenerated reports whether t is one of the types getting methods.
+func (g *generator) isGenerated(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	return ok && g.generated[n.Obj()]
+}
+
+// implements reports whether values of type t or pointers to them implement
+// the interface iface, or will once the methods are generated.
+func (g *generator) implements(t types.Type, iface *types.Interface) bool {
+	if p, ok := t.(*types.Pointer); ok && g.isGenerated(p.Elem()) {
+		return true
+	}
+	return g.isGenerated(t) || types.Implements(t, iface) || types.Implements(types.NewPointer(t), iface)
+}
+
+// basic returns the functions encoding and decoding values of the basic
+// type t, and the type they take and return, if t has a fast path.
+func basic(t *types.Basic) (newFunc, accessor string, typ types.BasicKind, ok bool) {
+	switch t.Kind() {
+	case types.String:
+		return "NewString", "AsString", types.String, true
+	case types.Int, types.Int8, types.Int16, types.Int32, types.Int64:
+		return "NewInt", "AsInt", types.Int64, true
+	case types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64, types.Uintptr:
+		return "NewUint", "AsUint", types.Uint64, true
+	case types.Float32:
+		return "NewReal32", "AsReal", types.Float32, true
+	case types.Float64:
+		return "NewReal", "AsReal", types.Float64, true
+	case types.Bool:
+		return "NewBool", "AsBool", types.Bool, true
+	}
+	return "", "", 0, false
+}
+
+var byteSlice = types.NewSlice(types.Universe.Lookup("byte").Type())
+
+// isBytes reports whether t is []byte or a named type of it.
+func isBytes(t types.Type) bool {
+	s, ok := t.Underlying().(*types.Slice)
+	return ok && types.Identical(s.Elem(), types.Typ[types.Byte])
+}
+
+// convert returns expr, of type t, converted to typ if needed.
+func (g *generator) convert(expr string, t, typ types.Type) string {
+	if types.Identical(t, typ) {
+		return expr
+	}
+	return g.typeString(typ) + "(" + expr + ")"
+}
+
+// selector returns the expression of f in the value named recv, and
+// writes the statements allocating the nil embedded pointers leading to it,
+// like the Encoder and Decoder do.
+func (g *generator) selector(recv string, f field) string {
+	expr := recv
+	for i, v := range f.vars {
+		expr += "." + v.Name()
+		if p, ok := v.Type().(*types.Pointer); ok && i < len(f.vars)-1 {
+			g.printf("if %s == nil {\n%s = new(%s)\n}\n", expr, expr, g.typeString(p.Elem()))
+		}
+	}
+	return expr
+}
+
+func (g *generator) writeMarshal(obj *types.TypeName) {
+	g.tmp = 0
+	g.printf("\n// MarshalPlist encodes t like plist.Encoder does.\n")
+	g.printf("func (t *%s) MarshalPlist() (interface{}, error) {\n", obj.Name())
+	g.printf("if t == nil {\nreturn %sNewNull(), nil\n}\n", g.plist)
+	g.inline(obj.Type())
+	dict := g.encodeStruct("t", obj.Type(), true, nil)
+	g.done()
+	g.printf("return %s, nil\n}\n", dict)
+}
+
+// encodeStruct writes the statements encoding the fields of expr, a struct
+// of type t, into a dictionary and returns its name.
+func (g *generator) encodeStruct(expr string, t types.Type, addressable bool, elems []string) string {
+	fields := typeFields(t)
+	dict := g.name("dict")
+	g.printf("%s := %sNewStructDict(%d)\n", dict, g.plist, len(fields))
+	for _, f := range fields {
+		ft := f.vars[len(f.vars)-1].Type()
+		fexpr := g.selector(expr, f)
+		nonEmpty := ""
+		if f.omitEmpty {
+			nonEmpty = g.nonEmpty(fexpr, ft)
+		}
+		if nonEmpty != "" {
+			g.printf("if %s {\n", nonEmpty)
+		}
+		// Fields reached through embedded pointers are addressable.
+		faddressable := addressable
+		for _, v := range f.vars[:len(f.vars)-1] {
+			if _, ok := v.Type().(*types.Pointer); ok {
+				faddressable = true
+			}
+		}
+		value := g.encode(fexpr, ft, faddressable, append(elems[:len(elems):len(elems)], strconv.Quote(f.name)))
+		g.printf("%s.Set(%q, %s)\n", dict, f.name, value)
+		if nonEmpty != "" {
+			g.printf("}\n")
+		}
+	}
+	return dict
+}
+
+// inline reports whether the fields of the struct type t can be encoded or
+// decoded where a value of it is, and marks t as being written until done is
+// called if so. A type can't be inlined within itself.
+func (g *generator) inline(t types.Type) bool {
+	for _, x := range g.inlined {
+		if types.Identical(x, t) {
+			return false
+		}
+	}
+	g.inlined = append(g.inlined, t)
+	return true
+}
+
+// done ends the inlining of the type inline marked last.
+func (g *generator) done() {
+	g.inlined = g.inlined[:len(g.inlined)-1]
+}
+
+// nonEmpty returns the condition under which the Encoder keeps expr, of type
+// t, in a dictionary if its field has the omitempty option, or "" if it
+// always does.
+func (g *generator) nonEmpty(expr string, t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Array, *types.Map, *types.Slice:
+		return "len(" + expr + ") != 0"
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
+			return "len(" + expr + ") != 0"
+		case u.Info()&types.IsBoolean != 0:
+			return expr
+		case u.Info()&(types.IsInteger|types.IsFloat) != 0:
+			return expr + " != 0"
+		}
+	case *types.Interface, *types.Pointer:
+		return expr + " != nil"
+	case *types.Struct:
+		if types.Comparable(t) {
+			return expr + " != (" + g.typeString(t) + "{})"
+		}
+		g.imports["reflect"] = "reflect"
+		return "!reflect.DeepEqual(" + expr + ", " + g.typeString(t) + "{})"
+	}
+	return ""
+}
+
+// encode writes the statements encoding expr, a value of type t, and returns
+// the expression of the resulting *plist.Value. addressable is whether the
+// Encoder would see expr as addressable, which map values aren't, so that
+// Marshalers with pointer receivers are used where it would use them. elems
+// are the keys and indices leading to expr, for the paths of errors.
+func (g *generator) encode(expr string, t types.Type, addressable bool, elems []string) string {
+	wrap := g.wrap(elems)
+	call := func(format string, args ...interface{}) string {
+		v := g.name("v")
+		g.printf("%s, err := %s\n", v, fmt.Sprintf(format, args...))
+		g.printf("if err != nil {\nreturn nil, %s\n}\n", wrap)
+		return v
+	}
+
+	if g.isGeneratedPtr(t) || g.isGenerated(t) && addressable {
+		m := g.name("m")
+		g.printf("%s, err := %s.MarshalPlist()\n", m, expr)
+		g.printf("if err != nil {\nreturn nil, %s\n}\n", wrap)
+		return m + ".(*" + g.plist + "Value)"
+	}
+	if g.isGenerated(t) && g.inline(t) {
+		// The Encoder encodes the fields of values which aren't
+		// addressable, without their MarshalPlist method.
+		dict := g.encodeStruct(expr, t, false, elems)
+		g.done()
+		return dict
+	}
+	if !g.implements(t, g.marshaler) && !g.isOpaque(t) {
+		if types.Identical(t, g.uidType) {
+			return g.plist + "NewUID(" + expr + ")"
+		}
+		switch u := t.Underlying().(type) {
+		case *types.Basic:
+			if newFunc, _, typ, ok := basic(u); ok {
+				return g.plist + newFunc + "(" + g.convert(expr, t, types.Typ[typ]) + ")"
+			}
+		case *types.Slice:
+			if isBytes(t) {
+				return g.plist + "NewData(" + g.convert(expr, t, byteSlice) + ")"
+			}
+			if isUint8(u.Elem()) {
+				break // encoded as data
+			}
+			if g.isSet(t) {
+				a, i := g.name("a"), g.name("i")
+				g.printf("%s := make([]*%sValue, len(%s))\n", a, g.plist, expr)
+				g.printf("for %s := range %s {\n", i, expr)
+				g.imports["strconv"] = "strconv"
+				g.printf("v, err := %sMarshalInterface(%s[%s])\n", g.plist, expr, i)
+				g.printf("if err != nil {\nreturn nil, %s\n}\n", g.wrap(append(elems[:len(elems):len(elems)], `"[" + strconv.Itoa(`+i+`) + "]"`)))
+				g.printf("%s[%s] = v\n}\n", a, i)
+				return g.plist + "New" + t.(*types.Named).Obj().Name() + "(" + a + "...)"
+			}
+			return g.encodeArray(expr, u.Elem(), true, elems)
+		case *types.Array:
+			if isUint8(u.Elem()) {
+				break // encoded as data
+			}
+			return g.encodeArray(expr, u.Elem(), addressable, elems)
+		case *types.Map:
+			if k, ok := u.Key().Underlying().(*types.Basic); !ok || k.Kind() != types.String {
+				break // an unsupported type
+			}
+			dict, k, e := g.name("dict"), g.name("k"), g.name("e")
+			g.printf("%s := %sNewMapDict(len(%s))\n", dict, g.plist, expr)
+			g.printf("for %s, %s := range %s {\n", k, e, expr)
+			key := g.convert(k, u.Key(), types.Typ[types.String])
+			elem := g.encode(e, u.Elem(), false, append(elems[:len(elems):len(elems)], key))
+			g.printf("%s.Set(%s, %s)\n}\n", dict, key, elem)
+			return dict
+		case *types.Pointer:
+			// The Encoder doesn't look for Marshalers of the value a pointer
+			// points to, but its type has none if the pointer's has none.
+			if _, ok := u.Elem().Underlying().(*types.Struct); !ok || g.isOpaque(u.Elem()) {
+				break
+			}
+			v := g.name("v")
+			g.printf("var %s *%sValue\n", v, g.plist)
+			g.printf("if %s == nil {\n%s = %sNewNull()\n} else {\n", expr, v, g.plist)
+			elem := g.encode("(*"+expr+")", u.Elem(), true, elems)
+			g.printf("%s = %s\n}\n", v, elem)
+			return v
+		case *types.Struct:
+			if types.Identical(t, g.timeType) {
+				return g.plist + "NewDate(" + expr + ")"
+			}
+			if !g.inline(t) {
+				break
+			}
+			dict := g.encodeStruct(expr, t, addressable, elems)
+			g.done()
+			return dict
+		case *types.Interface:
+			if u.Empty() {
+				return call("%sMarshalInterface(%s)", g.plist, expr)
+			}
+		}
+	}
+	if _, ok := t.Underlying().(*types.Interface); ok || addressable {
+		return call("%sMarshalValue(&%s)", g.plist, expr)
+	}
+	return call("%sMarshalMapValue(%s)", g.plist, expr)
+}
+
+// wrap returns the expression adding the path of elems to the error err.
+func (g *generator) wrap(elems []string) string {
+	wrap := "err"
+	for i := len(elems) - 1; i >= 0; i-- {
+		wrap = g.plist + "PrependPath(" + wrap + ", " + elems[i] + ")"
+	}
+	return wrap
+}
+
+// encodeArray writes the statements encoding expr, a slice or array with
+// elements of type elem, and returns the name of the resulting array.
+func (g *generator) encodeArray(expr string, elem types.Type, addressable bool, elems []string) string {
+	a, i := g.name("a"), g.name("i")
+	g.printf("%s := %sMakeArray(len(%s))\n", a, g.plist, expr)
+	g.printf("for %s := range %s {\n", i, expr)
+	g.imports["strconv"] = "strconv"
+	value := g.encode(expr+"["+i+"]", elem, addressable, append(elems[:len(elems):len(elems)], `"[" + strconv.Itoa(`+i+`) + "]"`))
+	g.printf("%s.SetIndex(%s, %s)\n}\n", a, i, value)
+	return a
+}
+
+// isUint8 reports whether t is an unsigned 8-bit integer type, slices and
+// arrays of which the Encoder encodes as data.
+func isUint8(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Uint8
+}
+
+// isOpaque reports whether t, or the type t points to, is plist.Value or
+// plist.OrderedMap, which the Encoder and Decoder handle by themselves.
+func (g *generator) isOpaque(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	return types.Identical(t, g.valueType) || types.Identical(t, g.orderedMapType)
+}
+
+func (g *generator) isGeneratedPtr(t types.Type) bool {
+	p, ok := t.(*types.Pointer)
+	return ok && g.isGenerated(p.Elem())
+}
+
+// isSet reports whether t is plist.Set or plist.OrderedSet, which encode as
+// sets.
+func (g *generator) isSet(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	if !ok || n.Obj().Pkg() != g.plistPkg {
+		return false
+	}
+	return n.Obj().Name() == "Set" || n.Obj().Name() == "OrderedSet"
+}
+
+// name returns a new variable name starting with prefix.
+func (g *generator) name(prefix string) string {
+	g.tmp++
+	return prefix + strconv.Itoa(g.tmp)
+}
+
+func (g *generator) writeUnmarshal(obj *types.TypeName) {
+	g.tmp = 0
+	g.printf("\n// UnmarshalPlist decodes into t like plist.Decoder does.\n")
+	g.printf("func (t *%s) UnmarshalPlist(f func(interface{}) error) error {\n", obj.Name())
+	g.printf("var d %sValueDecoder\n", g.plist)
+	g.printf("if err := f(&d); err != nil {\nreturn err\n}\n")
+	g.printf("if d.Kind() != %sDictionary {\nreturn d.TypeError(t)\n}\n", g.plist)
+	g.inline(obj.Type())
+	g.decodeStruct("d", "t", obj.Type())
+	g.done()
+	g.printf("return nil\n}\n")
+}
+
+// decodeStruct writes the statements decoding the dictionary in the
+// plist.ValueDecoder named v into the fields of expr, a struct of type t.
+func (g *generator) decodeStruct(v, expr string, t types.Type) {
+	fields := typeFields(t)
+	var names []string
+	for _, f := range fields {
+		names = append(names, strconv.Quote(f.name))
+	}
+	g.printf("if err := %s.CheckFields(%s); err != nil {\nreturn err\n}\n", v, strings.Join(names, ", "))
+	for _, f := range fields {
+		w := g.name("v")
+		g.printf("if %s, ok := %s.Key(%q); ok {\n", w, v, f.name)
+		fexpr := g.selector(expr, f)
+		g.decode(w, fexpr, f.vars[len(f.vars)-1].Type())
+		g.printf("}\n")
+	}
+}
+
+// decode writes the statements decoding the plist.ValueDecoder named v into
+// expr, an addressable value of type t.
+func (g *generator) decode(v, expr string, t types.Type) {
+	fallback := fmt.Sprintf("if err := %s.Decode(&%s); err != nil {\nreturn err\n}\n", v, expr)
+	if g.isOpaque(t) {
+		g.printf("%s", fallback)
+		return
+	}
+	// Structs, and pointers to them, are decoded field by field from
+	// dictionaries, like the Decoder and the generated methods do. Other
+	// plist values are type errors or null, which the Decoder handles.
+	st, ptr := t, false
+	if p, ok := t.(*types.Pointer); ok {
+		st, ptr = p.Elem(), true
+	}
+	if _, ok := st.Underlying().(*types.Struct); ok && !types.Identical(st, g.timeType) && !g.isOpaque(st) &&
+		(g.isGenerated(st) || !g.implements(st, g.unmarshaler)) && g.inline(st) {
+		g.printf("if %s.Kind() == %sDictionary {\n", v, g.plist)
+		if ptr {
+			g.printf("if %s == nil {\n%s = new(%s)\n}\n", expr, expr, g.typeString(st))
+		}
+		g.decodeStruct(v, expr, st)
+		g.done()
+		g.printf("} else %s", fallback)
+		return
+	}
+	if g.implements(t, g.unmarshaler) {
+		g.printf("%s", fallback)
+		return
+	}
+	accessor, typ := "", types.Type(nil)
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		if _, a, kind, ok := basic(u); ok {
+			accessor, typ = a, types.Typ[kind]
+			if kind == types.Float32 {
+				typ = types.Typ[types.Float64]
+			}
+		}
+	case *types.Slice:
+		if isBytes(t) {
+			accessor, typ = "AsData", byteSlice
+			break
+		}
+		n, i, e := g.name("n"), g.name("i"), g.name("e")
+		g.printf("if %s.Kind() == %sArray {\n", v, g.plist)
+		g.printf("%s := %s.Len()\n", n, v)
+		// Grow the slice like the Decoder does.
+		g.printf("if %s >= cap(%s) {\n", n, expr)
+		g.printf("c := 2 * %s\nif c < 4 {\nc = 4\n}\n", n)
+		g.printf("s := make(%s, len(%s), c)\ncopy(s, %s)\n%s = s\n}\n", g.typeString(t), expr, expr, expr)
+		g.printf("%s = %s[:%s]\n", expr, expr, n)
+		g.printf("for %s := 0; %s < %s; %s++ {\n", i, i, n, i)
+		g.printf("%s := %s.Index(%s)\n", e, v, i)
+		g.decode(e, expr+"["+i+"]", u.Elem())
+		g.printf("}\n} else %s", fallback)
+		return
+	case *types.Struct:
+		if types.Identical(t, g.timeType) {
+			accessor, typ = "AsDate", t
+		}
+	}
+	if accessor == "" {
+		g.printf("%s", fallback)
+		return
+	}
+	g.printf("if x, ok := %s.%s(); ok {\n", v, accessor)
+	g.printf("%s = %s\n", expr, g.convert("x", typ, t))
+	g.printf("} else %s", fallback)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerate checks that the methods of package gentest, which its tests
+// compare with the Encoder and Decoder, and those the tests of package plist
+// run its tables against, are up to date.
+func TestGenerate(t *testing.T) {
+	for _, outputName := range []string{
+		filepath.Join("internal", "gentest", "types_plist.go"),
+		filepath.Join("..", "..", "codegen_plist_test.go"),
+	} {
+		dir := filepath.Dir(outputName)
+		want, err := ioutil.ReadFile(outputName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The names are in the first line, written by the go:generate command.
+		line := string(want[:bytes.IndexByte(want, '\n')])
+		names := strings.TrimSuffix(line[strings.Index(line, "-type=")+len("-type="):], `"; DO NOT EDIT.`)
+		have, err := generate(dir, outputName, strings.Split(names, ","))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(have, want) {
+			t.Errorf("%s is stale; run go generate in %s", outputName, dir)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	dir := filepath.Join("internal", "gentest")
+	outputName := filepath.Join(dir, "types_plist.go")
+	for _, names := range [][]string{{"Missing"}, {"Label"}} {
+		if _, err := generate(dir, outputName, names); err == nil {
+			t.Errorf("generate(%v) succeeded", names)
+		}
+	}
+}
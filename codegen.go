@@ -0,0 +1,359 @@
+package plist
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// The functions and types in this file let Marshaler and Unmarshaler
+// methods encode and decode the way Encoder and Decoder would, using
+// reflection only for the values they hand over. They are used by the methods
+// plistgen writes.
+
+// MarshalValue returns the plist encoding of the value v points to as
+// a Value, exactly like Encoder encodes struct fields and other addressable
+// values.
+func MarshalValue(v interface{}) (*Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, errors.New("plist: MarshalValue needs a non-nil pointer")
+	}
+	pval, err := valueEncoder.marshal(rv.Elem())
+	if err != nil {
+		return nil, err
+	}
+	return (*Value)(pval), nil
+}
+
+// valueEncoder encodes the values of the functions in this file. It keeps the
+// declaration order of structs, which the Encoder writing the Value may use.
+var valueEncoder = &Encoder{format: XMLFormat, keyOrder: DeclarationOrder}
+
+// MarshalMapValue returns the plist encoding of v as a Value, exactly like
+// Encoder encodes the values of maps. Unlike struct fields they aren't
+// addressable, so Marshalers with pointer receivers aren't used.
+func MarshalMapValue(v interface{}) (*Value, error) {
+	pval, err := valueEncoder.marshal(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	return (*Value)(pval), nil
+}
+
+// MarshalInterface returns the plist encoding of v as a Value, exactly like
+// Encoder encodes an empty interface holding it. Values of the types the
+// Decoder stores in empty interfaces are encoded without reflection.
+func MarshalInterface(v interface{}) (*Value, error) {
+	pval, err := marshalInterface(v)
+	if err != nil {
+		return nil, err
+	}
+	return (*Value)(pval), nil
+}
+
+func marshalInterface(v interface{}) (*plistValue, error) {
+	switch v := v.(type) {
+	case nil:
+		return &plistValue{NullKind, nil}, nil
+	case string:
+		return &plistValue{String, v}, nil
+	case bool:
+		return &plistValue{Boolean, v}, nil
+	case int:
+		return &plistValue{Integer, signedInt{uint64(v), true}}, nil
+	case int8:
+		return &plistValue{Integer, signedInt{uint64(v), true}}, nil
+	case int16:
+		return &plistValue{Integer, signedInt{uint64(v), true}}, nil
+	case int32:
+		return &plistValue{Integer, signedInt{uint64(v), true}}, nil
+	case int64:
+		return &plistValue{Integer, signedInt{uint64(v), true}}, nil
+	case uint:
+		return &plistValue{Integer, signedInt{uint64(v), false}}, nil
+	case uint8:
+		return &plistValue{Integer, signedInt{uint64(v), false}}, nil
+	case uint16:
+		return &plistValue{Integer, signedInt{uint64(v), false}}, nil
+	case uint32:
+		return &plistValue{Integer, signedInt{uint64(v), false}}, nil
+	case uint64:
+		return &plistValue{Integer, signedInt{v, false}}, nil
+	case float32:
+		return &plistValue{Real, sizedFloat{float64(v), 32}}, nil
+	case float64:
+		return &plistValue{Real, sizedFloat{v, 64}}, nil
+	case []byte:
+		return &plistValue{Data, v}, nil
+	case time.Time:
+		return &plistValue{Date, v}, nil
+	case UID:
+		return &plistValue{UIDKind, v}, nil
+	case []interface{}:
+		return marshalInterfaces(Array, v)
+	case Set:
+		return marshalInterfaces(SetKind, v)
+	case OrderedSet:
+		return marshalInterfaces(OrderedSetKind, v)
+	case map[string]interface{}:
+		dict := &dictionary{m: make(map[string]*plistValue, len(v))}
+		for key, elem := range v {
+			pval, err := marshalInterface(elem)
+			if err != nil {
+				return nil, prependPath(err, key)
+			}
+			dict.m[key] = pval
+		}
+		return &plistValue{Dictionary, dict}, nil
+	}
+	return valueEncoder.marshal(reflect.ValueOf(&v).Elem())
+}
+
+func marshalInterfaces(kind Kind, elems []interface{}) (*plistValue, error) {
+	values := make([]*plistValue, len(elems))
+	for i, elem := range elems {
+		pval, err := marshalInterface(elem)
+		if err != nil {
+			return nil, prependPath(err, indexPath(i))
+		}
+		values[i] = pval
+	}
+	return &plistValue{kind, values}, nil
+}
+
+// NewStructDict returns an empty dictionary Value for the fields of a struct,
+// with room for n of them. Like the dictionaries Encoder makes of structs, its
+// keys are written in the order they are set with DeclarationOrder as well as
+// with InsertionOrder.
+func NewStructDict(n int) *Value {
+	// The Value and its dictionary are allocated together.
+	dv := &struct {
+		v Value
+		d dictionary
+	}{}
+	dv.d = dictionary{
+		m:        make(map[string]*plistValue, n),
+		order:    make([]string, 0, n),
+		declared: true,
+	}
+	dv.v = Value{Dictionary, &dv.d}
+	return &dv.v
+}
+
+// NewMapDict returns an empty dictionary Value for the entries of a map, with
+// room for n of them. Like the dictionaries Encoder makes of maps, its keys
+// are always written sorted.
+func NewMapDict(n int) *Value {
+	return &Value{Dictionary, &dictionary{m: make(map[string]*plistValue, n)}}
+}
+
+// MakeArray returns an array Value of n null elements, which SetIndex
+// replaces.
+func MakeArray(n int) *Value {
+	nulls := make([]plistValue, n)
+	values := make([]*plistValue, n)
+	for i := range values {
+		nulls[i].kind = NullKind
+		values[i] = &nulls[i]
+	}
+	return &Value{Array, values}
+}
+
+// PrependPath adds elem, a dictionary key or an array index like "[2]", to
+// the path of an *UnsupportedTypeError or *UnsupportedValueError returned by
+// encoding a value of a dictionary or array. Other errors are returned
+// unchanged.
+func PrependPath(err error, elem string) error {
+	return prependPath(err, elem)
+}
+
+// A ValueDecoder is the plist value an UnmarshalPlist method is decoding,
+// along with the state of the Decoder. Passing a *ValueDecoder to the
+// function UnmarshalPlist is called with sets it:
+//
+//	func (t *T) UnmarshalPlist(f func(interface{}) error) error {
+//		var d plist.ValueDecoder
+//		if err := f(&d); err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// The As methods return the value when it is of the kind they are named
+// for, as the Decoder would decode it into a Go value of that kind. Other
+// values are decoded with Decode, which handles them exactly like the
+// Decoder does.
+type ValueDecoder struct {
+	d    *Decoder
+	pval *plistValue
+
+	// The path of the value is the path of its parent, as in "Items", and
+	// its key or index in the parent. It is only formatted for errors.
+	parent string
+	key    string
+	index  int // -1 for keys
+}
+
+var valueDecoderType = reflect.TypeOf(ValueDecoder{})
+
+// Kind returns the kind of the value.
+func (vd ValueDecoder) Kind() Kind {
+	return vd.pval.kind
+}
+
+// Key returns the value for key of a dictionary, and whether there is one.
+func (vd ValueDecoder) Key(key string) (ValueDecoder, bool) {
+	if vd.pval.kind != Dictionary {
+		return ValueDecoder{}, false
+	}
+	pval, ok := vd.pval.value.(*dictionary).m[key]
+	return ValueDecoder{d: vd.d, pval: pval, parent: vd.path(), key: key, index: -1}, ok
+}
+
+// Len returns the number of elements of an array or set. It returns 0 for
+// other kinds.
+func (vd ValueDecoder) Len() int {
+	switch vd.pval.kind {
+	case Array, SetKind, OrderedSetKind:
+		return len(vd.pval.value.([]*plistValue))
+	}
+	return 0
+}
+
+// Index returns the i'th element of an array or set.
+// It panics if the value isn't an array or set, or i is out of range.
+func (vd ValueDecoder) Index(i int) ValueDecoder {
+	return ValueDecoder{d: vd.d, pval: (*Value)(vd.pval).elems()[i], parent: vd.path(), index: i}
+}
+
+// AsString returns the value of a string.
+func (vd ValueDecoder) AsString() (string, bool) {
+	s, ok := vd.pval.value.(string)
+	return s, ok && vd.pval.kind == String
+}
+
+// AsInt returns the value of an integer, as the Decoder stores it in signed
+// integers.
+func (vd ValueDecoder) AsInt() (int64, bool) {
+	if vd.pval.kind != Integer {
+		return 0, false
+	}
+	return int64(vd.pval.value.(signedInt).value), true
+}
+
+// AsUint returns the value of an integer which isn't negative.
+func (vd ValueDecoder) AsUint() (uint64, bool) {
+	if vd.pval.kind != Integer || vd.pval.value.(signedInt).signed {
+		return 0, false
+	}
+	return vd.pval.value.(signedInt).value, true
+}
+
+// AsReal returns the value of a real.
+func (vd ValueDecoder) AsReal() (float64, bool) {
+	if vd.pval.kind != Real {
+		return 0, false
+	}
+	return vd.pval.value.(sizedFloat).value, true
+}
+
+// AsBool returns the value of a boolean.
+func (vd ValueDecoder) AsBool() (bool, bool) {
+	b, ok := vd.pval.value.(bool)
+	return b, ok && vd.pval.kind == Boolean
+}
+
+// AsData returns the value of data. It doesn't share memory with the input
+// of the Decoder.
+func (vd ValueDecoder) AsData() ([]byte, bool) {
+	if vd.pval.kind != Data {
+		return nil, false
+	}
+	return vd.d.dataBytes(vd.pval), true
+}
+
+// AsDate returns the value of a date.
+func (vd ValueDecoder) AsDate() (time.Time, bool) {
+	t, ok := vd.pval.value.(time.Time)
+	return t, ok && vd.pval.kind == Date
+}
+
+// Decode decodes the value into the value v points to, exactly like the
+// Decoder decodes it into a struct field or array element.
+func (vd ValueDecoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("plist: ValueDecoder.Decode needs a non-nil pointer")
+	}
+	return vd.within(func() error {
+		return vd.d.collect(vd.d.unmarshal(vd.pval, rv.Elem()))
+	})
+}
+
+// CheckFields returns an error if the Decoder disallows unknown fields and
+// the dictionary has a key which isn't one of the field names. See
+// Decoder.DisallowUnknownFields.
+func (vd ValueDecoder) CheckFields(names ...string) error {
+	if !vd.d.disallowUnknownFields || vd.pval.kind != Dictionary {
+		return nil
+	}
+	return vd.within(func() error {
+		return vd.d.checkFields(vd.pval.value.(*dictionary).m, names)
+	})
+}
+
+// TypeError returns the error the Decoder reports for decoding the value
+// into the struct v points to, which only dictionaries decode into.
+func (vd ValueDecoder) TypeError(v interface{}) error {
+	return vd.within(func() error {
+		return vd.d.typeError(describeValue(vd.pval), reflect.TypeOf(v).Elem())
+	})
+}
+
+// path returns the keys and indices leading to the value from the value
+// the Unmarshaler is decoding.
+func (vd ValueDecoder) path() string {
+	switch {
+	case vd.index >= 0:
+		return vd.parent + indexPath(vd.index)
+	case vd.parent == "":
+		return vd.key
+	}
+	return formatPath([]string{vd.parent, vd.key})
+}
+
+// within calls f with the path of the value added to the path of the
+// decoder.
+func (vd ValueDecoder) within(f func() error) error {
+	path := vd.path()
+	if path == "" {
+		return f()
+	}
+	vd.d.path = append(vd.d.path, path)
+	err := f()
+	vd.d.path = vd.d.path[:len(vd.d.path)-1]
+	return err
+}
+
+// describeValue describes pval like the errors of decoding it into a struct.
+func describeValue(pval *plistValue) string {
+	switch pval.kind {
+	case String:
+		return pval.value.(string)
+	case Integer:
+		return fmt.Sprintf("%v", pval.value.(signedInt).value)
+	case Real:
+		return fmt.Sprintf("%v", pval.value.(sizedFloat).value)
+	case Data:
+		return fmt.Sprintf("%s", pval.value.([]byte))
+	case UIDKind:
+		return fmt.Sprintf("uid %d", pval.value.(UID))
+	case Dictionary:
+		return "dict"
+	case Array, SetKind, OrderedSetKind:
+		return "array"
+	}
+	return fmt.Sprintf("%v", pval.value)
+}
@@ -0,0 +1,567 @@
+// Code generated by "plistgen -type=genDict,genIndentHeader,genOmitHeader,genOmitAllHeader,genDictHeader,genKeyOrderDoc,genValues"; DO NOT EDIT.
+
+package plist
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *genDict) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return NewNull(), nil
+	}
+	dict1 := NewStructDict(2)
+	dict1.Set("foo", NewString(t.Foo))
+	dict1.Set("bool", NewBool(t.Bool))
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *genDict) UnmarshalPlist(f func(interface{}) error) error {
+	var d ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("foo", "bool"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("foo"); ok {
+		if x, ok := v1.AsString(); ok {
+			t.Foo = x
+		} else if err := v1.Decode(&t.Foo); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("bool"); ok {
+		if x, ok := v2.AsBool(); ok {
+			t.Bool = x
+		} else if err := v2.Decode(&t.Bool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *genIndentHeader) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return NewNull(), nil
+	}
+	dict1 := NewStructDict(6)
+	dict1.Set("CFBundleInfoDictionaryVersion", NewString(t.InfoDictionaryVersion))
+	dict1.Set("band-size", NewUint(t.BandSize))
+	dict1.Set("bundle-backingstore-version", NewInt(int64(t.BackingStoreVersion)))
+	dict1.Set("diskimage-bundle-type", NewString(t.DiskImageBundleType))
+	dict1.Set("size", NewUint(t.Size))
+	dict2 := NewStructDict(2)
+	dict2.Set("unused-string", NewString(t.Unused.UnusedString))
+	if len(t.Unused.UnusedByte) != 0 {
+		dict2.Set("unused-byte", NewData(t.Unused.UnusedByte))
+	}
+	dict1.Set("useless", dict2)
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *genIndentHeader) UnmarshalPlist(f func(interface{}) error) error {
+	var d ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("CFBundleInfoDictionaryVersion", "band-size", "bundle-backingstore-version", "diskimage-bundle-type", "size", "useless"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("CFBundleInfoDictionaryVersion"); ok {
+		if x, ok := v1.AsString(); ok {
+			t.InfoDictionaryVersion = x
+		} else if err := v1.Decode(&t.InfoDictionaryVersion); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("band-size"); ok {
+		if x, ok := v2.AsUint(); ok {
+			t.BandSize = x
+		} else if err := v2.Decode(&t.BandSize); err != nil {
+			return err
+		}
+	}
+	if v3, ok := d.Key("bundle-backingstore-version"); ok {
+		if x, ok := v3.AsInt(); ok {
+			t.BackingStoreVersion = int(x)
+		} else if err := v3.Decode(&t.BackingStoreVersion); err != nil {
+			return err
+		}
+	}
+	if v4, ok := d.Key("diskimage-bundle-type"); ok {
+		if x, ok := v4.AsString(); ok {
+			t.DiskImageBundleType = x
+		} else if err := v4.Decode(&t.DiskImageBundleType); err != nil {
+			return err
+		}
+	}
+	if v5, ok := d.Key("size"); ok {
+		if x, ok := v5.AsUint(); ok {
+			t.Size = x
+		} else if err := v5.Decode(&t.Size); err != nil {
+			return err
+		}
+	}
+	if v6, ok := d.Key("useless"); ok {
+		if v6.Kind() == Dictionary {
+			if err := v6.CheckFields("unused-string", "unused-byte"); err != nil {
+				return err
+			}
+			if v7, ok := v6.Key("unused-string"); ok {
+				if x, ok := v7.AsString(); ok {
+					t.Unused.UnusedString = x
+				} else if err := v7.Decode(&t.Unused.UnusedString); err != nil {
+					return err
+				}
+			}
+			if v8, ok := v6.Key("unused-byte"); ok {
+				if x, ok := v8.AsData(); ok {
+					t.Unused.UnusedByte = x
+				} else if err := v8.Decode(&t.Unused.UnusedByte); err != nil {
+					return err
+				}
+			}
+		} else if err := v6.Decode(&t.Unused); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *genOmitHeader) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return NewNull(), nil
+	}
+	dict1 := NewStructDict(6)
+	dict1.Set("CFBundleInfoDictionaryVersion", NewString(t.InfoDictionaryVersion))
+	if t.BandSize != 0 {
+		dict1.Set("band-size", NewUint(t.BandSize))
+	}
+	dict1.Set("bundle-backingstore-version", NewInt(int64(t.BackingStoreVersion)))
+	dict1.Set("diskimage-bundle-type", NewString(t.DiskImageBundleType))
+	dict1.Set("size", NewUint(t.Size))
+	dict2 := NewStructDict(2)
+	dict2.Set("unused-string", NewString(t.Unused.UnusedString))
+	if len(t.Unused.UnusedByte) != 0 {
+		dict2.Set("unused-byte", NewData(t.Unused.UnusedByte))
+	}
+	dict1.Set("useless", dict2)
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *genOmitHeader) UnmarshalPlist(f func(interface{}) error) error {
+	var d ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("CFBundleInfoDictionaryVersion", "band-size", "bundle-backingstore-version", "diskimage-bundle-type", "size", "useless"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("CFBundleInfoDictionaryVersion"); ok {
+		if x, ok := v1.AsString(); ok {
+			t.InfoDictionaryVersion = x
+		} else if err := v1.Decode(&t.InfoDictionaryVersion); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("band-size"); ok {
+		if x, ok := v2.AsUint(); ok {
+			t.BandSize = x
+		} else if err := v2.Decode(&t.BandSize); err != nil {
+			return err
+		}
+	}
+	if v3, ok := d.Key("bundle-backingstore-version"); ok {
+		if x, ok := v3.AsInt(); ok {
+			t.BackingStoreVersion = int(x)
+		} else if err := v3.Decode(&t.BackingStoreVersion); err != nil {
+			return err
+		}
+	}
+	if v4, ok := d.Key("diskimage-bundle-type"); ok {
+		if x, ok := v4.AsString(); ok {
+			t.DiskImageBundleType = x
+		} else if err := v4.Decode(&t.DiskImageBundleType); err != nil {
+			return err
+		}
+	}
+	if v5, ok := d.Key("size"); ok {
+		if x, ok := v5.AsUint(); ok {
+			t.Size = x
+		} else if err := v5.Decode(&t.Size); err != nil {
+			return err
+		}
+	}
+	if v6, ok := d.Key("useless"); ok {
+		if v6.Kind() == Dictionary {
+			if err := v6.CheckFields("unused-string", "unused-byte"); err != nil {
+				return err
+			}
+			if v7, ok := v6.Key("unused-string"); ok {
+				if x, ok := v7.AsString(); ok {
+					t.Unused.UnusedString = x
+				} else if err := v7.Decode(&t.Unused.UnusedString); err != nil {
+					return err
+				}
+			}
+			if v8, ok := v6.Key("unused-byte"); ok {
+				if x, ok := v8.AsData(); ok {
+					t.Unused.UnusedByte = x
+				} else if err := v8.Decode(&t.Unused.UnusedByte); err != nil {
+					return err
+				}
+			}
+		} else if err := v6.Decode(&t.Unused); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *genOmitAllHeader) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return NewNull(), nil
+	}
+	dict1 := NewStructDict(6)
+	dict1.Set("CFBundleInfoDictionaryVersion", NewString(t.InfoDictionaryVersion))
+	if t.BandSize != 0 {
+		dict1.Set("band-size", NewUint(t.BandSize))
+	}
+	dict1.Set("bundle-backingstore-version", NewInt(int64(t.BackingStoreVersion)))
+	dict1.Set("diskimage-bundle-type", NewString(t.DiskImageBundleType))
+	dict1.Set("size", NewUint(t.Size))
+	if !reflect.DeepEqual(t.Unused, testStruct{}) {
+		dict2 := NewStructDict(2)
+		dict2.Set("unused-string", NewString(t.Unused.UnusedString))
+		if len(t.Unused.UnusedByte) != 0 {
+			dict2.Set("unused-byte", NewData(t.Unused.UnusedByte))
+		}
+		dict1.Set("useless", dict2)
+	}
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *genOmitAllHeader) UnmarshalPlist(f func(interface{}) error) error {
+	var d ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("CFBundleInfoDictionaryVersion", "band-size", "bundle-backingstore-version", "diskimage-bundle-type", "size", "useless"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("CFBundleInfoDictionaryVersion"); ok {
+		if x, ok := v1.AsString(); ok {
+			t.InfoDictionaryVersion = x
+		} else if err := v1.Decode(&t.InfoDictionaryVersion); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("band-size"); ok {
+		if x, ok := v2.AsUint(); ok {
+			t.BandSize = x
+		} else if err := v2.Decode(&t.BandSize); err != nil {
+			return err
+		}
+	}
+	if v3, ok := d.Key("bundle-backingstore-version"); ok {
+		if x, ok := v3.AsInt(); ok {
+			t.BackingStoreVersion = int(x)
+		} else if err := v3.Decode(&t.BackingStoreVersion); err != nil {
+			return err
+		}
+	}
+	if v4, ok := d.Key("diskimage-bundle-type"); ok {
+		if x, ok := v4.AsString(); ok {
+			t.DiskImageBundleType = x
+		} else if err := v4.Decode(&t.DiskImageBundleType); err != nil {
+			return err
+		}
+	}
+	if v5, ok := d.Key("size"); ok {
+		if x, ok := v5.AsUint(); ok {
+			t.Size = x
+		} else if err := v5.Decode(&t.Size); err != nil {
+			return err
+		}
+	}
+	if v6, ok := d.Key("useless"); ok {
+		if v6.Kind() == Dictionary {
+			if err := v6.CheckFields("unused-string", "unused-byte"); err != nil {
+				return err
+			}
+			if v7, ok := v6.Key("unused-string"); ok {
+				if x, ok := v7.AsString(); ok {
+					t.Unused.UnusedString = x
+				} else if err := v7.Decode(&t.Unused.UnusedString); err != nil {
+					return err
+				}
+			}
+			if v8, ok := v6.Key("unused-byte"); ok {
+				if x, ok := v8.AsData(); ok {
+					t.Unused.UnusedByte = x
+				} else if err := v8.Decode(&t.Unused.UnusedByte); err != nil {
+					return err
+				}
+			}
+		} else if err := v6.Decode(&t.Unused); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *genDictHeader) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return NewNull(), nil
+	}
+	dict1 := NewStructDict(5)
+	dict1.Set("CFBundleInfoDictionaryVersion", NewString(t.InfoDictionaryVersion))
+	dict1.Set("band-size", NewUint(t.BandSize))
+	dict1.Set("bundle-backingstore-version", NewInt(int64(t.BackingStoreVersion)))
+	dict1.Set("diskimage-bundle-type", NewString(t.DiskImageBundleType))
+	dict1.Set("size", NewUint(t.Size))
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *genDictHeader) UnmarshalPlist(f func(interface{}) error) error {
+	var d ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("CFBundleInfoDictionaryVersion", "band-size", "bundle-backingstore-version", "diskimage-bundle-type", "size"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("CFBundleInfoDictionaryVersion"); ok {
+		if x, ok := v1.AsString(); ok {
+			t.InfoDictionaryVersion = x
+		} else if err := v1.Decode(&t.InfoDictionaryVersion); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("band-size"); ok {
+		if x, ok := v2.AsUint(); ok {
+			t.BandSize = x
+		} else if err := v2.Decode(&t.BandSize); err != nil {
+			return err
+		}
+	}
+	if v3, ok := d.Key("bundle-backingstore-version"); ok {
+		if x, ok := v3.AsInt(); ok {
+			t.BackingStoreVersion = int(x)
+		} else if err := v3.Decode(&t.BackingStoreVersion); err != nil {
+			return err
+		}
+	}
+	if v4, ok := d.Key("diskimage-bundle-type"); ok {
+		if x, ok := v4.AsString(); ok {
+			t.DiskImageBundleType = x
+		} else if err := v4.Decode(&t.DiskImageBundleType); err != nil {
+			return err
+		}
+	}
+	if v5, ok := d.Key("size"); ok {
+		if x, ok := v5.AsUint(); ok {
+			t.Size = x
+		} else if err := v5.Decode(&t.Size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *genKeyOrderDoc) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return NewNull(), nil
+	}
+	dict1 := NewStructDict(4)
+	dict1.Set("name", NewString(t.Name))
+	v2, err := MarshalValue(&t.Ordered)
+	if err != nil {
+		return nil, PrependPath(err, "ordered")
+	}
+	dict1.Set("ordered", v2)
+	dict3 := NewMapDict(len(t.Map))
+	for k4, e5 := range t.Map {
+		dict3.Set(k4, NewInt(int64(e5)))
+	}
+	dict1.Set("map", dict3)
+	dict1.Set("age", NewInt(int64(t.Age)))
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *genKeyOrderDoc) UnmarshalPlist(f func(interface{}) error) error {
+	var d ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("name", "ordered", "map", "age"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("name"); ok {
+		if x, ok := v1.AsString(); ok {
+			t.Name = x
+		} else if err := v1.Decode(&t.Name); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("ordered"); ok {
+		if err := v2.Decode(&t.Ordered); err != nil {
+			return err
+		}
+	}
+	if v3, ok := d.Key("map"); ok {
+		if err := v3.Decode(&t.Map); err != nil {
+			return err
+		}
+	}
+	if v4, ok := d.Key("age"); ok {
+		if x, ok := v4.AsInt(); ok {
+			t.Age = int(x)
+		} else if err := v4.Decode(&t.Age); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalPlist encodes t like plist.Encoder does.
+func (t *genValues) MarshalPlist() (interface{}, error) {
+	if t == nil {
+		return NewNull(), nil
+	}
+	dict1 := NewStructDict(7)
+	dict1.Set("String", NewString(t.String))
+	dict1.Set("Uint", NewUint(t.Uint))
+	dict1.Set("Real", NewReal(t.Real))
+	dict1.Set("Bool", NewBool(t.Bool))
+	a2 := MakeArray(len(t.Array))
+	for i3 := range t.Array {
+		v4, err := MarshalInterface(t.Array[i3])
+		if err != nil {
+			return nil, PrependPath(PrependPath(err, "["+strconv.Itoa(i3)+"]"), "Array")
+		}
+		a2.SetIndex(i3, v4)
+	}
+	dict1.Set("Array", a2)
+	dict1.Set("Date", NewDate(t.Date))
+	dict5 := NewMapDict(len(t.Dict))
+	for k6, e7 := range t.Dict {
+		v8, err := MarshalInterface(e7)
+		if err != nil {
+			return nil, PrependPath(PrependPath(err, k6), "Dict")
+		}
+		dict5.Set(k6, v8)
+	}
+	dict1.Set("Dict", dict5)
+	return dict1, nil
+}
+
+// UnmarshalPlist decodes into t like plist.Decoder does.
+func (t *genValues) UnmarshalPlist(f func(interface{}) error) error {
+	var d ValueDecoder
+	if err := f(&d); err != nil {
+		return err
+	}
+	if d.Kind() != Dictionary {
+		return d.TypeError(t)
+	}
+	if err := d.CheckFields("String", "Uint", "Real", "Bool", "Array", "Date", "Dict"); err != nil {
+		return err
+	}
+	if v1, ok := d.Key("String"); ok {
+		if x, ok := v1.AsString(); ok {
+			t.String = x
+		} else if err := v1.Decode(&t.String); err != nil {
+			return err
+		}
+	}
+	if v2, ok := d.Key("Uint"); ok {
+		if x, ok := v2.AsUint(); ok {
+			t.Uint = x
+		} else if err := v2.Decode(&t.Uint); err != nil {
+			return err
+		}
+	}
+	if v3, ok := d.Key("Real"); ok {
+		if x, ok := v3.AsReal(); ok {
+			t.Real = x
+		} else if err := v3.Decode(&t.Real); err != nil {
+			return err
+		}
+	}
+	if v4, ok := d.Key("Bool"); ok {
+		if x, ok := v4.AsBool(); ok {
+			t.Bool = x
+		} else if err := v4.Decode(&t.Bool); err != nil {
+			return err
+		}
+	}
+	if v5, ok := d.Key("Array"); ok {
+		if v5.Kind() == Array {
+			n6 := v5.Len()
+			if n6 >= cap(t.Array) {
+				c := 2 * n6
+				if c < 4 {
+					c = 4
+				}
+				s := make([]interface{}, len(t.Array), c)
+				copy(s, t.Array)
+				t.Array = s
+			}
+			t.Array = t.Array[:n6]
+			for i7 := 0; i7 < n6; i7++ {
+				e8 := v5.Index(i7)
+				if err := e8.Decode(&t.Array[i7]); err != nil {
+					return err
+				}
+			}
+		} else if err := v5.Decode(&t.Array); err != nil {
+			return err
+		}
+	}
+	if v9, ok := d.Key("Date"); ok {
+		if x, ok := v9.AsDate(); ok {
+			t.Date = x
+		} else if err := v9.Decode(&t.Date); err != nil {
+			return err
+		}
+	}
+	if v10, ok := d.Key("Dict"); ok {
+		if err := v10.Decode(&t.Dict); err != nil {
+			return err
+		}
+	}
+	return nil
+}
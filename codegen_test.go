@@ -0,0 +1,150 @@
+package plist
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+//go:generate go run ./cmd/plistgen -type=genDict,genIndentHeader,genOmitHeader,genOmitAllHeader,genDictHeader,genKeyOrderDoc,genValues -output=codegen_plist_test.go
+
+// The gen types have the fields of the anonymous structs of the encode and
+// decode tests, and methods written by plistgen.
+type (
+	genDict struct {
+		Foo  string `plist:"foo"`
+		Bool bool   `plist:"bool"`
+	}
+
+	genIndentHeader struct {
+		InfoDictionaryVersion string     `plist:"CFBundleInfoDictionaryVersion"`
+		BandSize              uint64     `plist:"band-size"`
+		BackingStoreVersion   int        `plist:"bundle-backingstore-version"`
+		DiskImageBundleType   string     `plist:"diskimage-bundle-type"`
+		Size                  uint64     `plist:"size"`
+		Unused                testStruct `plist:"useless"`
+	}
+
+	genOmitHeader struct {
+		InfoDictionaryVersion string     `plist:"CFBundleInfoDictionaryVersion"`
+		BandSize              uint64     `plist:"band-size,omitempty"`
+		BackingStoreVersion   int        `plist:"bundle-backingstore-version"`
+		DiskImageBundleType   string     `plist:"diskimage-bundle-type"`
+		Size                  uint64     `plist:"size"`
+		Unused                testStruct `plist:"useless"`
+	}
+
+	genOmitAllHeader struct {
+		InfoDictionaryVersion string     `plist:"CFBundleInfoDictionaryVersion"`
+		BandSize              uint64     `plist:"band-size,omitempty"`
+		BackingStoreVersion   int        `plist:"bundle-backingstore-version"`
+		DiskImageBundleType   string     `plist:"diskimage-bundle-type"`
+		Size                  uint64     `plist:"size"`
+		Unused                testStruct `plist:"useless,omitempty"`
+	}
+
+	genDictHeader struct {
+		InfoDictionaryVersion string `plist:"CFBundleInfoDictionaryVersion"`
+		BandSize              uint64 `plist:"band-size"`
+		BackingStoreVersion   int    `plist:"bundle-backingstore-version"`
+		DiskImageBundleType   string `plist:"diskimage-bundle-type"`
+		Size                  uint64 `plist:"size"`
+	}
+
+	genKeyOrderDoc struct {
+		Name    string         `plist:"name"`
+		Ordered OrderedMap     `plist:"ordered"`
+		Map     map[string]int `plist:"map"`
+		Age     int            `plist:"age"`
+	}
+)
+
+var genTypes = []reflect.Type{
+	reflect.TypeOf(genDict{}),
+	reflect.TypeOf(genIndentHeader{}),
+	reflect.TypeOf(genOmitHeader{}),
+	reflect.TypeOf(genOmitAllHeader{}),
+	reflect.TypeOf(genDictHeader{}),
+	reflect.TypeOf(genKeyOrderDoc{}),
+}
+
+// withGenerated returns v, a struct or a pointer to one, along with a pointer
+// to it as the gen type with the same fields, if there is one. Tests looping
+// over them run against both the reflection of the Encoder and Decoder and
+// the methods plistgen writes.
+func withGenerated(v interface{}) []interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr {
+		p := reflect.New(rv.Type())
+		p.Elem().Set(rv)
+		rv = p
+	}
+	vs := []interface{}{v}
+	for _, t := range genTypes {
+		if rv.Type().Elem().AssignableTo(t) {
+			vs = append(vs, rv.Convert(reflect.PtrTo(t)).Interface())
+		}
+	}
+	return vs
+}
+
+func TestMarshalInterface(t *testing.T) {
+	t.Parallel()
+	for _, tt := range encodeTests {
+		v, err := MarshalInterface(tt.in)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		b, err := Marshal(v)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if out := string(b); out != tt.out {
+			t.Errorf("MarshalInterface(%v) = \n%v, \nwant\n %v", tt.in, out, tt.out)
+		}
+	}
+
+	_, err := MarshalInterface(map[string]interface{}{"a": []interface{}{1, complex(1, 2)}})
+	if err == nil || !strings.Contains(err.Error(), "(in a[1])") {
+		t.Errorf("error %v, want the path a[1]", err)
+	}
+}
+
+// genValues has a field of the type of each value of decodeTests.
+type genValues struct {
+	String string
+	Uint   uint64
+	Real   float64
+	Bool   bool
+	Array  []interface{}
+	Date   time.Time
+	Dict   map[string]interface{}
+}
+
+// TestGeneratedDecodeValues decodes the values of decodeTests into the fields
+// of a type with generated methods.
+func TestGeneratedDecodeValues(t *testing.T) {
+	fields := reflect.TypeOf(genValues{})
+	for _, tt := range decodeTests {
+		var name string
+		for i := 0; i < fields.NumField(); i++ {
+			if fields.Field(i).Type == reflect.TypeOf(tt.out) {
+				name = fields.Field(i).Name
+			}
+		}
+		start := strings.Index(tt.in, `<plist version="1.0">`) + len(`<plist version="1.0">`)
+		end := strings.LastIndex(tt.in, "</plist>")
+		in := "<plist><dict><key>" + name + "</key>" + tt.in[start:end] + "</dict></plist>"
+		var out genValues
+		if err := Unmarshal([]byte(in), &out); err != nil {
+			t.Error(err)
+			continue
+		}
+		if have := reflect.ValueOf(out).FieldByName(name).Interface(); !reflect.DeepEqual(have, tt.out) {
+			t.Errorf("Unmarshal(%v) = \n%v, want %v", in, have, tt.out)
+		}
+	}
+}
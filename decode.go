@@ -1,11 +1,17 @@
 package plist
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"reflect"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -17,164 +23,537 @@ type Unmarshaler interface {
 }
 
 // Unmarshal parses the plist-encoded data and stores the result in the value pointed to by v.
+// The format of the plist is detected automatically.
 func Unmarshal(data []byte, v interface{}) error {
-	// Check for binary plist here before setting up the decoder.
-	if bytes.HasPrefix(data, []byte("bplist0")) {
-		return NewBinaryDecoder(bytes.NewReader(data)).Decode(v)
-	}
-	return NewXMLDecoder(bytes.NewReader(data)).Decode(v)
+	d := NewDecoder(bytes.NewReader(data))
+	d.data = data
+	return d.Decode(v)
 }
 
-// A Decoder reads and decodes Apple plist objects from an input stream.
-// The plists can be in XML or binary format.
+// A Decoder reads and decodes Apple plists from an input stream.
+// The plists can be in XML, binary, OpenStep, GNUstep or JSON format.
 type Decoder struct {
-	reader   io.Reader // binary decoders assert this to io.ReadSeeker
-	isBinary bool      // true if this is a binary plist
+	reader io.Reader // binary decoders assert this to io.ReadSeeker
+	format Format    // format of the plist, AutomaticFormat before detection
+	detect bool      // true if the format is detected on each Decode
+	data   []byte    // input of Unmarshal, which binary plists are parsed in place
+
+	collectErrors bool      // true if type errors are collected, see CollectErrors
+	errs          ErrorList // type errors collected by the current Decode
+
+	disallowUnknownFields bool          // see DisallowUnknownFields
+	strict                bool          // see Strict
+	limits                DecoderLimits // see SetLimits
+
+	path []string // keys and indices leading to the value being decoded
 }
 
-// NewDecoder returns a new XML plist decoder.
-// DEPRECATED: Please use NewXMLDecoder instead.
+// NewDecoder returns a new decoder that reads from r and detects the format
+// of the plist. Binary plists need random access; if r is not an io.ReadSeeker
+// a binary plist is read into memory before it is decoded.
 func NewDecoder(r io.Reader) *Decoder {
-	return NewXMLDecoder(r)
+	return &Decoder{reader: r, detect: true}
 }
 
 // NewXMLDecoder returns a new decoder that reads an XML plist from r.
 func NewXMLDecoder(r io.Reader) *Decoder {
-	return &Decoder{reader: r, isBinary: false}
+	return &Decoder{reader: r, format: XMLFormat}
 }
 
 // NewBinaryDecoder returns a new decoder that reads a binary plist from r.
 // No error checking is done to make sure that r is actually a binary plist.
+// If r can't seek, as with pipes, the plist is read into memory first.
 func NewBinaryDecoder(r io.ReadSeeker) *Decoder {
-	return &Decoder{reader: r, isBinary: true}
+	return &Decoder{reader: r, format: BinaryFormat}
+}
+
+// NewOpenStepDecoder returns a new decoder that reads an OpenStep plist from r.
+//
+// Since OpenStep plists store numbers, booleans and dates as strings, strings
+// are converted when they are decoded into Go values of those types.
+func NewOpenStepDecoder(r io.Reader) *Decoder {
+	return &Decoder{reader: r, format: OpenStepFormat}
+}
+
+// NewGNUStepDecoder returns a new decoder that reads a GNUstep plist from r.
+// GNUstep's typed literals are decoded into integers, reals, booleans and
+// dates; untyped strings are converted like in OpenStep plists.
+func NewGNUStepDecoder(r io.Reader) *Decoder {
+	return &Decoder{reader: r, format: GNUStepFormat}
+}
+
+// NewJSONDecoder returns a new decoder that reads a JSON plist, as written
+// by plutil -convert json, from r.
+//
+// JSON has no data or date types. Strings are base64-decoded when they are
+// decoded into a []byte, and parsed as RFC 3339 dates when they are decoded
+// into a time.Time. Integers may be decoded into floating point values.
+func NewJSONDecoder(r io.Reader) *Decoder {
+	return &Decoder{reader: r, format: JSONFormat}
+}
+
+// NewTypedJSONDecoder returns a new decoder that reads a typed JSON plist,
+// as written by an encoder returned by NewTypedJSONEncoder, from r.
+func NewTypedJSONDecoder(r io.Reader) *Decoder {
+	return &Decoder{reader: r, format: TypedJSONFormat}
+}
+
+// Format returns the format of the plist read by the last call to Decode.
+// For a decoder created with NewDecoder it returns AutomaticFormat until
+// the format has been detected.
+func (d *Decoder) Format() Format {
+	return d.format
 }
 
 // Decode reads the next plist-encoded value from its input and stores it in
-// the value pointed to by v.  Decode uses xml.Decoder to do the heavy lifting
-// for XML plists, and uses binaryParser for binary plists.
+// the value pointed to by v.  Decode uses xmlScanner to do the heavy lifting
+// for XML plists, binaryParser for binary plists, textParser for OpenStep
+// plists and json.Decoder for JSON plists.
 func (d *Decoder) Decode(v interface{}) error {
 	val := reflect.ValueOf(v)
 	if val.Kind() != reflect.Ptr {
 		return errors.New("plist: non-pointer passed to Unmarshal")
 	}
-	var pval *plistValue
-	if d.isBinary {
-		// For binary decoder, type assert the reader to an io.ReadSeeker
-		var err error
-		r, ok := d.reader.(io.ReadSeeker)
-		if !ok {
-			return fmt.Errorf("binary plist decoder requires an io.ReadSeeker")
+	if err := d.detectFormat(); err != nil {
+		return err
+	}
+	d.path = d.path[:0]
+	d.errs = nil
+	if d.format == XMLFormat {
+		if err := d.decodeXML(val.Elem()); err != nil {
+			return err
 		}
-		parser, err := newBinaryParser(r)
+	} else {
+		pval, err := d.parseFormat()
 		if err != nil {
 			return err
 		}
-		pval, err = parser.parseDocument()
-		if err != nil {
+		if err := d.collect(d.unmarshal(pval, val.Elem())); err != nil {
 			return err
 		}
-	} else {
-		var err error
-		parser := newXMLParser(d.reader)
-		pval, err = parser.parseDocument(nil)
+	}
+	if len(d.errs) > 0 {
+		return d.errs
+	}
+	return nil
+}
+
+// CollectErrors makes the decoder carry on when a plist value doesn't fit the
+// Go value it is decoded into, instead of stopping at the first mismatch. The
+// mismatched values are left alone, everything else is decoded, and Decode
+// returns an ErrorList of all the mismatches. Other errors still stop
+// decoding.
+func (d *Decoder) CollectErrors() {
+	d.collectErrors = true
+}
+
+// DisallowUnknownFields makes Decode return an error when a dictionary holds
+// a key which doesn't match any field of the struct it is decoded into,
+// instead of ignoring the key.
+func (d *Decoder) DisallowUnknownFields() {
+	d.disallowUnknownFields = true
+}
+
+// Strict makes the decoder reject plists which are well-formed enough to be
+// read, but which Apple's tools wouldn't write:
+//
+//   - dictionaries with duplicate keys
+//   - XML dictionaries with keys without values, and XML plists with text
+//     outside of elements
+//   - XML plists without a plist root element with version="1.0"
+//   - XML plists with more than one root object
+//   - XML and JSON plists with data after the end of the document
+//   - XML booleans which aren't empty elements, and text plist strings other
+//     than YES and NO decoded into a bool
+//
+// Since nothing may follow a document, a strict decoder reads a single plist
+// from its input.
+func (d *Decoder) Strict() {
+	d.strict = true
+}
+
+// SetLimits sets the limits on the resources spent decoding each plist.
+// Decoders use DefaultDecoderLimits until SetLimits is called. A plist which
+// exceeds a limit returns an error wrapping a *LimitError.
+func (d *Decoder) SetLimits(limits DecoderLimits) {
+	d.limits = limits
+}
+
+// parseDocument detects the format of the next plist if needed, and parses
+// it with the parser for its format.
+func (d *Decoder) parseDocument() (*plistValue, error) {
+	if err := d.detectFormat(); err != nil {
+		return nil, err
+	}
+	return d.parseFormat()
+}
+
+// detectFormat detects the format of the next plist, if the decoder detects
+// formats.
+func (d *Decoder) detectFormat() error {
+	if d.detect {
+		prefix, err := d.sniff()
 		if err != nil {
 			return err
 		}
+		d.format = detectFormat(prefix)
 	}
-	return d.unmarshal(pval, val.Elem())
+	return nil
 }
 
-func (d *Decoder) unmarshal(pval *plistValue, v reflect.Value) error {
-	// check for empty interface v type
-	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
-		val := reflect.ValueOf(d.valueInterface(pval))
-		if !val.IsValid() {
-			return fmt.Errorf("plist: invalid reflect.Value %v", v)
+// parseFormat parses the next plist with the parser for the format of the
+// decoder.
+func (d *Decoder) parseFormat() (*plistValue, error) {
+	var pval *plistValue
+	switch d.format {
+	case BinaryFormat:
+		parser, err := d.newBinaryParser()
+		if err != nil {
+			return nil, err
 		}
-		v.Set(val)
-		return nil
+		parser.strict = d.strict
+		parser.limits = newLimiter(d.limits)
+		pval, err = parser.parseDocument()
+		if err != nil {
+			return nil, err
+		}
+	case OpenStepFormat, GNUStepFormat:
+		data, err := ioutil.ReadAll(d.reader)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			return nil, io.EOF
+		}
+		parser := newTextParser(data)
+		parser.strict = d.strict
+		parser.limits = newLimiter(d.limits)
+		pval, err = parser.parseDocument()
+		if err != nil {
+			return nil, err
+		}
+		if d.detect && parser.gnustep {
+			d.format = GNUStepFormat
+		}
+	case JSONFormat, TypedJSONFormat:
+		var err error
+		parser := newJSONParser(d.reader, d.format == TypedJSONFormat)
+		parser.strict = d.strict
+		parser.limits = newLimiter(d.limits)
+		pval, err = parser.parseDocument()
+		if err != nil {
+			return nil, err
+		}
+		// Give back what json.Decoder read past the end of the document.
+		d.reader = io.MultiReader(parser.Buffered(), d.reader)
+	default:
+		var err error
+		pval, err = d.newXMLParser().parseDocument()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pval, nil
+}
+
+// newXMLParser returns a parser for the XML plist read by the decoder. The
+// parser reads through a bufio.Reader, which is kept for subsequent reads.
+func (d *Decoder) newXMLParser() *xmlParser {
+	if _, ok := d.reader.(*bufio.Reader); !ok {
+		d.reader = bufio.NewReader(d.reader)
 	}
+	parser := newXMLParser(d.reader)
+	parser.strict = d.strict
+	parser.limits = newLimiter(d.limits)
+	return parser
+}
 
-	if v.Kind() == reflect.Ptr {
-		if v.IsNil() {
-			v.Set(reflect.New(v.Type().Elem()))
+// newBinaryParser returns a parser for the binary plist read by the decoder.
+// Binary plists need random access: the input of Unmarshal is parsed in
+// place, readers which are both an io.ReaderAt and an io.Seeker are read as
+// needed, and anything else, including pipes, is read into memory first.
+func (d *Decoder) newBinaryParser() (*binaryParser, error) {
+	if d.data != nil {
+		return newBinaryParser(d.data)
+	}
+	if rs, ok := d.seeker(); ok {
+		size, err := rs.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		if ra, ok := rs.(io.ReaderAt); ok {
+			return newBinaryParserAt(ra, size)
 		}
-		v = v.Elem()
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	data, err := ioutil.ReadAll(d.reader)
+	if err != nil {
+		return nil, err
 	}
+	return newBinaryParser(data)
+}
+
+// A decoderFunc decodes plist values into values of the type it was made
+// for.
+type decoderFunc func(d *Decoder, pval *plistValue, v reflect.Value) error
+
+var decoderCache sync.Map // map[reflect.Type]decoderFunc
+
+var unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
+func (d *Decoder) unmarshal(pval *plistValue, v reflect.Value) error {
+	return typeDecoder(v.Type())(d, pval, v)
+}
 
-	unmarshalerType := reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+// typeDecoder returns the decoder for values of type t. Like typeEncoder, it
+// is made on first use and cached.
+func typeDecoder(t reflect.Type) decoderFunc {
+	if f, ok := decoderCache.Load(t); ok {
+		return f.(decoderFunc)
+	}
 
-	if v.CanInterface() && v.Type().Implements(unmarshalerType) {
-		u := v.Interface().(Unmarshaler)
-		return u.UnmarshalPlist(func(i interface{}) error {
-			return d.unmarshal(pval, reflect.ValueOf(i))
-		})
+	// Recursive types look up their own decoder while it is being made, and
+	// get one which waits for it.
+	var (
+		wg sync.WaitGroup
+		f  decoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := decoderCache.LoadOrStore(t, decoderFunc(func(d *Decoder, pval *plistValue, v reflect.Value) error {
+		wg.Wait()
+		return f(d, pval, v)
+	}))
+	if loaded {
+		return fi.(decoderFunc)
 	}
+	f = newTypeDecoder(t)
+	wg.Done()
+	decoderCache.Store(t, f)
+	return f
+}
 
-	if v.CanAddr() {
-		pv := v.Addr()
-		if pv.CanInterface() && pv.Type().Implements(unmarshalerType) {
-			u := pv.Interface().(Unmarshaler)
-			return u.UnmarshalPlist(func(i interface{}) error {
-				return d.unmarshal(pval, reflect.ValueOf(i))
-			})
+// newTypeDecoder makes the decoder for values of type t.
+func newTypeDecoder(t reflect.Type) decoderFunc {
+	switch t {
+	case valueType, valuePtrType:
+		return func(d *Decoder, pval *plistValue, v reflect.Value) error {
+			switch d.format {
+			case BinaryFormat:
+				// Binary plists share values between references to an
+				// object, which mustn't see each other's changes.
+				pval = pval.copy()
+			case XMLFormat:
+				// Values hold UIDs as such, which XML plists write as
+				// CF$UID dictionaries.
+				pval = dictionaryUIDs(pval)
+			}
+			if t == valueType {
+				v.Set(reflect.ValueOf(Value(*pval)))
+			} else {
+				v.Set(reflect.ValueOf((*Value)(pval)))
+			}
+			return nil
 		}
+	}
+
+	var next decoderFunc
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		// empty interface values
+		next = func(d *Decoder, pval *plistValue, v reflect.Value) error {
+			val := reflect.ValueOf(d.valueInterface(pval))
+			if !val.IsValid() {
+				return fmt.Errorf("plist: invalid reflect.Value %v", v)
+			}
+			v.Set(val)
+			return nil
+		}
+	} else {
+		next = newIndirectDecoder(t, newUnmarshalerDecoder)
+	}
 
+	// Like encoding/json, null sets pointers, interfaces, maps and slices to
+	// nil, and leaves other values unchanged.
+	var nullable bool
+	switch t.Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+		nullable = true
 	}
+	return func(d *Decoder, pval *plistValue, v reflect.Value) error {
+		if pval.kind == NullKind {
+			if nullable {
+				v.Set(reflect.Zero(t))
+			}
+			return nil
+		}
+		return next(d, pval, v)
+	}
+}
 
-	// change pointer values to the correct type
-	// ex type foo struct {
-	// 			  Foo *string `plist:"foo"
-	//		   }
-	if v.Kind() == reflect.Ptr {
+// newIndirectDecoder makes a decoder which allocates the value a pointer of
+// type t points to, if needed, and decodes into it with the decoder newElem
+// makes for its type, as for fields like Foo *string. If t isn't a pointer,
+// it returns newElem(t).
+func newIndirectDecoder(t reflect.Type, newElem func(reflect.Type) decoderFunc) decoderFunc {
+	if t.Kind() != reflect.Ptr {
+		return newElem(t)
+	}
+	elemType := t.Elem()
+	elem := newElem(elemType)
+	return func(d *Decoder, pval *plistValue, v reflect.Value) error {
 		if v.IsNil() {
-			v.Set(reflect.New(v.Type().Elem()))
+			v.Set(reflect.New(elemType))
 		}
-		v = v.Elem()
+		return elem(d, pval, v.Elem())
+	}
+}
 
+// newUnmarshalerDecoder makes the decoder for values of type t which uses
+// their UnmarshalPlist method, if they have one.
+func newUnmarshalerDecoder(t reflect.Type) decoderFunc {
+	if t == valueDecoderType {
+		return func(d *Decoder, pval *plistValue, v reflect.Value) error {
+			v.Set(reflect.ValueOf(ValueDecoder{d: d, pval: pval, index: -1}))
+			return nil
+		}
 	}
+	next := newIndirectDecoder(t, newKindDecoder)
+	switch {
+	case t.Implements(unmarshalerType):
+		return func(d *Decoder, pval *plistValue, v reflect.Value) error {
+			if !v.CanInterface() {
+				return next(d, pval, v)
+			}
+			return d.unmarshalUnmarshaler(pval, v.Interface().(Unmarshaler))
+		}
+	case reflect.PtrTo(t).Implements(unmarshalerType):
+		return func(d *Decoder, pval *plistValue, v reflect.Value) error {
+			if !v.CanAddr() || !v.Addr().CanInterface() {
+				return next(d, pval, v)
+			}
+			return d.unmarshalUnmarshaler(pval, v.Addr().Interface().(Unmarshaler))
+		}
+	}
+	return next
+}
 
-	switch pval.kind {
-	case String:
-		return d.unmarshalString(pval, v)
-	case Dictionary:
-		return d.unmarshalDictionary(pval, v)
-	case Array:
-		return d.unmarshalArray(pval, v)
-	case Boolean:
-		return d.unmarshalBoolean(pval, v)
-	case Real:
-		return d.unmarshalReal(pval, v)
-	case Integer:
-		return d.unmarshalInteger(pval, v)
-	case Data:
-		return d.unmarshalData(pval, v)
-	case Date:
-		return d.unmarshalDate(pval, v)
-	default:
-		return fmt.Errorf("plist: %v is an unsuported plist element kind", pval.kind)
+func (d *Decoder) unmarshalUnmarshaler(pval *plistValue, u Unmarshaler) error {
+	return u.UnmarshalPlist(func(i interface{}) error {
+		return d.unmarshal(pval, reflect.ValueOf(i))
+	})
+}
+
+// newKindDecoder makes the decoder for values of type t which decodes each
+// kind of plist value.
+func newKindDecoder(t reflect.Type) decoderFunc {
+	dict := newDictionaryDecoder(t)
+	array := newArrayDecoder(t)
+	set := newSetDecoder(t, array)
+	return func(d *Decoder, pval *plistValue, v reflect.Value) error {
+		switch pval.kind {
+		case String:
+			return d.unmarshalString(pval, v)
+		case Dictionary:
+			if uid, ok := dictionaryUID(pval.value.(*dictionary)); ok && isUint(v.Kind()) {
+				return d.unmarshalUID(&plistValue{UIDKind, uid}, v)
+			}
+			return dict(d, pval, v)
+		case Array:
+			return array(d, pval, v)
+		case Boolean:
+			return d.unmarshalBoolean(pval, v)
+		case Real:
+			return d.unmarshalReal(pval, v)
+		case Integer:
+			return d.unmarshalInteger(pval, v)
+		case Data:
+			return d.unmarshalData(pval, v)
+		case Date:
+			return d.unmarshalDate(pval, v)
+		case UIDKind:
+			return d.unmarshalUID(pval, v)
+		case SetKind, OrderedSetKind:
+			return set(d, pval, v)
+		default:
+			return fmt.Errorf("plist: %v is an unsuported plist element kind", pval.kind)
+		}
+	}
+}
+
+// unmarshalElem decodes an element of a dictionary or array, which elem names
+// in the path of errors.
+func (d *Decoder) unmarshalElem(pval *plistValue, v reflect.Value, elem string) error {
+	return d.decodeElem(typeDecoder(v.Type()), pval, v, elem)
+}
+
+// decodeElem is like unmarshalElem, with the decoder for the type of v.
+func (d *Decoder) decodeElem(dec decoderFunc, pval *plistValue, v reflect.Value, elem string) error {
+	d.path = append(d.path, elem)
+	err := d.collect(dec(d, pval, v))
+	d.path = d.path[:len(d.path)-1]
+	return err
+}
+
+// collect records err and returns nil if it is a type error and the decoder
+// collects errors.
+func (d *Decoder) collect(err error) error {
+	if terr, ok := err.(UnmarshalTypeError); ok && d.collectErrors {
+		d.errs = append(d.errs, terr)
+		return nil
 	}
+	return err
 }
 
 func (d *Decoder) unmarshalDate(pval *plistValue, v reflect.Value) error {
 	if v.Type() != reflect.TypeOf((*time.Time)(nil)).Elem() {
-		return UnmarshalTypeError{fmt.Sprintf("%v", pval.value), v.Type()}
+		return d.typeError(fmt.Sprintf("%v", pval.value), v.Type())
 	}
 	v.Set(reflect.ValueOf(pval.value.(time.Time)))
 	return nil
 }
 
+func (d *Decoder) unmarshalUID(pval *plistValue, v reflect.Value) error {
+	if !isUint(v.Kind()) {
+		return d.typeError(fmt.Sprintf("uid %d", pval.value.(UID)), v.Type())
+	}
+	v.SetUint(uint64(pval.value.(UID)))
+	return nil
+}
+
+// isUint reports whether k is an unsigned integer kind, which UIDs decode
+// into.
+func isUint(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
 func (d *Decoder) unmarshalData(pval *plistValue, v reflect.Value) error {
 	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() != reflect.Uint8 {
-		return UnmarshalTypeError{fmt.Sprintf("%s", pval.value.([]byte)), v.Type()}
+		return d.typeError(fmt.Sprintf("%s", pval.value.([]byte)), v.Type())
 	}
-	v.SetBytes(pval.value.([]byte))
+	v.SetBytes(d.dataBytes(pval))
 	return nil
 }
 
+// dataBytes returns the bytes of the data value pval. The data of binary
+// plists shares memory with the plist and with other references to the
+// object, so it is copied.
+func (d *Decoder) dataBytes(pval *plistValue) []byte {
+	data := pval.value.([]byte)
+	if d.format == BinaryFormat && data != nil {
+		data = append([]byte{}, data...)
+	}
+	return data
+}
+
 func (d *Decoder) unmarshalReal(pval *plistValue, v reflect.Value) error {
 	if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
-		return UnmarshalTypeError{fmt.Sprintf("%v", pval.value.(sizedFloat).value), v.Type()}
+		return d.typeError(fmt.Sprintf("%v", pval.value.(sizedFloat).value), v.Type())
 	}
 	v.SetFloat(pval.value.(sizedFloat).value)
 	return nil
@@ -182,58 +561,189 @@ func (d *Decoder) unmarshalReal(pval *plistValue, v reflect.Value) error {
 
 func (d *Decoder) unmarshalBoolean(pval *plistValue, v reflect.Value) error {
 	if v.Kind() != reflect.Bool {
-		return UnmarshalTypeError{fmt.Sprintf("%v", pval.value), v.Type()}
+		return d.typeError(fmt.Sprintf("%v", pval.value), v.Type())
 	}
 	v.SetBool(pval.value.(bool))
 	return nil
 }
 
-func (d *Decoder) unmarshalDictionary(pval *plistValue, v reflect.Value) error {
-	subvalues := pval.value.(*dictionary).m
-	switch v.Kind() {
+func newDictionaryDecoder(t reflect.Type) decoderFunc {
+	if t == orderedMapType {
+		return func(d *Decoder, pval *plistValue, v reflect.Value) error {
+			return d.unmarshalOrderedMap(pval, v)
+		}
+	}
+	switch t.Kind() {
 	case reflect.Struct:
-		fields := cachedTypeFields(v.Type())
-		for _, field := range fields {
-			if _, ok := subvalues[field.name]; !ok {
+		return newStructDecoder(t)
+	case reflect.Map:
+		return newMapDecoder(t)
+	default:
+		return func(d *Decoder, pval *plistValue, v reflect.Value) error {
+			return d.typeError("dict", v.Type())
+		}
+	}
+}
+
+func newStructDecoder(t reflect.Type) decoderFunc {
+	fields := cachedTypeFields(t)
+	decoders := make([]decoderFunc, len(fields))
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		decoders[i] = typeDecoder(typeByIndex(t, field.index))
+		names[i] = field.name
+	}
+	return func(d *Decoder, pval *plistValue, v reflect.Value) error {
+		subvalues := pval.value.(*dictionary).m
+		if d.disallowUnknownFields {
+			if err := d.checkFields(subvalues, names); err != nil {
+				return err
+			}
+		}
+		for i, field := range fields {
+			sval, ok := subvalues[field.name]
+			if !ok {
 				continue
 			}
-			if err := d.unmarshal(subvalues[field.name], field.value(v)); err != nil {
+			if err := d.decodeElem(decoders[i], sval, field.value(v), field.name); err != nil {
 				return err
 			}
 		}
-	case reflect.Map:
+		return nil
+	}
+}
+
+func newMapDecoder(t reflect.Type) decoderFunc {
+	keyType, elemType := t.Key(), t.Elem()
+	elem := typeDecoder(elemType)
+	return func(d *Decoder, pval *plistValue, v reflect.Value) error {
 		if v.IsNil() {
-			v.Set(reflect.MakeMap(v.Type()))
+			v.Set(reflect.MakeMap(t))
 		}
-		for k, sval := range subvalues {
-			keyv := reflect.ValueOf(k).Convert(v.Type().Key())
+		for k, sval := range pval.value.(*dictionary).m {
+			keyv := reflect.ValueOf(k).Convert(keyType)
 			mapElem := v.MapIndex(keyv)
 			if !mapElem.IsValid() {
-				mapElem = reflect.New(v.Type().Elem()).Elem()
+				mapElem = reflect.New(elemType).Elem()
 			}
-			if err := d.unmarshal(sval, mapElem); err != nil {
+			// Keys whose values failed to decode are left out rather than
+			// set to a zero value.
+			nerrs := len(d.errs)
+			if err := d.decodeElem(elem, sval, mapElem, k); err != nil {
 				return err
 			}
-			v.SetMapIndex(keyv, mapElem)
+			if len(d.errs) == nerrs {
+				v.SetMapIndex(keyv, mapElem)
+			}
 		}
-	default:
-		return UnmarshalTypeError{"dict", v.Type()}
+		return nil
 	}
-	return nil
+}
+
+// checkFields returns an error for the first key of subvalues, in sorted
+// order, which isn't one of the field names.
+func (d *Decoder) checkFields(subvalues map[string]*plistValue, names []string) error {
+	known := make(map[string]bool, len(names))
+	for _, name := range names {
+		known[name] = true
+	}
+	var unknown []string
+	for k := range subvalues {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("plist: unknown field %s", formatPath([]string{formatPath(d.path), unknown[0]}))
 }
 
 func (d *Decoder) unmarshalString(pval *plistValue, v reflect.Value) error {
 	if v.Kind() != reflect.String {
-		return UnmarshalTypeError{fmt.Sprintf("%s", pval.value.(string)), v.Type()}
+		switch d.format {
+		case OpenStepFormat, GNUStepFormat:
+			return d.unmarshalTextString(pval, v)
+		case JSONFormat:
+			return d.unmarshalJSONString(pval, v)
+		}
+		return d.typeError(fmt.Sprintf("%s", pval.value.(string)), v.Type())
 	}
 	v.SetString(pval.value.(string))
 	return nil
 }
 
-func (d *Decoder) unmarshalArray(pval *plistValue, v reflect.Value) error {
-	subvalues := pval.value.([]*plistValue)
+// unmarshalTextString converts the string pval to the kind of plist value
+// which v holds, and decodes the result into v. Text plists store all
+// scalars as strings.
+func (d *Decoder) unmarshalTextString(pval *plistValue, v reflect.Value) error {
+	s := pval.value.(string)
+	var converted *plistValue
 	switch v.Kind() {
-	case reflect.Slice:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, err := strconv.ParseInt(s, 0, 64); err == nil {
+			converted = &plistValue{Integer, signedInt{uint64(i), true}}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u, err := strconv.ParseUint(s, 0, 64); err == nil {
+			converted = &plistValue{Integer, signedInt{u, false}}
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			converted = &plistValue{Real, sizedFloat{f, 64}}
+		}
+	case reflect.Bool:
+		switch {
+		case s == "YES", !d.strict && (s == "Yes" || s == "yes" || s == "true" || s == "1"):
+			converted = &plistValue{Boolean, true}
+		case s == "NO", !d.strict && (s == "No" || s == "no" || s == "false" || s == "0"):
+			converted = &plistValue{Boolean, false}
+		}
+	case reflect.Struct:
+		if v.Type() != reflect.TypeOf((*time.Time)(nil)).Elem() {
+			break
+		}
+		for _, layout := range []string{textDateFormat, time.RFC3339} {
+			if t, err := time.Parse(layout, s); err == nil {
+				converted = &plistValue{Date, t}
+				break
+			}
+		}
+	}
+	if converted == nil {
+		return d.typeError(s, v.Type())
+	}
+	return d.unmarshal(converted, v)
+}
+
+// unmarshalJSONString decodes base64 strings into []byte and RFC 3339
+// strings into time.Time, which is how data and dates appear in JSON plists.
+func (d *Decoder) unmarshalJSONString(pval *plistValue, v reflect.Value) error {
+	s := pval.value.(string)
+	switch {
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		if data, err := base64.StdEncoding.DecodeString(s); err == nil {
+			return d.unmarshal(&plistValue{Data, data}, v)
+		}
+	case v.Type() == reflect.TypeOf((*time.Time)(nil)).Elem():
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return d.unmarshal(&plistValue{Date, t}, v)
+		}
+	}
+	return d.typeError(s, v.Type())
+}
+
+func newArrayDecoder(t reflect.Type) decoderFunc {
+	if t.Kind() != reflect.Slice {
+		return func(d *Decoder, pval *plistValue, v reflect.Value) error {
+			return d.typeError("array", v.Type())
+		}
+	}
+	elem := typeDecoder(t.Elem())
+	return func(d *Decoder, pval *plistValue, v reflect.Value) error {
+		subvalues := pval.value.([]*plistValue)
 		// Slice of element values.
 		// Grow slice.
 		// Borrowed from https://golang.org/src/encoding/xml/read.go
@@ -243,23 +753,54 @@ func (d *Decoder) unmarshalArray(pval *plistValue, v reflect.Value) error {
 			if ncap < 4 {
 				ncap = 4
 			}
-			new := reflect.MakeSlice(v.Type(), v.Len(), ncap)
+			new := reflect.MakeSlice(t, v.Len(), ncap)
 			reflect.Copy(new, v)
 			v.Set(new)
 		}
-		n := v.Len()
+		// Like encoding/json, replace the elements of a non-empty slice,
+		// rather than index past its end.
 		v.SetLen(cnt)
-		for _, sval := range subvalues {
-			if err := d.unmarshal(sval, v.Index(n)); err != nil {
-				v.SetLen(cnt)
+		for i, sval := range subvalues {
+			if err := d.decodeElem(elem, sval, v.Index(i), indexPath(i)); err != nil {
 				return err
 			}
-			n++
 		}
-	default:
-		return UnmarshalTypeError{"array", v.Type()}
+		return nil
+	}
+}
+
+func newSetDecoder(t reflect.Type, array decoderFunc) decoderFunc {
+	if t.Kind() != reflect.Map {
+		return array
+	}
+	// Sets decode into the keys of maps with struct{} or bool values.
+	elemType := t.Elem()
+	if elemType.Kind() != reflect.Bool && (elemType.Kind() != reflect.Struct || elemType.NumField() != 0) {
+		return func(d *Decoder, pval *plistValue, v reflect.Value) error {
+			return d.typeError(pval.kind.String(), v.Type())
+		}
+	}
+	key := typeDecoder(t.Key())
+	return func(d *Decoder, pval *plistValue, v reflect.Value) error {
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		elem := reflect.New(elemType).Elem()
+		if elemType.Kind() == reflect.Bool {
+			elem.SetBool(true)
+		}
+		for i, sval := range pval.value.([]*plistValue) {
+			keyv := reflect.New(t.Key()).Elem()
+			nerrs := len(d.errs)
+			if err := d.decodeElem(key, sval, keyv, indexPath(i)); err != nil {
+				return err
+			}
+			if len(d.errs) == nerrs {
+				v.SetMapIndex(keyv, elem)
+			}
+		}
+		return nil
 	}
-	return nil
 }
 
 func (d *Decoder) unmarshalInteger(pval *plistValue, v reflect.Value) error {
@@ -270,13 +811,21 @@ func (d *Decoder) unmarshalInteger(pval *plistValue, v reflect.Value) error {
 		reflect.Uint32, reflect.Uint64, reflect.Uintptr:
 		// Make sure plistValue isn't negative when decoding into uint.
 		if pval.value.(signedInt).signed {
-			return UnmarshalTypeError{
-				fmt.Sprintf("%v", int64(pval.value.(signedInt).value)), v.Type()}
+			return d.typeError(fmt.Sprintf("%v", int64(pval.value.(signedInt).value)), v.Type())
 		}
 		v.SetUint(pval.value.(signedInt).value)
+	case reflect.Float32, reflect.Float64:
+		// JSON doesn't tell integers and reals apart.
+		if d.format != JSONFormat {
+			return d.typeError(fmt.Sprintf("%v", pval.value.(signedInt).value), v.Type())
+		}
+		if pval.value.(signedInt).signed {
+			v.SetFloat(float64(int64(pval.value.(signedInt).value)))
+		} else {
+			v.SetFloat(float64(pval.value.(signedInt).value))
+		}
 	default:
-		return UnmarshalTypeError{
-			fmt.Sprintf("%v", pval.value.(signedInt).value), v.Type()}
+		return d.typeError(fmt.Sprintf("%v", pval.value.(signedInt).value), v.Type())
 	}
 	return nil
 }
@@ -309,9 +858,15 @@ func (d *Decoder) valueInterface(pval *plistValue) interface{} {
 	case Dictionary:
 		return d.dictionaryInterface(pval.value.(*dictionary))
 	case Data:
-		return pval.value.([]byte)
+		return d.dataBytes(pval)
 	case Date:
 		return pval.value.(time.Time)
+	case UIDKind:
+		return pval.value.(UID)
+	case SetKind:
+		return Set(d.arrayInterface(pval.value.([]*plistValue)))
+	case OrderedSetKind:
+		return OrderedSet(d.arrayInterface(pval.value.([]*plistValue)))
 	default:
 		return nil
 	}
@@ -333,13 +888,37 @@ func (d *Decoder) dictionaryInterface(dict *dictionary) map[string]interface{} {
 	return out
 }
 
+// typeError returns an UnmarshalTypeError for the value being decoded.
+func (d *Decoder) typeError(value string, typ reflect.Type) error {
+	return UnmarshalTypeError{Value: value, Type: typ, Path: formatPath(d.path)}
+}
+
 // An UnmarshalTypeError describes a plist value that was
 // not appropriate for a value of a specific Go type.
 type UnmarshalTypeError struct {
 	Value string // description of plist value - "true", "string", "date"
 	Type  reflect.Type
+	Path  string // keys and indices leading to the value, as in "Items[2].Name"
 }
 
 func (e UnmarshalTypeError) Error() string {
-	return "plist: cannot unmarshal " + e.Value + " into Go value of type " + e.Type.String()
+	msg := "plist: cannot unmarshal " + e.Value + " into Go value of type " + e.Type.String()
+	if e.Path != "" {
+		msg += " (in " + e.Path + ")"
+	}
+	return msg
+}
+
+// An ErrorList is returned by Decode when the decoder collects errors and
+// some plist values didn't fit the Go values they were decoded into.
+type ErrorList []UnmarshalTypeError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0].Error(), len(l)-1)
 }
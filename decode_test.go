@@ -3,14 +3,20 @@ package plist
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/xml"
+	"errors"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 )
 
@@ -71,11 +77,14 @@ func TestDecodeDict(t *testing.T) {
 		Size                  uint64 `plist:"size"`
 	}
 
-	if err := Unmarshal([]byte(indentRef), &sparseBundleHeader); err != nil {
-		t.Fatal(err)
-	}
-	if sparseBundleHeader != expected {
-		t.Error("Expected", expected, "got", sparseBundleHeader)
+	for _, out := range withGenerated(&sparseBundleHeader) {
+		reflect.ValueOf(out).Elem().Set(reflect.Zero(reflect.TypeOf(out).Elem()))
+		if err := Unmarshal([]byte(indentRef), out); err != nil {
+			t.Fatal(err)
+		}
+		if sparseBundleHeader != expected {
+			t.Errorf("%T: Expected %v got %v", out, expected, sparseBundleHeader)
+		}
 	}
 
 	// Test Map
@@ -254,6 +263,37 @@ func TestDecodeUnicodeString(t *testing.T) {
 	}
 }
 
+func TestDecodeXMLMarkup(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want interface{}
+	}{
+		{"entities", `<plist><string>&lt;a&gt; &amp; &quot;b&quot; &apos;c&apos; &#65;&#x42;&#x1F600;</string></plist>`, `<a> & "b" 'c' AB😀`},
+		{"cdata", `<plist><string>a<![CDATA[<b> & ]]]]>c</string></plist>`, "a<b> & ]]c"},
+		{"comments", `<plist><!-- a --><array><!-- b --><string>c<!-- d -->e</string></array></plist>`, []interface{}{"ce"}},
+		{"line endings", "<plist><string>a\r\nb\rc\nd&#13;</string></plist>", "a\nb\nc\nd\r"},
+		{"internal subset", `<!DOCTYPE plist [<!ELEMENT plist (string)> <!-- "> --> <!ATTLIST plist version CDATA "1.0">]><plist><string>a</string></plist>`, "a"},
+		{"processing instructions", `<?xml version='1.0' encoding='utf-8'?><?app data?><plist><?app data?><true/></plist>`, true},
+		{"empty elements", `<plist><dict><key/><string/><key>b</key><integer>1</integer></dict></plist>`, map[string]interface{}{"": "", "b": uint64(1)}},
+		{"attributes", `<plist version = '1.0' a="&lt;'>"><real>1.5</real></plist>`, 1.5},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			for _, r := range []io.Reader{strings.NewReader(tt.in), iotest.OneByteReader(strings.NewReader(tt.in))} {
+				var v interface{}
+				if err := NewXMLDecoder(r).Decode(&v); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(v, tt.want) {
+					t.Errorf("have %#v, want %#v", v, tt.want)
+				}
+			}
+		})
+	}
+}
+
 // Unknown struct fields should return an error
 func TestDecodeUnknownStructField(t *testing.T) {
 	var sparseBundleHeader struct {
@@ -513,3 +553,983 @@ func TestDecodeTagSkip(t *testing.T) {
 		t.Error("field decoded when it was tagged as -")
 	}
 }
+
+func TestDecodeDetectFormat(t *testing.T) {
+	binaryPlist, err := ioutil.ReadFile(filepath.Join("testdata", "sample2.binary.plist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		in     []byte
+		format Format
+	}{
+		{"xml", []byte(indentRef), XMLFormat},
+		{"binary", binaryPlist, BinaryFormat},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// hide the Seek method of bytes.Reader, like an HTTP body.
+			r := struct{ io.Reader }{bytes.NewReader(tt.in)}
+			d := NewDecoder(r)
+			if have, want := d.Format(), AutomaticFormat; have != want {
+				t.Errorf("before Decode: have format %v, want %v", have, want)
+			}
+			var v map[string]interface{}
+			if err := d.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+			if have, want := d.Format(), tt.format; have != want {
+				t.Errorf("have format %v, want %v", have, want)
+			}
+			if len(v) == 0 {
+				t.Error("decoded empty dictionary")
+			}
+			if err := d.Decode(&v); err != io.EOF {
+				t.Errorf("err = %v; want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestDecodePipe(t *testing.T) {
+	binaryPlist, err := ioutil.ReadFile(filepath.Join("testdata", "sample2.binary.plist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		in     []byte
+		decode func(*os.File) *Decoder
+	}{
+		{"xml", []byte(indentRef), func(f *os.File) *Decoder { return NewDecoder(f) }},
+		{"binary", binaryPlist, func(f *os.File) *Decoder { return NewDecoder(f) }},
+		{"binary decoder", binaryPlist, func(f *os.File) *Decoder { return NewBinaryDecoder(f) }},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Pipes are *os.File values, so io.ReadSeekers, but can't seek.
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			go func() {
+				w.Write(tt.in)
+				w.Close()
+			}()
+			var v map[string]interface{}
+			if err := tt.decode(r).Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+			if len(v) == 0 {
+				t.Error("decoded empty dictionary")
+			}
+		})
+	}
+}
+
+func TestDecodeXMLSyntaxError(t *testing.T) {
+	tests := []struct {
+		name         string
+		in           string
+		line, column int
+		path         string
+		msg          string
+	}{
+		{
+			name:   "bad integer",
+			in:     "<plist>\n<dict>\n\t<key>Items</key>\n\t<array>\n\t\t<integer>1</integer>\n\t\t<integer>one</integer>\n\t</array>\n</dict>\n</plist>",
+			line:   6,
+			column: 3,
+			path:   "Items[1]",
+			msg:    `strconv.ParseUint: parsing "one": invalid syntax`,
+		},
+		{
+			name:   "missing key",
+			in:     "<plist><dict><key>a</key><dict><string>b</string></dict></dict></plist>",
+			line:   1,
+			column: 32,
+			path:   "a",
+			msg:    "missing key in dict",
+		},
+		{
+			name:   "unknown element",
+			in:     "<plist>\n  <foo/>\n</plist>",
+			line:   2,
+			column: 3,
+			msg:    "Unknown plist element foo",
+		},
+		{
+			name:   "bad xml",
+			in:     "<plist><array>\n<string>a</strin></array></plist>",
+			line:   2,
+			column: 18,
+			path:   "[0]",
+			msg:    "element <string> closed by </strin>",
+		},
+		{
+			name:   "unknown entity",
+			in:     "<plist><string>a &nbsp; b</string></plist>",
+			line:   1,
+			column: 24,
+			msg:    "invalid character entity &nbsp;",
+		},
+		{
+			name:   "double hyphen in comment",
+			in:     "<plist>\n<!-- a -- b -->\n<true/></plist>",
+			line:   2,
+			column: 11,
+			msg:    `invalid sequence "--" not allowed in comments`,
+		},
+		{
+			name:   "unescaped cdata end",
+			in:     "<plist><array><string>a]]>b</string></array></plist>",
+			line:   1,
+			column: 27,
+			path:   "[0]",
+			msg:    "unescaped ]]> not in CDATA section",
+		},
+		{
+			name:   "illegal character",
+			in:     "<plist><string>a\x01</string></plist>",
+			line:   1,
+			column: 18,
+			msg:    "illegal character code U+0001",
+		},
+		{
+			name:   "unexpected eof",
+			in:     "<plist><dict><key>a</key><string>b",
+			line:   1,
+			column: 35,
+			path:   "a",
+			msg:    "unexpected EOF",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			err := NewXMLDecoder(bytes.NewReader([]byte(tt.in))).Decode(&v)
+			serr, ok := err.(*SyntaxError)
+			if !ok {
+				t.Fatalf("have error %#v, want *SyntaxError", err)
+			}
+			if serr.Line != tt.line || serr.Column != tt.column {
+				t.Errorf("have line %d, column %d, want line %d, column %d", serr.Line, serr.Column, tt.line, tt.column)
+			}
+			if serr.Path != tt.path {
+				t.Errorf("have path %q, want %q", serr.Path, tt.path)
+			}
+			if serr.Msg != tt.msg {
+				t.Errorf("have message %q, want %q", serr.Msg, tt.msg)
+			}
+		})
+	}
+}
+
+func TestDecodeBinaryFormatError(t *testing.T) {
+	valid, err := MarshalBinary([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// bplist00, the array, its ref, then "a"
+	if !bytes.Equal(valid[8:12], []byte{0xa1, 0x01, 0x51, 'a'}) {
+		t.Fatalf("unexpected encoding % x", valid)
+	}
+	corrupt := func(i int, b byte) []byte {
+		data := append([]byte{}, valid...)
+		if i < 0 {
+			i += len(data)
+		}
+		data[i] = b
+		return data
+	}
+
+	tests := []struct {
+		name    string
+		in      []byte
+		err     error
+		object  int64
+		parents []uint64
+	}{
+		{"short", valid[:20], ErrTruncated, -1, nil},
+		{"object ref size", corrupt(-32+7, 0), ErrBadTrailer, -1, nil},
+		{"root object", corrupt(-32+23, 9), ErrBadTrailer, -1, nil},
+		{"offset table", corrupt(-33, 0xff), ErrBadOffset, 1, nil},
+		{"ref", corrupt(9, 0x05), ErrBadOffset, 0, nil},
+		{"marker", corrupt(10, 0x71), ErrBadMarker, 1, []uint64{0}},
+		{"count", corrupt(10, 0x5e), ErrTruncated, 1, []uint64{0}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			err := NewBinaryDecoder(bytes.NewReader(tt.in)).Decode(&v)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("have error %v, want %v", err, tt.err)
+			}
+			var ferr *BinaryFormatError
+			if !errors.As(err, &ferr) {
+				t.Fatalf("have error %T, want *BinaryFormatError", err)
+			}
+			if ferr.Object != tt.object || !reflect.DeepEqual(ferr.Parents, tt.parents) {
+				t.Errorf("have object %d in %v, want %d in %v", ferr.Object, ferr.Parents, tt.object, tt.parents)
+			}
+		})
+	}
+}
+
+func TestDecodeBinaryBadKey(t *testing.T) {
+	data, err := MarshalBinary(map[string]string{"a": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// bplist00, the dictionary and its refs, then "b" and "a"
+	if !bytes.Equal(data[8:15], []byte{0xd1, 0x02, 0x01, 0x51, 'b', 0x51, 'a'}) {
+		t.Fatalf("unexpected encoding % x", data)
+	}
+	data[13] = 0x10 // the key is now the integer 'a'
+
+	var v interface{}
+	err = NewBinaryDecoder(bytes.NewReader(data)).Decode(&v)
+	if !errors.Is(err, ErrBadKey) {
+		t.Errorf("Decode: have error %v, want ErrBadKey", err)
+	}
+	doc, err := OpenBinary(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doc.Root().Keys(); !errors.Is(err, ErrBadKey) {
+		t.Errorf("Keys: have error %v, want ErrBadKey", err)
+	}
+}
+
+func TestDecodeTypeErrorPath(t *testing.T) {
+	const data = `<plist><dict>
+	<key>QueryResponses</key><dict>
+		<key>OSUpdateSettings</key><array>
+			<dict><key>ProductKey</key><string>a</string></dict>
+			<dict><key>ProductKey</key><string>b</string></dict>
+			<dict><key>ProductKey</key><integer>3</integer></dict>
+		</array>
+	</dict>
+</dict></plist>`
+	var v struct {
+		QueryResponses map[string][]struct {
+			ProductKey string
+		}
+	}
+	err := Unmarshal([]byte(data), &v)
+	terr, ok := err.(UnmarshalTypeError)
+	if !ok {
+		t.Fatalf("have error %v, want UnmarshalTypeError", err)
+	}
+	if want := "QueryResponses.OSUpdateSettings[2].ProductKey"; terr.Path != want {
+		t.Errorf("have path %q, want %q", terr.Path, want)
+	}
+	if !strings.HasSuffix(err.Error(), "(in QueryResponses.OSUpdateSettings[2].ProductKey)") {
+		t.Errorf("error %q doesn't mention the path", err)
+	}
+
+	// The path of a previous Decode doesn't leak into the next one.
+	var s string
+	err = Unmarshal([]byte(`<plist><true/></plist>`), &s)
+	if terr, ok := err.(UnmarshalTypeError); !ok || terr.Path != "" {
+		t.Errorf("have error %v, want UnmarshalTypeError without a path", err)
+	}
+}
+
+func TestDecodeCollectErrors(t *testing.T) {
+	const data = `<plist><dict>
+	<key>Name</key><string>profile</string>
+	<key>Version</key><string>one</string>
+	<key>Payloads</key><array>
+		<dict><key>Type</key><string>a</string><key>Enabled</key><integer>1</integer></dict>
+		<dict><key>Type</key><true/><key>Enabled</key><true/></dict>
+	</array>
+</dict></plist>`
+	type payload struct {
+		Type    string
+		Enabled bool
+	}
+	var v struct {
+		Name     string
+		Version  int
+		Payloads []payload
+	}
+
+	if err := Unmarshal([]byte(data), &v); err == nil {
+		t.Fatal("expected an error")
+	} else if _, ok := err.(UnmarshalTypeError); !ok {
+		t.Fatalf("have error %T, want UnmarshalTypeError", err)
+	}
+
+	d := NewDecoder(strings.NewReader(data))
+	d.CollectErrors()
+	err := d.Decode(&v)
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("have error %v, want ErrorList", err)
+	}
+	var paths []string
+	for _, terr := range list {
+		paths = append(paths, terr.Path)
+	}
+	if want := []string{"Version", "Payloads[0].Enabled", "Payloads[1].Type"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("have paths %q, want %q", paths, want)
+	}
+	if !strings.HasSuffix(err.Error(), "(and 2 more errors)") {
+		t.Errorf("have error %q", err)
+	}
+	want := []payload{{Type: "a"}, {Enabled: true}}
+	if v.Name != "profile" || !reflect.DeepEqual(v.Payloads, want) {
+		t.Errorf("have %+v, want the fields which fit to be decoded", v)
+	}
+}
+
+func TestDecodeNonEmptySlice(t *testing.T) {
+	docs := map[string]string{
+		"xml":      `<plist><array><string>x</string><string>y</string><string>z</string></array></plist>`,
+		"openstep": `(x, y, z)`,
+	}
+	for name, doc := range docs {
+		for _, v := range [][]string{{"a"}, {"a", "b", "c", "d"}, make([]string, 1, 8)} {
+			if err := Unmarshal([]byte(doc), &v); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if want := []string{"x", "y", "z"}; !reflect.DeepEqual(v, want) {
+				t.Errorf("%s: have %q, want %q", name, v, want)
+			}
+		}
+	}
+}
+
+func TestDecodeCollectErrorsMap(t *testing.T) {
+	const data = `<plist><dict>
+	<key>a</key><string>x</string>
+	<key>b</key><integer>2</integer>
+	<key>c</key><string>y</string>
+</dict></plist>`
+	d := NewDecoder(strings.NewReader(data))
+	d.CollectErrors()
+	v := map[string]int{"c": 3}
+	err := d.Decode(&v)
+	if list, ok := err.(ErrorList); !ok || len(list) != 2 {
+		t.Fatalf("have error %v, want an ErrorList of 2 errors", err)
+	}
+	if want := map[string]int{"b": 2, "c": 3}; !reflect.DeepEqual(v, want) {
+		t.Errorf("have %v, want %v", v, want)
+	}
+}
+
+func TestDecodeDisallowUnknownFields(t *testing.T) {
+	const data = `<plist version="1.0"><dict>
+	<key>Payloads</key><array>
+		<dict><key>Name</key><string>a</string><key>Nmae</key><string>b</string></dict>
+	</array>
+</dict></plist>`
+	var v struct {
+		Payloads []struct {
+			Name string
+		}
+	}
+	if err := Unmarshal([]byte(data), &v); err != nil {
+		t.Fatalf("unknown fields are ignored by default, have error %v", err)
+	}
+	d := NewDecoder(strings.NewReader(data))
+	d.DisallowUnknownFields()
+	err := d.Decode(&v)
+	if err == nil || err.Error() != "plist: unknown field Payloads[0].Nmae" {
+		t.Errorf("have error %v, want unknown field Payloads[0].Nmae", err)
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		in     string
+	}{
+		{"duplicate key", XMLFormat, `<plist version="1.0"><dict><key>a</key><true/><key>a</key><false/></dict></plist>`},
+		{"key without value", XMLFormat, `<plist version="1.0"><dict><key>a</key><key>b</key><true/></dict></plist>`},
+		{"last key without value", XMLFormat, `<plist version="1.0"><dict><key>a</key></dict></plist>`},
+		{"stray text", XMLFormat, `<plist version="1.0"><array>a<true/></array></plist>`},
+		{"multiple roots", XMLFormat, `<plist version="1.0"><true/><false/></plist>`},
+		{"trailing data", XMLFormat, `<plist version="1.0"><true/></plist><plist version="1.0"><true/></plist>`},
+		{"no plist element", XMLFormat, `<true/>`},
+		{"no version", XMLFormat, `<plist><true/></plist>`},
+		{"wrong version", XMLFormat, `<plist version="2.0"><true/></plist>`},
+		{"boolean", XMLFormat, `<plist version="1.0"><true>yes</true></plist>`},
+		{"text duplicate key", OpenStepFormat, `{a = 1; a = 2;}`},
+		{"json duplicate key", JSONFormat, `{"a": 1, "a": 2}`},
+		{"json trailing data", JSONFormat, `{"a": 1} {}`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			if err := NewDecoder(strings.NewReader(tt.in)).Decode(&v); err != nil {
+				t.Fatalf("lenient decoding failed: %v", err)
+			}
+			d := NewDecoder(strings.NewReader(tt.in))
+			d.Strict()
+			if err := d.Decode(&v); err == nil {
+				t.Fatal("expected an error in strict mode")
+			}
+			if d.Format() != tt.format {
+				t.Errorf("have format %v, want %v", d.Format(), tt.format)
+			}
+		})
+	}
+
+	const valid = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>a</key>
+	<true/>
+</dict>
+</plist>
+<!-- comment -->
+`
+	d := NewDecoder(strings.NewReader(valid))
+	d.Strict()
+	var v map[string]bool
+	if err := d.Decode(&v); err != nil || !v["a"] {
+		t.Errorf("have %v, %v", v, err)
+	}
+
+	// Values of keys which match no field are checked too.
+	ignored := []string{
+		`<plist version="1.0"><dict><key>b</key><true>yes</true></dict></plist>`,
+		`<plist version="1.0"><dict><key>b</key><dict><key>c</key><true/><key>c</key><true/></dict></dict></plist>`,
+		`<plist version="1.0"><dict><key>b</key><array>c<true/></array></dict></plist>`,
+	}
+	for _, in := range ignored {
+		var s struct{ A bool }
+		if err := NewDecoder(strings.NewReader(in)).Decode(&s); err != nil {
+			t.Errorf("lenient decoding of %s failed: %v", in, err)
+		}
+		d := NewDecoder(strings.NewReader(in))
+		d.Strict()
+		if err := d.Decode(&s); err == nil {
+			t.Errorf("expected an error decoding %s in strict mode", in)
+		}
+	}
+
+	var b bool
+	d = NewOpenStepDecoder(strings.NewReader(`yes`))
+	d.Strict()
+	if err := d.Decode(&b); err == nil {
+		t.Error("expected an error decoding yes into a bool in strict mode")
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	nested := strings.Repeat("<array>", 10) + strings.Repeat("</array>", 10)
+	shared, err := MarshalBinary([]string{"a", "a", "a", "a", "a", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		in     string
+		limits DecoderLimits
+		limit  string
+	}{
+		{"xml depth", `<plist>` + nested + `</plist>`, DecoderLimits{MaxDepth: 5}, "MaxDepth"},
+		{"json depth", strings.Repeat("[", 10) + strings.Repeat("]", 10), DecoderLimits{MaxDepth: 5}, "MaxDepth"},
+		{"text depth", strings.Repeat("(", 10) + strings.Repeat(")", 10), DecoderLimits{MaxDepth: 5}, "MaxDepth"},
+		{"collection", `<plist><array><true/><true/><true/></array></plist>`, DecoderLimits{MaxCollection: 2}, "MaxCollection"},
+		{"string", `<plist><string>abcd</string></plist>`, DecoderLimits{MaxString: 3}, "MaxString"},
+		{"total", `<plist><dict><key>abc</key><string>abc</string></dict></plist>`, DecoderLimits{MaxTotal: 5}, "MaxTotal"},
+		{"shared objects", string(shared), DecoderLimits{MaxObjects: 5}, "MaxObjects"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			if err := Unmarshal([]byte(tt.in), &v); err != nil {
+				t.Fatalf("decoding with the default limits failed: %v", err)
+			}
+			d := NewDecoder(strings.NewReader(tt.in))
+			d.SetLimits(tt.limits)
+			err := d.Decode(&v)
+			var lerr *LimitError
+			if !errors.As(err, &lerr) {
+				t.Fatalf("have error %v, want a *LimitError", err)
+			}
+			if lerr.Limit != tt.limit {
+				t.Errorf("have limit %s, want %s", lerr.Limit, tt.limit)
+			}
+		})
+	}
+}
+
+// repeatReader reads prefix, followed by n bytes of chunk repeated.
+type repeatReader struct {
+	prefix string
+	chunk  string
+	n      int64
+	i      int // offset in chunk
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if r.prefix != "" {
+		n := copy(p, r.prefix)
+		r.prefix = r.prefix[n:]
+		return n, nil
+	}
+	if r.n == 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.n {
+		p = p[:r.n]
+	}
+	for i := range p {
+		p[i] = r.chunk[r.i]
+		r.i = (r.i + 1) % len(r.chunk)
+	}
+	r.n -= int64(len(p))
+	return len(p), nil
+}
+
+func TestDecodeXMLLimitsBoundAllocation(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		chunk  string
+		limit  string
+	}{
+		{"string", `<plist><string>`, "a", "MaxString"},
+		{"key", `<plist><dict><key>`, "a", "MaxString"},
+		{"data", `<plist><data>`, "A", "MaxString"},
+		{"integer", `<plist><integer>`, "1", "MaxString"},
+	}
+	for _, tt := range tests {
+		// A gigabyte of text, which must not be buffered.
+		r := &repeatReader{prefix: tt.prefix, chunk: tt.chunk, n: 1 << 30}
+		d := NewDecoder(r)
+		d.SetLimits(DecoderLimits{MaxString: 1024})
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		var v interface{}
+		err := d.Decode(&v)
+		runtime.ReadMemStats(&after)
+		var lerr *LimitError
+		if !errors.As(err, &lerr) || lerr.Limit != tt.limit {
+			t.Errorf("%s: have error %v, want a %s *LimitError", tt.name, err, tt.limit)
+		}
+		if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+			t.Errorf("%s: allocated %d bytes", tt.name, alloc)
+		}
+	}
+
+	// MaxTotal leaves less than MaxString for the second string.
+	d := NewDecoder(strings.NewReader(`<plist><array><string>abcd</string><string>efgh</string></array></plist>`))
+	d.SetLimits(DecoderLimits{MaxString: 6, MaxTotal: 6})
+	var v interface{}
+	var lerr *LimitError
+	if err := d.Decode(&v); !errors.As(err, &lerr) || lerr.Limit != "MaxTotal" {
+		t.Errorf("have error %v, want a MaxTotal *LimitError", err)
+	}
+}
+
+func TestDecodeBinaryCycle(t *testing.T) {
+	// An array whose only element is the array itself.
+	data := []byte("bplist00\xa1\x00\x08")
+	data = append(data, 0, 0, 0, 0, 0, 0, 1, 1)
+	data = append(data, 0, 0, 0, 0, 0, 0, 0, 1)
+	data = append(data, 0, 0, 0, 0, 0, 0, 0, 0)
+	data = append(data, 0, 0, 0, 0, 0, 0, 0, 10)
+	var v interface{}
+	err := Unmarshal(data, &v)
+	if !errors.Is(err, ErrCycle) {
+		t.Fatalf("have error %v, want ErrCycle", err)
+	}
+}
+
+// readSeeker hides all methods of its reader but Read and Seek.
+type readSeeker struct {
+	io.ReadSeeker
+}
+
+func TestDecodeBinarySharedObjects(t *testing.T) {
+	type shared struct {
+		A, B   []byte
+		Values []*Value
+	}
+	in := shared{
+		A:      []byte{1, 2, 3},
+		B:      []byte{1, 2, 3},
+		Values: []*Value{NewArray(NewString("x")), NewArray(NewString("x"))},
+	}
+	data, err := MarshalBinary(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoders := map[string]func() *Decoder{
+		"bytes": nil,
+		"reader at": func() *Decoder {
+			return NewBinaryDecoder(bytes.NewReader(data))
+		},
+		"read seeker": func() *Decoder {
+			return NewBinaryDecoder(readSeeker{bytes.NewReader(data)})
+		},
+	}
+	for name, newDecoder := range decoders {
+		var out shared
+		if newDecoder == nil {
+			err = Unmarshal(data, &out)
+		} else {
+			err = newDecoder().Decode(&out)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(out.A, in.A) || !reflect.DeepEqual(out.B, in.B) || len(out.Values) != 2 {
+			t.Fatalf("%s: have %v", name, out)
+		}
+		// The identical values are a single object of the binary plist,
+		// but decode into values which don't share memory.
+		out.A[0] = 9
+		out.Values[0].SetIndex(0, NewString("y"))
+		if out.B[0] != 1 || !out.Values[1].Equal(NewArray(NewString("x"))) {
+			t.Errorf("%s: changing one decoded value changed another", name)
+		}
+	}
+	if !bytes.Contains(data, []byte{0x43, 1, 2, 3}) {
+		t.Error("changing a decoded value changed the plist")
+	}
+}
+
+func TestDecodeXMLSinglePass(t *testing.T) {
+	type inner struct {
+		Name string
+		Tags []string
+	}
+	type outer struct {
+		Count   int
+		Inner   inner
+		Ptr     *inner
+		Items   []inner
+		Items2  []*inner
+		Map     map[string]int
+		Any     interface{}
+		Val     *Value
+		UID     UID
+		Data    []byte
+		Date    time.Time
+		Empty   []int
+		Ignored string `plist:"-"`
+	}
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0"><dict>
+	<key>Count</key><integer>3</integer>
+	<key>Unknown</key><dict><key>A</key><array><integer>not a number</integer></array></dict>
+	<key>Inner</key><dict><key>Name</key><string>in</string><key>Tags</key><array><string>a</string><string>b</string></array></dict>
+	<key>Ptr</key><dict><key>Name</key><string>ptr</string></dict>
+	<key>Items</key><array>
+		<dict><key>Name</key><string>0</string></dict>
+		<dict><key>Name</key><string>1</string><key>Extra</key><true/></dict>
+		<dict><key>Name</key><string>2</string></dict>
+		<dict><key>Name</key><string>3</string></dict>
+		<dict><key>Name</key><string>4</string></dict>
+	</array>
+	<key>Items2</key><array><dict><key>Name</key><string>p</string></dict></array>
+	<key>Map</key><dict><key>x</key><integer>1</integer></dict>
+	<key>Any</key><array><string>s</string><integer>2</integer></array>
+	<key>Val</key><dict><key>k</key><real>1.5</real></dict>
+	<key>UID</key><dict><key>CF$UID</key><integer>7</integer></dict>
+	<key>Data</key><data>aGVsbG8=</data>
+	<key>Date</key><date>2020-01-02T03:04:05Z</date>
+	<key>Empty</key><array/>
+	<key>Ignored</key><string>x</string>
+</dict></plist>`
+
+	var got outer
+	if err := Unmarshal([]byte(doc), &got); err != nil {
+		t.Fatal(err)
+	}
+	// The unknown key holds an invalid integer, which the tree parser
+	// rejects, so leave it out to compare with decoding the tree.
+	treeDoc := strings.Replace(doc, "not a number", "1", 1)
+	pval, err := NewXMLDecoder(strings.NewReader(treeDoc)).parseDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want outer
+	if err := (&Decoder{format: XMLFormat}).unmarshal(pval, reflect.ValueOf(&want).Elem()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("single pass decoded\n%+v\nwant\n%+v", got, want)
+	}
+	if got.Empty == nil || len(got.Items) != 5 || got.UID != 7 {
+		t.Errorf("decoded %+v", got)
+	}
+
+	// Type errors are reported with their paths, and skipped elements are
+	// read up to their end.
+	var mismatch struct {
+		Count string
+		Inner []int
+		Items []struct{ Name int }
+	}
+	d := NewDecoder(strings.NewReader(doc))
+	d.CollectErrors()
+	err = d.Decode(&mismatch)
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("error = %v, want an ErrorList", err)
+	}
+	var paths []string
+	for _, e := range list {
+		paths = append(paths, e.Path)
+	}
+	wantPaths := []string{"Count", "Inner", "Items[0].Name", "Items[1].Name", "Items[2].Name", "Items[3].Name", "Items[4].Name"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("error paths = %v, want %v", paths, wantPaths)
+	}
+
+	// Syntax errors in decoded elements keep their paths.
+	bad := strings.Replace(doc, "<string>2</string>", "<integer>x</integer>", 1)
+	err = Unmarshal([]byte(bad), &got)
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Path != "Items[2].Name" {
+		t.Errorf("error = %v, want a syntax error in Items[2].Name", err)
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	for _, format := range []string{"xml", "binary"} {
+		var data []byte
+		var err error
+		if format == "xml" {
+			data, err = Marshal(newBenchCommand())
+		} else {
+			data, err = MarshalBinary(newBenchCommand())
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(format, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var cmd benchCommand
+				if err := Unmarshal(data, &cmd); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUnmarshalLarge(b *testing.B) {
+	cmds := make([]benchCommand, 1000)
+	for i := range cmds {
+		cmds[i] = newBenchCommand()
+	}
+	data, err := MarshalIndent(cmds, "\t")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var v interface{}
+		if err := Unmarshal(data, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkXMLTokens compares reading the tokens of a large XML plist with
+// xmlScanner and with encoding/xml, which XML plists were parsed with before.
+func BenchmarkXMLTokens(b *testing.B) {
+	cmds := make([]benchCommand, 1000)
+	for i := range cmds {
+		cmds[i] = newBenchCommand()
+	}
+	data, err := MarshalIndent(cmds, "\t")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("scanner", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s := newXMLScanner(bytes.NewReader(data))
+			for {
+				_, err := s.token()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("encoding/xml", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			d := xml.NewDecoder(bytes.NewReader(data))
+			for {
+				_, err := d.Token()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func TestDecodeOrderedMap(t *testing.T) {
+	t.Parallel()
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+	<dict>
+		<key>Label</key>
+		<string>com.example.agent</string>
+		<key>ProgramArguments</key>
+		<array>
+			<string>/usr/local/bin/agent</string>
+			<string>--verbose</string>
+		</array>
+		<key>KeepAlive</key>
+		<dict>
+			<key>SuccessfulExit</key><false/>
+			<key>Crashed</key><true/>
+		</dict>
+		<key>Sockets</key>
+		<array>
+			<dict>
+				<key>SockServiceName</key>
+				<string>8080</string>
+				<key>SockFamily</key>
+				<string>IPv4</string>
+			</dict>
+		</array>
+		<key>Interval</key>
+		<integer>300</integer>
+	</dict>
+</plist>
+`
+	var m OrderedMap
+	if err := Unmarshal([]byte(doc), &m); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Label", "ProgramArguments", "KeepAlive", "SuccessfulExit", "Crashed", "Sockets", "Interval"}
+	if have := flattenKeys(&m); !reflect.DeepEqual(have, want) {
+		t.Errorf("have keys %q, want %q", have, want)
+	}
+	sockets, _ := m.Get("Sockets")
+	socket, ok := sockets.([]interface{})[0].(*OrderedMap)
+	if !ok {
+		t.Fatalf("decoded socket %#v, want *OrderedMap", sockets.([]interface{})[0])
+	}
+	if have := socket.Keys(); !reflect.DeepEqual(have, []string{"SockServiceName", "SockFamily"}) {
+		t.Errorf("have socket keys %q", have)
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.Indent("\t")
+	enc.SetKeyOrder(InsertionOrder)
+	if err := enc.Encode(&m); err != nil {
+		t.Fatal(err)
+	}
+	if have := buf.String(); have != doc {
+		t.Errorf("encoded\n%s\nwant\n%s", have, doc)
+	}
+
+	// OrderedMap fields of structs decoded in a single pass are decoded
+	// from the parsed dictionary.
+	var s struct {
+		KeepAlive *OrderedMap
+	}
+	if err := Unmarshal([]byte(doc), &s); err != nil {
+		t.Fatal(err)
+	}
+	if have := s.KeepAlive.Keys(); !reflect.DeepEqual(have, []string{"SuccessfulExit", "Crashed"}) {
+		t.Errorf("have KeepAlive keys %q", have)
+	}
+	var label struct {
+		Label OrderedMap
+	}
+	err := Unmarshal([]byte(doc), &label)
+	if _, ok := err.(UnmarshalTypeError); !ok {
+		t.Errorf("have error %v, want UnmarshalTypeError", err)
+	}
+}
+
+func TestDecodeXMLUIDDictionary(t *testing.T) {
+	t.Parallel()
+	doc := []byte(`<plist version="1.0"><dict><key>CF$UID</key><integer>3</integer></dict></plist>`)
+
+	var m map[string]int
+	if err := Unmarshal(doc, &m); err != nil || m["CF$UID"] != 3 {
+		t.Errorf("map: decoded %v, %v", m, err)
+	}
+	var s struct {
+		UID int `plist:"CF$UID"`
+	}
+	if err := Unmarshal(doc, &s); err != nil || s.UID != 3 {
+		t.Errorf("struct: decoded %+v, %v", s, err)
+	}
+	var i interface{}
+	if err := Unmarshal(doc, &i); err != nil || !reflect.DeepEqual(i, map[string]interface{}{"CF$UID": uint64(3)}) {
+		t.Errorf("interface: decoded %#v, %v", i, err)
+	}
+
+	// UIDs, unsigned integers and Values take the dictionary for a UID.
+	var uid UID
+	if err := Unmarshal(doc, &uid); err != nil || uid != 3 {
+		t.Errorf("uid: decoded %d, %v", uid, err)
+	}
+	var u uint32
+	if err := Unmarshal(doc, &u); err != nil || u != 3 {
+		t.Errorf("uint32: decoded %d, %v", u, err)
+	}
+	var v Value
+	if err := Unmarshal(doc, &v); err != nil {
+		t.Fatal(err)
+	}
+	if have, ok := v.AsUID(); !ok || have != 3 {
+		t.Errorf("Value: decoded %#v", v)
+	}
+}
+
+func TestDecodeXMLUnclosedPlist(t *testing.T) {
+	t.Parallel()
+	for _, doc := range []string{
+		`<plist><string>x</string>`,
+		`<plist version="1.0"><string>x</string><!-- trailing -->`,
+		`<plist><string>x</string><array><string>ignored</string>`,
+	} {
+		d := NewDecoder(strings.NewReader(doc))
+		var s string
+		if err := d.Decode(&s); err != nil || s != "x" {
+			t.Errorf("%s: decoded %q, %v", doc, s, err)
+			continue
+		}
+		if err := d.Decode(&s); err != io.EOF {
+			t.Errorf("%s: second Decode returned %v, want io.EOF", doc, err)
+		}
+	}
+
+	// The root value itself must be complete.
+	var s string
+	if err := Unmarshal([]byte(`<plist><string>x`), &s); err == nil {
+		t.Error("decoded an unclosed string")
+	}
+}
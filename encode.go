@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io"
 	"reflect"
+	"sync"
 	"time"
 )
 
@@ -13,11 +14,31 @@ type Marshaler interface {
 
 // Encoder ...
 type Encoder struct {
-	w io.Writer
+	w      io.Writer
+	format Format
 
-	indent string
+	indent   string
+	keyOrder KeyOrder
 }
 
+// KeyOrder is the order in which an Encoder writes the keys of dictionaries.
+type KeyOrder int
+
+const (
+	// SortedKeys writes the keys of every dictionary in sorted order. It is
+	// the default, which makes the output independent of how it was built.
+	SortedKeys KeyOrder = iota
+
+	// DeclarationOrder writes the fields of structs in the order they are
+	// declared in. Other dictionaries are sorted.
+	DeclarationOrder
+
+	// InsertionOrder writes the fields of structs in declaration order, and
+	// the keys of OrderedMaps and Values in the order they were decoded or
+	// set in. Maps, which have no order, are sorted.
+	InsertionOrder
+)
+
 // Marshal ...
 func Marshal(v interface{}) ([]byte, error) {
 	var buf bytes.Buffer
@@ -38,169 +59,431 @@ func MarshalIndent(v interface{}, indent string) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// NewEncoder returns a new encoder that writes to w.
+// MarshalBinary returns the binary plist encoding of v.
+func MarshalBinary(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewBinaryEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewEncoder returns a new encoder that writes an XML plist to w.
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w: w}
+	return &Encoder{w: w, format: XMLFormat}
+}
+
+// NewBinaryEncoder returns a new encoder that writes a binary plist to w.
+func NewBinaryEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, format: BinaryFormat}
+}
+
+// NewOpenStepEncoder returns a new encoder that writes an OpenStep plist to w.
+// Numbers, booleans and dates are written as strings, since the format
+// doesn't have types for them.
+func NewOpenStepEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, format: OpenStepFormat}
+}
+
+// NewGNUStepEncoder returns a new encoder that writes a GNUstep plist to w.
+// Numbers, booleans and dates are written as GNUstep typed literals.
+func NewGNUStepEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, format: GNUStepFormat}
+}
+
+// NewJSONEncoder returns a new encoder that writes a JSON plist to w, in the
+// format of plutil -convert json. Data is written as base64 strings and dates
+// as RFC 3339 strings.
+func NewJSONEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, format: JSONFormat}
+}
+
+// NewTypedJSONEncoder returns a new encoder that writes a typed JSON plist
+// to w. Typed JSON plists record the kind of every value, so that they decode
+// to exactly the same values.
+func NewTypedJSONEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, format: TypedJSONFormat}
 }
 
-// Encode ...
+// Encode writes the plist encoding of v to the stream.
 func (e *Encoder) Encode(v interface{}) error {
 	pval, err := e.marshal(reflect.ValueOf(v))
 	if err != nil {
 		return err
 	}
 
-	enc := newXMLEncoder(e.w)
-	enc.Indent("", e.indent)
-	return enc.generateDocument(pval)
+	switch e.format {
+	case BinaryFormat:
+		enc := newBinaryEncoder(e.w)
+		enc.keyOrder = e.keyOrder
+		return enc.generateDocument(pval)
+	case OpenStepFormat, GNUStepFormat:
+		enc := newTextEncoder(e.w, e.format == GNUStepFormat)
+		enc.Indent(e.indent)
+		enc.keyOrder = e.keyOrder
+		return enc.generateDocument(pval)
+	case JSONFormat, TypedJSONFormat:
+		enc := newJSONEncoder(e.w, e.format == TypedJSONFormat)
+		enc.Indent(e.indent)
+		enc.keyOrder = e.keyOrder
+		return enc.generateDocument(pval)
+	default:
+		enc := newXMLEncoder(e.w)
+		enc.Indent(e.indent)
+		enc.keyOrder = e.keyOrder
+		return enc.generateDocument(pval)
+	}
 }
 
-// Indent ...
+// Indent sets the indentation used for XML, text and JSON plists.
+// It has no effect on binary plists.
 func (e *Encoder) Indent(indent string) {
 	e.indent = indent
 }
 
+// SetKeyOrder sets the order in which dictionary keys are written, which is
+// SortedKeys by default.
+func (e *Encoder) SetKeyOrder(order KeyOrder) {
+	e.keyOrder = order
+}
+
+// An encoderFunc encodes values of the type it was made for.
+type encoderFunc func(e *Encoder, v reflect.Value) (*plistValue, error)
+
+// encoderKey identifies an encoder in encoderCache. Base encoders are for
+// values held by pointers and interfaces, which aren't checked for Value
+// types and Marshalers.
+type encoderKey struct {
+	typ  reflect.Type
+	base bool
+}
+
+var encoderCache sync.Map // map[encoderKey]encoderFunc
+
 func (e *Encoder) marshal(v reflect.Value) (*plistValue, error) {
-	marshalerType := reflect.TypeOf((*Marshaler)(nil)).Elem()
+	// nil interfaces
+	if !v.IsValid() {
+		return &plistValue{NullKind, nil}, nil
+	}
+	return typeEncoder(v.Type())(e, v)
+}
+
+// typeEncoder returns the encoder for values of type t. Like the encoders
+// of encoding/json, it is made on first use and cached, so that encoding
+// a value doesn't look at its type again.
+func typeEncoder(t reflect.Type) encoderFunc {
+	return cachedEncoder(encoderKey{typ: t})
+}
+
+func cachedEncoder(key encoderKey) encoderFunc {
+	if f, ok := encoderCache.Load(key); ok {
+		return f.(encoderFunc)
+	}
+
+	// Recursive types look up their own encoder while it is being made, and
+	// get one which waits for it.
+	var (
+		wg sync.WaitGroup
+		f  encoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := encoderCache.LoadOrStore(key, encoderFunc(func(e *Encoder, v reflect.Value) (*plistValue, error) {
+		wg.Wait()
+		return f(e, v)
+	}))
+	if loaded {
+		return fi.(encoderFunc)
+	}
+	if key.base {
+		f = newBaseEncoder(key.typ)
+	} else {
+		f = newTypeEncoder(key.typ)
+	}
+	wg.Done()
+	encoderCache.Store(key, f)
+	return f
+}
 
-	if v.CanInterface() && v.Type().Implements(marshalerType) {
-		m := v.Interface().(Marshaler)
-		val, err := m.MarshalPlist()
-		if err != nil {
-			return nil, err
+// newTypeEncoder makes the encoder for values of type t.
+func newTypeEncoder(t reflect.Type) encoderFunc {
+	switch t {
+	case valueType:
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			pval := plistValue(v.Interface().(Value))
+			if pval.kind == Invalid {
+				return nil, errInvalidValue()
+			}
+			return &pval, nil
+		}
+	case valuePtrType:
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			if v.IsNil() {
+				return &plistValue{NullKind, nil}, nil
+			}
+			pval := (*plistValue)(v.Interface().(*Value))
+			if pval.kind == Invalid {
+				return nil, errInvalidValue()
+			}
+			return pval, nil
 		}
-		return e.marshal(reflect.ValueOf(val))
 	}
 
-	if v.CanAddr() {
-		pv := v.Addr()
-		if pv.CanInterface() && pv.Type().Implements(marshalerType) {
-			m := pv.Interface().(Marshaler)
-			val, err := m.MarshalPlist()
-			if err != nil {
-				return nil, err
+	next := newElemEncoder(t)
+	switch {
+	case t.Implements(marshalerType):
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			if !v.CanInterface() {
+				return next(e, v)
 			}
-			return e.marshal(reflect.ValueOf(val))
+			return e.marshalMarshaler(v.Interface().(Marshaler))
 		}
+	case reflect.PtrTo(t).Implements(marshalerType):
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			if !v.CanAddr() || !v.Addr().CanInterface() {
+				return next(e, v)
+			}
+			return e.marshalMarshaler(v.Addr().Interface().(Marshaler))
+		}
+	}
+	return next
+}
+
+func (e *Encoder) marshalMarshaler(m Marshaler) (*plistValue, error) {
+	val, err := m.MarshalPlist()
+	if err != nil {
+		return nil, err
 	}
+	return e.marshal(reflect.ValueOf(val))
+}
 
-	// check for empty interface v type
-	if v.Kind() == reflect.Interface && v.NumMethod() == 0 || v.Kind() == reflect.Ptr {
-		v = v.Elem()
+// newElemEncoder makes the encoder for values of type t which follows
+// pointers and empty interfaces.
+func newElemEncoder(t reflect.Type) encoderFunc {
+	switch {
+	case t.Kind() == reflect.Interface && t.NumMethod() == 0:
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			if v.IsNil() {
+				return &plistValue{NullKind, nil}, nil
+			}
+			v = v.Elem()
+			return cachedEncoder(encoderKey{typ: v.Type(), base: true})(e, v)
+		}
+	case t.Kind() == reflect.Ptr:
+		elem := cachedEncoder(encoderKey{typ: t.Elem(), base: true})
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			// nil pointers
+			if v.IsNil() {
+				return &plistValue{NullKind, nil}, nil
+			}
+			return elem(e, v.Elem())
+		}
 	}
+	return newBaseEncoder(t)
+}
 
-	// check for time type
-	if v.Type() == reflect.TypeOf((*time.Time)(nil)).Elem() {
-		if date, ok := v.Interface().(time.Time); ok {
-			return &plistValue{Date, date}, nil
+// newBaseEncoder makes the encoder for values of type t which are encoded
+// by their type and kind.
+func newBaseEncoder(t reflect.Type) encoderFunc {
+	switch t {
+	case timeType:
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			return &plistValue{Date, v.Interface().(time.Time)}, nil
+		}
+	case uidType:
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			return &plistValue{UIDKind, UID(v.Uint())}, nil
+		}
+	case orderedMapType:
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			return e.marshalOrderedMap(v)
+		}
+	case orderedMapPtrType:
+		// The values of decoded OrderedMaps hold *OrderedMap in empty
+		// interfaces, which don't otherwise hold pointers.
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			if v.IsNil() {
+				return &plistValue{NullKind, nil}, nil
+			}
+			return e.marshalOrderedMap(v.Elem())
+		}
+	case reflect.TypeOf(Set(nil)), reflect.TypeOf(OrderedSet(nil)):
+		kind := SetKind
+		if t == reflect.TypeOf(OrderedSet(nil)) {
+			kind = OrderedSetKind
+		}
+		array := newArrayEncoder(t)
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			pval, err := array(e, v)
+			if err != nil {
+				return nil, err
+			}
+			pval.kind = kind
+			return pval, nil
 		}
-		return nil, &UnsupportedValueError{v, v.String()}
 	}
 
-	switch v.Kind() {
+	switch t.Kind() {
 	case reflect.String:
-		return &plistValue{String, v.String()}, nil
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			return &plistValue{String, v.String()}, nil
+		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return &plistValue{Integer, signedInt{uint64(v.Int()), true}}, nil
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			return &plistValue{Integer, signedInt{uint64(v.Int()), true}}, nil
+		}
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		return &plistValue{Integer, signedInt{uint64(v.Uint()), false}}, nil
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			return &plistValue{Integer, signedInt{uint64(v.Uint()), false}}, nil
+		}
 	case reflect.Float32, reflect.Float64:
-		return &plistValue{Real, sizedFloat{v.Float(), v.Type().Bits()}}, nil
+		bits := t.Bits()
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			return &plistValue{Real, sizedFloat{v.Float(), bits}}, nil
+		}
 	case reflect.Bool:
-		return &plistValue{Boolean, v.Bool()}, nil
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			return &plistValue{Boolean, v.Bool()}, nil
+		}
 	case reflect.Slice, reflect.Array:
-		return e.marshalArray(v)
+		return newArrayEncoder(t)
 	case reflect.Map:
-		return e.marshalMap(v)
+		return newMapEncoder(t)
 	case reflect.Struct:
-		return e.marshalStruct(v)
+		return newStructEncoder(t)
 	default:
-		return nil, &UnsupportedTypeError{v.Type()}
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			return nil, &UnsupportedTypeError{Type: t}
+		}
 	}
 }
 
-func (e *Encoder) marshalStruct(v reflect.Value) (*plistValue, error) {
-	fields := cachedTypeFields(v.Type())
-	dict := &dictionary{
-		m: make(map[string]*plistValue, len(fields)),
+func newStructEncoder(t reflect.Type) encoderFunc {
+	fields := cachedTypeFields(t)
+	encoders := make([]encoderFunc, len(fields))
+	for i, field := range fields {
+		encoders[i] = typeEncoder(typeByIndex(t, field.index))
 	}
-	for _, field := range fields {
-		val := field.value(v)
-		if field.omitEmpty && isEmptyValue(val) {
-			continue
+	return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+		dict := &dictionary{
+			m: make(map[string]*plistValue, len(fields)),
+		}
+		if e.keyOrder != SortedKeys {
+			// The fields are in declaration order.
+			dict.order = make([]string, 0, len(fields))
+			dict.declared = true
 		}
-		value, err := e.marshal(field.value(v))
-		if err != nil {
-			return nil, err
+		for i, field := range fields {
+			val := field.value(v)
+			if field.omitEmpty && isEmptyValue(val) {
+				continue
+			}
+			value, err := encoders[i](e, val)
+			if err != nil {
+				return nil, prependPath(err, field.name)
+			}
+			dict.set(field.name, value)
 		}
-		dict.m[field.name] = value
+		return &plistValue{Dictionary, dict}, nil
 	}
-	return &plistValue{Dictionary, dict}, nil
 }
 
-func (e *Encoder) marshalArray(v reflect.Value) (*plistValue, error) {
-	if v.Type().Elem().Kind() == reflect.Uint8 {
-		bytes := []byte(nil)
-		if v.CanAddr() {
-			bytes = v.Slice(0, v.Len()).Bytes()
-		} else {
-			bytes = make([]byte, v.Len())
-			reflect.Copy(reflect.ValueOf(bytes), v)
+func newArrayEncoder(t reflect.Type) encoderFunc {
+	if t.Elem().Kind() == reflect.Uint8 {
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			bytes := []byte(nil)
+			if v.CanAddr() {
+				bytes = v.Slice(0, v.Len()).Bytes()
+			} else {
+				bytes = make([]byte, v.Len())
+				reflect.Copy(reflect.ValueOf(bytes), v)
+			}
+			return &plistValue{Data, bytes}, nil
 		}
-		return &plistValue{Data, bytes}, nil
 	}
-	subvalues := make([]*plistValue, v.Len())
-	for idx, length := 0, v.Len(); idx < length; idx++ {
-		subpval, err := e.marshal(v.Index(idx))
-		if err != nil {
-			return nil, err
-		}
-		if subpval != nil {
-			subvalues[idx] = subpval
+	elem := typeEncoder(t.Elem())
+	return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+		subvalues := make([]*plistValue, v.Len())
+		for idx, length := 0, v.Len(); idx < length; idx++ {
+			subpval, err := elem(e, v.Index(idx))
+			if err != nil {
+				return nil, prependPath(err, indexPath(idx))
+			}
+			if subpval != nil {
+				subvalues[idx] = subpval
+			}
 		}
+		return &plistValue{Array, subvalues}, nil
 	}
-	return &plistValue{Array, subvalues}, nil
 }
 
-func (e *Encoder) marshalMap(v reflect.Value) (*plistValue, error) {
-	if v.Type().Key().Kind() != reflect.String {
-		return nil, &UnsupportedTypeError{v.Type()}
-	}
-
-	l := v.Len()
-	dict := &dictionary{
-		m: make(map[string]*plistValue, l),
+func newMapEncoder(t reflect.Type) encoderFunc {
+	if t.Key().Kind() != reflect.String {
+		return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+			return nil, &UnsupportedTypeError{Type: t}
+		}
 	}
-	for _, keyv := range v.MapKeys() {
-		subpval, err := e.marshal(v.MapIndex(keyv))
-		if err != nil {
-			return nil, err
+	elem := typeEncoder(t.Elem())
+	return func(e *Encoder, v reflect.Value) (*plistValue, error) {
+		l := v.Len()
+		dict := &dictionary{
+			m: make(map[string]*plistValue, l),
 		}
-		if subpval != nil {
-			dict.m[keyv.String()] = subpval
+		for _, keyv := range v.MapKeys() {
+			subpval, err := elem(e, v.MapIndex(keyv))
+			if err != nil {
+				return nil, prependPath(err, keyv.String())
+			}
+			if subpval != nil {
+				dict.m[keyv.String()] = subpval
+			}
 		}
+		return &plistValue{Dictionary, dict}, nil
 	}
-	return &plistValue{Dictionary, dict}, nil
 }
 
 // An UnsupportedTypeError is returned by Marshal when attempting
 // to encode an unsupported value type.
 type UnsupportedTypeError struct {
 	Type reflect.Type
+	Path string // keys and indices leading to the value, as in "Items[2].Name"
 }
 
 func (e *UnsupportedTypeError) Error() string {
-	return "plist: unsupported type: " + e.Type.String()
+	msg := "plist: unsupported type: " + e.Type.String()
+	if e.Path != "" {
+		msg += " (in " + e.Path + ")"
+	}
+	return msg
+}
+
+// errInvalidValue returns the error for the zero Value, which can't be
+// encoded.
+func errInvalidValue() error {
+	return &UnsupportedValueError{Str: "invalid Value"}
+}
+
+// kindError returns the error for a value of a kind which a writer can't
+// write.
+func kindError(pval *plistValue) error {
+	if pval.kind == Invalid {
+		return errInvalidValue()
+	}
+	return &UnsupportedTypeError{Type: reflect.ValueOf(pval.value).Type()}
 }
 
 // UnsupportedValueError ...
 type UnsupportedValueError struct {
 	Value reflect.Value
 	Str   string
+	Path  string // keys and indices leading to the value, as in "Items[2].Name"
 }
 
 func (e *UnsupportedValueError) Error() string {
-	return "plist: unsupported value: " + e.Str
+	msg := "plist: unsupported value: " + e.Str
+	if e.Path != "" {
+		msg += " (in " + e.Path + ")"
+	}
+	return msg
 }
 
 func isEmptyValue(v reflect.Value) bool {
@@ -2,6 +2,13 @@ package plist
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -153,14 +160,16 @@ var encodeTests = []struct {
 func TestEncodeValues(t *testing.T) {
 	t.Parallel()
 	for _, tt := range encodeTests {
-		b, err := Marshal(tt.in)
-		if err != nil {
-			t.Error(err)
-			continue
-		}
-		out := string(b)
-		if out != tt.out {
-			t.Errorf("Marshal(%v) = \n%v, \nwant\n %v", tt.in, out, tt.out)
+		for _, in := range withGenerated(tt.in) {
+			b, err := Marshal(in)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			out := string(b)
+			if out != tt.out {
+				t.Errorf("Marshal(%v) = \n%v, \nwant\n %v", in, out, tt.out)
+			}
 		}
 	}
 }
@@ -211,13 +220,15 @@ func TestIndent(t *testing.T) {
 		BackingStoreVersion:   1,
 		Unused:                testStruct{UnusedString: "unused"},
 	}
-	b, err := MarshalIndent(sparseBundleHeader, "   ")
-	if err != nil {
-		t.Fatal(err)
-	}
-	out := string(b)
-	if out != indentRef {
-		t.Errorf("MarshalIndent(%v) = \n%v, \nwant\n %v", sparseBundleHeader, out, indentRef)
+	for _, in := range withGenerated(sparseBundleHeader) {
+		b, err := MarshalIndent(in, "   ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := string(b)
+		if out != indentRef {
+			t.Errorf("MarshalIndent(%v) = \n%v, \nwant\n %v", in, out, indentRef)
+		}
 	}
 }
 
@@ -238,13 +249,15 @@ func TestOmitNotEmpty(t *testing.T) {
 		BackingStoreVersion:   1,
 		Unused:                testStruct{UnusedString: "unused"},
 	}
-	b, err := MarshalIndent(sparseBundleHeader, "   ")
-	if err != nil {
-		t.Fatal(err)
-	}
-	out := string(b)
-	if out != indentRef {
-		t.Errorf("MarshalIndent(%v) = \n%v, \nwant\n %v", sparseBundleHeader, out, indentRef)
+	for _, in := range withGenerated(sparseBundleHeader) {
+		b, err := MarshalIndent(in, "   ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := string(b)
+		if out != indentRef {
+			t.Errorf("MarshalIndent(%v) = \n%v, \nwant\n %v", in, out, indentRef)
+		}
 	}
 }
 
@@ -263,13 +276,15 @@ func TestOmitIsEmpty(t *testing.T) {
 		DiskImageBundleType:   "com.apple.diskimage.sparsebundle",
 		BackingStoreVersion:   1,
 	}
-	b, err := MarshalIndent(sparseBundleHeader, "   ")
-	if err != nil {
-		t.Fatal(err)
-	}
-	out := string(b)
-	if out != indentRefOmit {
-		t.Errorf("MarshalIndent(%v) = \n%v, \nwant\n %v", sparseBundleHeader, out, indentRefOmit)
+	for _, in := range withGenerated(sparseBundleHeader) {
+		b, err := MarshalIndent(in, "   ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := string(b)
+		if out != indentRefOmit {
+			t.Errorf("MarshalIndent(%v) = \n%v, \nwant\n %v", in, out, indentRefOmit)
+		}
 	}
 }
 
@@ -300,6 +315,45 @@ func TestMarshaler(t *testing.T) {
 	}
 }
 
+// TestXMLEscaping checks that strings and keys are escaped like
+// encoding/xml escapes character data and element text.
+func TestXMLEscaping(t *testing.T) {
+	t.Parallel()
+	for _, s := range []string{
+		"plain",
+		"a\nb\r\tc",
+		`<&>"'`,
+		"\x00\x1f\x7f",
+		"\xff\xfe invalid",
+		"\ufffd\ufffe\uffff",
+		"☼ \U0001F600",
+	} {
+		var chardata, text bytes.Buffer
+		enc := xml.NewEncoder(&chardata)
+		if err := enc.EncodeToken(xml.CharData(s)); err != nil {
+			t.Fatal(err)
+		}
+		enc.Flush()
+		xml.EscapeText(&text, []byte(s))
+		want := "<string>" + chardata.String() + "</string>"
+		b, err := Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), want) {
+			t.Errorf("Marshal(%q) = %s, want %s", s, b, want)
+		}
+		want = "<key>" + text.String() + "</key>"
+		b, err = Marshal(map[string]bool{s: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), want) {
+			t.Errorf("Marshal of key %q = %s, want %s", s, b, want)
+		}
+	}
+}
+
 func TestSelfClosing(t *testing.T) {
 	t.Parallel()
 	selfClosing := struct {
@@ -348,3 +402,380 @@ func TestEncodeTagSkip(t *testing.T) {
 		t.Error("field encoded when it was tagged as -")
 	}
 }
+
+func TestEncodeBinaryRoundTrip(t *testing.T) {
+	t.Parallel()
+	type sample struct {
+		Strings  []string           `plist:"strings"`
+		Ints     []int64            `plist:"ints"`
+		Uint64   uint64             `plist:"uint64"`
+		Float32  float32            `plist:"float32"`
+		Float64  float64            `plist:"float64"`
+		Bool     bool               `plist:"bool"`
+		Data     []byte             `plist:"data"`
+		Date     time.Time          `plist:"date"`
+		Nested   map[string]string  `plist:"nested"`
+		Repeated []map[string]int64 `plist:"repeated"`
+	}
+	long := make([]int64, 300)
+	for i := range long {
+		long[i] = int64(i * i * i)
+	}
+	in := sample{
+		Strings: []string{"short", "こんにちは世界", "this is a much longer string having more than 14 characters", "short"},
+		Ints:    append([]int64{0, 42, -42, 255, -255, -123456, -9223372036854775808, 9223372036854775807}, long...),
+		Uint64:  ^uint64(0),
+		Float32: 3.25,
+		Float64: -1234.5678,
+		Bool:    true,
+		Data:    bytes.Repeat([]byte{0xde, 0xad, 0xbe, 0xef}, 100),
+		Date:    time.Date(2038, 1, 19, 3, 14, 8, 0, time.UTC),
+		Nested:  map[string]string{"a": "b", "short": "short"},
+		Repeated: []map[string]int64{
+			{"x": 1, "y": 2},
+			{"x": 1, "y": 2},
+		},
+	}
+	b, err := MarshalBinary(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out sample
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Date.Equal(in.Date) {
+		t.Errorf("date: have %v, want %v", out.Date, in.Date)
+	}
+	out.Date = in.Date
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("binary round trip:\nhave %#v\nwant %#v", out, in)
+	}
+}
+
+func TestEncodeBinaryValues(t *testing.T) {
+	t.Parallel()
+	for _, tt := range encodeTests {
+		b, err := MarshalBinary(tt.in)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		var have, want interface{}
+		if err := Unmarshal(b, &have); err != nil {
+			t.Error(err)
+			continue
+		}
+		if err := Unmarshal([]byte(tt.out), &want); err != nil {
+			t.Error(err)
+			continue
+		}
+		if date, ok := have.(time.Time); ok {
+			have = date.UTC()
+		}
+		if !reflect.DeepEqual(normalizeInts(have), normalizeInts(want)) {
+			t.Errorf("MarshalBinary(%v) decoded to %#v, want %#v", tt.in, have, want)
+		}
+	}
+}
+
+// normalizeInts converts all integers in v to uint64, since binary plists
+// don't record the signedness of integers.
+func normalizeInts(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int64:
+		return uint64(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeInts(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = normalizeInts(v[k])
+		}
+	}
+	return v
+}
+
+func TestEncodeBinaryDedup(t *testing.T) {
+	t.Parallel()
+	shared := map[string]interface{}{"key": "value", "list": []string{"value", "value"}}
+	b, err := MarshalBinary([]interface{}{shared, shared, "value", "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// root array, dict, "key", "list", "value", list array
+	numObjects := binary.BigEndian.Uint64(b[len(b)-24:])
+	if have, want := numObjects, uint64(6); have != want {
+		t.Errorf("have %d objects, want %d", have, want)
+	}
+}
+
+func TestEncodeBinarySample(t *testing.T) {
+	t.Parallel()
+	content, err := ioutil.ReadFile(filepath.Join("testdata", "sample2.binary.plist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want interface{}
+	if err := Unmarshal(content, &want); err != nil {
+		t.Fatal(err)
+	}
+	b, err := MarshalBinary(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have interface{}
+	if err := Unmarshal(b, &have); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("have %#v\nwant %#v", have, want)
+	}
+}
+
+func TestEncodeSetsAndNull(t *testing.T) {
+	t.Parallel()
+	type value struct {
+		Set        Set         `plist:"set"`
+		OrderedSet OrderedSet  `plist:"ordered"`
+		UID        UID         `plist:"uid"`
+		Null       *string     `plist:"null"`
+		Any        interface{} `plist:"any"`
+	}
+	in := value{
+		Set:        Set{"a", "b"},
+		OrderedSet: OrderedSet{int64(2), int64(1)},
+		UID:        7,
+	}
+
+	b, err := MarshalBinary(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have map[string]interface{}
+	if err := Unmarshal(b, &have); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"set":     Set{"a", "b"},
+		"ordered": OrderedSet{uint64(2), uint64(1)},
+		"uid":     UID(7),
+		"null":    nil,
+		"any":     nil,
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("binary: have %#v\nwant %#v", have, want)
+	}
+
+	var keys struct {
+		Set map[string]struct{} `plist:"set"`
+		Ord []int               `plist:"ordered"`
+	}
+	if err := Unmarshal(b, &keys); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys.Set) != 2 || !reflect.DeepEqual(keys.Ord, []int{2, 1}) {
+		t.Errorf("have %#v", keys)
+	}
+
+	// XML has neither sets nor null, and stores UIDs as CF$UID dictionaries.
+	x, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const xmlWant = `<plist version="1.0"><dict><key>ordered</key><array><integer>2</integer><integer>1</integer></array><key>set</key><array><string>a</string><string>b</string></array><key>uid</key><dict><key>CF$UID</key><integer>7</integer></dict></dict></plist>`
+	if !strings.Contains(string(x), xmlWant) {
+		t.Errorf("xml: have %s\nwant %s", x, xmlWant)
+	}
+	var uid struct {
+		UID UID `plist:"uid"`
+	}
+	if err := Unmarshal(x, &uid); err != nil || uid.UID != 7 {
+		t.Errorf("xml: decoded uid %d, %v", uid.UID, err)
+	}
+
+	s := "a"
+	if _, err := Marshal([]*string{&s, nil}); err == nil {
+		t.Error("expected an error encoding null in an XML array")
+	}
+}
+
+func TestEncodeErrorPath(t *testing.T) {
+	t.Parallel()
+	type item struct {
+		Name string      `plist:"name"`
+		Ch   interface{} `plist:"ch"`
+	}
+	in := map[string]interface{}{
+		"Items": []item{{Name: "a"}, {Name: "b"}, {Name: "c", Ch: make(chan int)}},
+	}
+	_, err := Marshal(in)
+	terr, ok := err.(*UnsupportedTypeError)
+	if !ok {
+		t.Fatalf("have error %v, want *UnsupportedTypeError", err)
+	}
+	if want := "Items[2].ch"; terr.Path != want {
+		t.Errorf("have path %q, want %q", terr.Path, want)
+	}
+
+	// XML plists can't hold null outside of dictionaries.
+	_, err = Marshal(map[string]interface{}{"list": []interface{}{"a", nil}})
+	verr, ok := err.(*UnsupportedValueError)
+	if !ok {
+		t.Fatalf("have error %v, want *UnsupportedValueError", err)
+	}
+	if want := "list[1]"; verr.Path != want {
+		t.Errorf("have path %q, want %q", verr.Path, want)
+	}
+}
+
+type recursiveNode struct {
+	Name     string
+	Children []recursiveNode `plist:",omitempty"`
+	Next     *recursiveNode  `plist:",omitempty"`
+}
+
+func TestEncodeRecursiveType(t *testing.T) {
+	in := recursiveNode{
+		Name:     "root",
+		Children: []recursiveNode{{Name: "a"}, {Name: "b", Next: &recursiveNode{Name: "c"}}},
+	}
+	for _, format := range []string{"xml", "binary"} {
+		var data []byte
+		var err error
+		if format == "xml" {
+			data, err = Marshal(in)
+		} else {
+			data, err = MarshalBinary(in)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out recursiveNode
+		if err := Unmarshal(data, &out); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(out, in) {
+			t.Errorf("%s: round trip = %+v, want %+v", format, out, in)
+		}
+	}
+}
+
+// benchCommand looks like the MDM commands which are marshaled over and over.
+type benchCommand struct {
+	CommandUUID string
+	Command     struct {
+		RequestType string
+		Queries     []string
+		Identifier  string `plist:",omitempty"`
+		Payload     []byte `plist:",omitempty"`
+		Settings    []struct {
+			Item    string
+			Enabled bool
+			Count   int
+		}
+	}
+	Meta map[string]interface{}
+}
+
+func newBenchCommand() benchCommand {
+	var cmd benchCommand
+	cmd.CommandUUID = "0001-0002-0003"
+	cmd.Command.RequestType = "DeviceInformation"
+	cmd.Command.Queries = []string{"UDID", "DeviceName", "OSVersion", "SerialNumber", "Model"}
+	cmd.Command.Payload = bytes.Repeat([]byte("payload"), 16)
+	cmd.Command.Settings = make([]struct {
+		Item    string
+		Enabled bool
+		Count   int
+	}, 8)
+	for i := range cmd.Command.Settings {
+		cmd.Command.Settings[i].Item = "Setting"
+		cmd.Command.Settings[i].Count = i
+	}
+	cmd.Meta = map[string]interface{}{"Attempt": 1, "Source": "bench"}
+	return cmd
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	cmd := newBenchCommand()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(cmd); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalBinary(b *testing.B) {
+	cmd := newBenchCommand()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := MarshalBinary(cmd); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// flattenKeys returns the keys of m in order, each followed by the keys of
+// its value if that is a dictionary.
+func flattenKeys(m *OrderedMap) []string {
+	var keys []string
+	for _, k := range m.Keys() {
+		keys = append(keys, k)
+		if v, _ := m.Get(k); v != nil {
+			if sub, ok := v.(*OrderedMap); ok {
+				keys = append(keys, flattenKeys(sub)...)
+			}
+		}
+	}
+	return keys
+}
+
+func TestEncodeKeyOrder(t *testing.T) {
+	t.Parallel()
+	var ordered OrderedMap
+	ordered.Set("zeta", 1)
+	ordered.Set("alpha", "a")
+	doc := struct {
+		Name    string         `plist:"name"`
+		Ordered OrderedMap     `plist:"ordered"`
+		Map     map[string]int `plist:"map"`
+		Age     int            `plist:"age"`
+	}{Name: "n", Ordered: ordered, Map: map[string]int{"b": 1, "a": 2}, Age: 3}
+
+	tests := []struct {
+		order KeyOrder
+		want  []string
+	}{
+		{SortedKeys, []string{"age", "map", "a", "b", "name", "ordered", "alpha", "zeta"}},
+		{DeclarationOrder, []string{"name", "ordered", "alpha", "zeta", "map", "a", "b", "age"}},
+		{InsertionOrder, []string{"name", "ordered", "zeta", "alpha", "map", "a", "b", "age"}},
+	}
+	encoders := map[string]func(io.Writer) *Encoder{
+		"xml":      NewEncoder,
+		"binary":   NewBinaryEncoder,
+		"openstep": NewOpenStepEncoder,
+		"json":     NewJSONEncoder,
+	}
+	for _, tt := range tests {
+		for name, newEncoder := range encoders {
+			for _, in := range withGenerated(doc) {
+				var buf bytes.Buffer
+				enc := newEncoder(&buf)
+				enc.SetKeyOrder(tt.order)
+				if err := enc.Encode(in); err != nil {
+					t.Fatalf("%s %d %T: %v", name, tt.order, in, err)
+				}
+				var out OrderedMap
+				if err := NewDecoder(bytes.NewReader(buf.Bytes())).Decode(&out); err != nil {
+					t.Fatalf("%s %d %T: %v", name, tt.order, in, err)
+				}
+				if have := flattenKeys(&out); !reflect.DeepEqual(have, tt.want) {
+					t.Errorf("%s %d %T: have keys %q, want %q", name, tt.order, in, have, tt.want)
+				}
+			}
+		}
+	}
+}
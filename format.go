@@ -0,0 +1,151 @@
+package plist
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Format is the serialization format of a property list.
+type Format int
+
+const (
+	// AutomaticFormat makes a Decoder detect the format of its input.
+	AutomaticFormat Format = iota
+	// XMLFormat is the XML plist format.
+	XMLFormat
+	// BinaryFormat is the bplist00 binary format.
+	BinaryFormat
+	// OpenStepFormat is the old-style ASCII format of OpenStep and NeXTSTEP.
+	OpenStepFormat
+	// GNUStepFormat is the OpenStep format extended with GNUstep's typed
+	// literals for integers, reals, booleans and dates.
+	GNUStepFormat
+	// JSONFormat is the JSON format written by plutil -convert json.
+	JSONFormat
+	// TypedJSONFormat is a lossless JSON format which records the kind of
+	// every value. It is never detected automatically.
+	TypedJSONFormat
+)
+
+var formatNames = map[Format]string{
+	AutomaticFormat: "automatic",
+	XMLFormat:       "XML",
+	BinaryFormat:    "binary",
+	OpenStepFormat:  "OpenStep",
+	GNUStepFormat:   "GNUstep",
+	JSONFormat:      "JSON",
+	TypedJSONFormat: "typed JSON",
+}
+
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// sniffLen is the number of bytes looked at to detect the format of a plist.
+const sniffLen = 512
+
+// detectFormat returns the format of a plist starting with prefix.
+// Empty input is assumed to be XML, and other input which isn't recognized
+// to be an OpenStep plist, since unquoted OpenStep strings may start with
+// almost anything. Their parsers report a meaningful error.
+func detectFormat(prefix []byte) Format {
+	if bytes.HasPrefix(prefix, []byte("bplist0")) {
+		return BinaryFormat
+	}
+	if bytes.HasPrefix(prefix, []byte{0xfe, 0xff}) || bytes.HasPrefix(prefix, []byte{0xff, 0xfe}) {
+		// Only text plists are read in UTF-16.
+		return OpenStepFormat
+	}
+	prefix = bytes.TrimPrefix(prefix, []byte("\xef\xbb\xbf"))
+	prefix = bytes.TrimLeft(prefix, " \t\r\n")
+	if len(prefix) == 0 {
+		return XMLFormat
+	}
+	if prefix[0] == '<' {
+		// <?xml, <!DOCTYPE and <plist are XML, <0fbd77> is OpenStep data
+		// and <*I42> is a GNUstep typed literal.
+		if len(prefix) > 1 && (isHexDigit(prefix[1]) || prefix[1] == '>' || prefix[1] == '*' ||
+			prefix[1] == ' ' || prefix[1] == '\t' || prefix[1] == '\r' || prefix[1] == '\n') {
+			return OpenStepFormat
+		}
+		return XMLFormat
+	}
+	if prefix[0] == '[' || prefix[0] == '{' && isJSONObject(prefix[1:]) {
+		return JSONFormat
+	}
+	return OpenStepFormat
+}
+
+// isJSONObject reports whether prefix, which follows an opening brace, looks
+// like a JSON object rather than an OpenStep dictionary. JSON keys are quoted
+// strings followed by a colon, OpenStep keys are followed by an equals sign.
+func isJSONObject(prefix []byte) bool {
+	prefix = bytes.TrimLeft(prefix, " \t\r\n")
+	if len(prefix) == 0 || prefix[0] == '}' {
+		return true
+	}
+	if prefix[0] != '"' {
+		return false
+	}
+	for i := 1; i < len(prefix); i++ {
+		switch prefix[i] {
+		case '\\':
+			i++
+		case '"':
+			rest := bytes.TrimLeft(prefix[i+1:], " \t\r\n")
+			return len(rest) == 0 || rest[0] == ':'
+		}
+	}
+	// The key doesn't fit into the prefix.
+	return true
+}
+
+// sniff returns the first bytes of the decoder's input without consuming them.
+// Seekable readers are rewound after reading, all other readers are wrapped
+// in a bufio.Reader which is kept for subsequent reads.
+func (d *Decoder) sniff() ([]byte, error) {
+	if rs, ok := d.seeker(); ok {
+		buf := make([]byte, sniffLen)
+		n, err := io.ReadFull(rs, buf)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return nil, err
+		}
+		if _, err := rs.Seek(int64(-n), io.SeekCurrent); err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, io.EOF
+		}
+		return buf[:n], nil
+	}
+	br, ok := d.reader.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(d.reader)
+		d.reader = br
+	}
+	buf, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if len(buf) == 0 {
+		return nil, io.EOF
+	}
+	return buf, nil
+}
+
+// seeker returns the decoder's input if it is an io.ReadSeeker which can
+// actually seek. Pipes such as os.Stdin are *os.File values whose Seek fails.
+func (d *Decoder) seeker() (io.ReadSeeker, bool) {
+	rs, ok := d.reader.(io.ReadSeeker)
+	if !ok {
+		return nil, false
+	}
+	if _, err := rs.Seek(0, io.SeekCurrent); err != nil {
+		return nil, false
+	}
+	return rs, true
+}
@@ -0,0 +1,295 @@
+package plist
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonParser uses json.Decoder to parse a JSON plist into the corresponding
+// plistValues.
+//
+// Plain JSON plists are what plutil -convert json writes: objects, arrays,
+// strings, numbers and booleans. Typed JSON plists wrap every value in an
+// object with a single key naming its Kind, so that they can hold every
+// plist value without loss; see jsonEncoder.
+type jsonParser struct {
+	*json.Decoder
+	typed  bool
+	strict bool // true if duplicate keys and trailing data are rejected
+	limits *limiter
+}
+
+func newJSONParser(r io.Reader, typed bool) *jsonParser {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &jsonParser{Decoder: dec, typed: typed, limits: newLimiter(DecoderLimits{})}
+}
+
+func (p *jsonParser) parseDocument() (*plistValue, error) {
+	tok, err := p.Token()
+	if err != nil {
+		return nil, err
+	}
+	var pval *plistValue
+	if p.typed {
+		pval, err = p.parseTypedValue(tok)
+	} else {
+		pval, err = p.parseValue(tok)
+	}
+	if err != nil || !p.strict {
+		return pval, err
+	}
+	if tok, err := p.Token(); err != io.EOF {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("plist: trailing data after the document: %v", tok)
+	}
+	return pval, nil
+}
+
+func (p *jsonParser) parseValue(tok json.Token) (*plistValue, error) {
+	if err := p.limits.enter(); err != nil {
+		return nil, err
+	}
+	defer p.limits.leave()
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			return p.parseObject(p.parseValue)
+		case '[':
+			return p.parseArray(p.parseValue)
+		}
+	case string:
+		if err := p.limits.bytes(uint64(len(tok))); err != nil {
+			return nil, err
+		}
+		return &plistValue{String, tok}, nil
+	case json.Number:
+		return parseJSONNumber(tok)
+	case bool:
+		return &plistValue{Boolean, tok}, nil
+	case nil:
+		return &plistValue{NullKind, nil}, nil
+	}
+	return nil, fmt.Errorf("plist: unexpected JSON token %v", tok)
+}
+
+// parseJSONNumber returns integral numbers as integers and all others as reals.
+func parseJSONNumber(n json.Number) (*plistValue, error) {
+	s := string(n)
+	if !strings.ContainsAny(s, ".eE") {
+		if strings.HasPrefix(s, "-") {
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return &plistValue{Integer, signedInt{uint64(i), true}}, nil
+			}
+		} else if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return &plistValue{Integer, signedInt{u, false}}, nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("plist: invalid number %s: %v", s, err)
+	}
+	return &plistValue{Real, sizedFloat{f, 64}}, nil
+}
+
+// parseObject parses the members of an object after its opening brace.
+// Values are parsed with parseValue.
+func (p *jsonParser) parseObject(parseValue func(json.Token) (*plistValue, error)) (*plistValue, error) {
+	dict := newDictionary()
+	for p.More() {
+		tok, err := p.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("plist: unexpected JSON token %v", tok)
+		}
+		if _, ok := dict.m[key]; ok && p.strict {
+			return nil, fmt.Errorf("plist: duplicate key %q", key)
+		}
+		if err := p.limits.collection(uint64(len(dict.m)) + 1); err != nil {
+			return nil, err
+		}
+		if err := p.limits.bytes(uint64(len(key))); err != nil {
+			return nil, err
+		}
+		if tok, err = p.Token(); err != nil {
+			return nil, err
+		}
+		sval, err := parseValue(tok)
+		if err != nil {
+			return nil, err
+		}
+		dict.set(key, sval)
+	}
+	// closing brace
+	if _, err := p.Token(); err != nil {
+		return nil, err
+	}
+	return &plistValue{Dictionary, dict}, nil
+}
+
+// parseArray parses the elements of an array after its opening bracket.
+// Values are parsed with parseValue.
+func (p *jsonParser) parseArray(parseValue func(json.Token) (*plistValue, error)) (*plistValue, error) {
+	subvalues := []*plistValue{}
+	for p.More() {
+		if err := p.limits.collection(uint64(len(subvalues)) + 1); err != nil {
+			return nil, err
+		}
+		tok, err := p.Token()
+		if err != nil {
+			return nil, err
+		}
+		subv, err := parseValue(tok)
+		if err != nil {
+			return nil, err
+		}
+		subvalues = append(subvalues, subv)
+	}
+	// closing bracket
+	if _, err := p.Token(); err != nil {
+		return nil, err
+	}
+	return &plistValue{Array, subvalues}, nil
+}
+
+// parseTypedValue parses a value of a typed JSON plist, which is an object
+// with a single member such as {"int": "-42"}.
+func (p *jsonParser) parseTypedValue(tok json.Token) (*plistValue, error) {
+	if tok != json.Delim('{') {
+		return nil, fmt.Errorf("plist: expected typed JSON value, found %v", tok)
+	}
+	if err := p.limits.enter(); err != nil {
+		return nil, err
+	}
+	defer p.limits.leave()
+	tok, err := p.Token()
+	if err != nil {
+		return nil, err
+	}
+	typ, ok := tok.(string)
+	if !ok {
+		return nil, fmt.Errorf("plist: expected typed JSON value, found %v", tok)
+	}
+	if tok, err = p.Token(); err != nil {
+		return nil, err
+	}
+
+	var pval *plistValue
+	switch typ {
+	case "dict":
+		if tok != json.Delim('{') {
+			return nil, fmt.Errorf("plist: typed JSON dict must be an object, found %v", tok)
+		}
+		pval, err = p.parseObject(p.parseTypedValue)
+	case "array":
+		if tok != json.Delim('[') {
+			return nil, fmt.Errorf("plist: typed JSON array must be an array, found %v", tok)
+		}
+		pval, err = p.parseArray(p.parseTypedValue)
+	case "set", "orderedset":
+		if tok != json.Delim('[') {
+			return nil, fmt.Errorf("plist: typed JSON %s must be an array, found %v", typ, tok)
+		}
+		if pval, err = p.parseArray(p.parseTypedValue); err == nil {
+			pval.kind = SetKind
+			if typ == "orderedset" {
+				pval.kind = OrderedSetKind
+			}
+		}
+	case "null":
+		if tok != nil {
+			return nil, fmt.Errorf("plist: typed JSON null must be null, found %v", tok)
+		}
+		pval = &plistValue{NullKind, nil}
+	case "bool":
+		b, ok := tok.(bool)
+		if !ok {
+			return nil, fmt.Errorf("plist: typed JSON bool must be a boolean, found %v", tok)
+		}
+		pval = &plistValue{Boolean, b}
+	default:
+		s, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("plist: typed JSON %s must be a string, found %v", typ, tok)
+		}
+		if err := p.limits.bytes(uint64(len(s))); err != nil {
+			return nil, err
+		}
+		pval, err = parseTypedScalar(typ, s)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if tok, err = p.Token(); err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('}') {
+		return nil, fmt.Errorf("plist: typed JSON value has more than one member")
+	}
+	return pval, nil
+}
+
+// parseTypedScalar parses the string payload of a typed JSON scalar.
+func parseTypedScalar(typ, s string) (*plistValue, error) {
+	switch typ {
+	case "string":
+		return &plistValue{String, s}, nil
+	case "int":
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("plist: invalid typed JSON int: %v", err)
+		}
+		return &plistValue{Integer, signedInt{uint64(i), true}}, nil
+	case "uint":
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("plist: invalid typed JSON uint: %v", err)
+		}
+		return &plistValue{Integer, signedInt{u, false}}, nil
+	case "uid":
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("plist: invalid typed JSON uid: %v", err)
+		}
+		return &plistValue{UIDKind, UID(u)}, nil
+	case "real32", "real64":
+		bits := 64
+		if typ == "real32" {
+			bits = 32
+		}
+		f, err := strconv.ParseFloat(s, bits)
+		if err != nil {
+			return nil, fmt.Errorf("plist: invalid typed JSON %s: %v", typ, err)
+		}
+		return &plistValue{Real, sizedFloat{f, bits}}, nil
+	case "data":
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("plist: invalid typed JSON data: %v", err)
+		}
+		if len(data) == 0 {
+			data = nil
+		}
+		return &plistValue{Data, data}, nil
+	case "date":
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("plist: invalid typed JSON date: %v", err)
+		}
+		return &plistValue{Date, t}, nil
+	default:
+		return nil, fmt.Errorf("plist: unknown typed JSON type %q", typ)
+	}
+}
@@ -0,0 +1,181 @@
+package plist
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const jsonRef = `{"CFBundleName":"Hello \"World\"","Count":-42,"Enabled":true,"Items":["one",2,3.5],"Size":18446744073709551615}
+`
+
+func TestDecodeJSON(t *testing.T) {
+	var out interface{}
+	d := NewDecoder(strings.NewReader(jsonRef))
+	if err := d.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := d.Format(), JSONFormat; have != want {
+		t.Errorf("have format %v, want %v", have, want)
+	}
+	want := map[string]interface{}{
+		"CFBundleName": `Hello "World"`,
+		"Count":        int64(-42),
+		"Enabled":      true,
+		"Items":        []interface{}{"one", uint64(2), 3.5},
+		"Size":         uint64(18446744073709551615),
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("have %#v\nwant %#v", out, want)
+	}
+
+	s := "set"
+	nulls := struct {
+		A *string `plist:"a"`
+		B int     `plist:"b"`
+	}{&s, 1}
+	if err := NewJSONDecoder(strings.NewReader(`{"a": null, "b": null}`)).Decode(&nulls); err != nil {
+		t.Fatal(err)
+	}
+	if nulls.A != nil || nulls.B != 1 {
+		t.Errorf("null decoded to %#v", nulls)
+	}
+
+	for _, input := range []string{`{"a":1`, `[1,]`} {
+		var out interface{}
+		if err := NewJSONDecoder(strings.NewReader(input)).Decode(&out); err == nil {
+			t.Errorf("expected error decoding %q, got %#v", input, out)
+		}
+	}
+}
+
+func TestDecodeJSONStrings(t *testing.T) {
+	const input = `{"data": "3q2+7w==", "date": "2011-05-12T01:00:00Z", "ratio": 2}`
+	var out struct {
+		Data  []byte    `plist:"data"`
+		Date  time.Time `plist:"date"`
+		Ratio float64   `plist:"ratio"`
+	}
+	if err := Unmarshal([]byte(input), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Data, []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Errorf("have data %x", out.Data)
+	}
+	if want := time.Date(2011, 5, 12, 1, 0, 0, 0, time.UTC); !out.Date.Equal(want) {
+		t.Errorf("have date %v, want %v", out.Date, want)
+	}
+	if out.Ratio != 2 {
+		t.Errorf("have ratio %v, want 2", out.Ratio)
+	}
+}
+
+func TestDecodeJSONStream(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`["a"] ["b"]`))
+	for _, want := range []string{"a", "b"} {
+		var out []string
+		if err := d.Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1 || out[0] != want {
+			t.Errorf("have %v, want [%s]", out, want)
+		}
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	in := map[string]interface{}{
+		"CFBundleName": `Hello "World"`,
+		"Count":        -42,
+		"Enabled":      true,
+		"Items":        []interface{}{"one", 2, 3.5},
+		"Size":         uint64(18446744073709551615),
+	}
+	var buf bytes.Buffer
+	if err := NewJSONEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	if have := buf.String(); have != jsonRef {
+		t.Errorf("have\n%s\nwant\n%s", have, jsonRef)
+	}
+
+	buf.Reset()
+	enc := NewJSONEncoder(&buf)
+	enc.Indent("  ")
+	if err := enc.Encode(map[string]interface{}{"a": []int{1, 2}, "b": []int{}, "c": "<&>"}); err != nil {
+		t.Fatal(err)
+	}
+	const want = `{
+  "a": [
+    1,
+    2
+  ],
+  "b": [],
+  "c": "<&>"
+}
+`
+	if have := buf.String(); have != want {
+		t.Errorf("have\n%s\nwant\n%s", have, want)
+	}
+
+	if err := NewJSONEncoder(&buf).Encode(math.NaN()); err == nil {
+		t.Error("expected error encoding NaN as JSON")
+	}
+}
+
+func TestTypedJSONRoundTrip(t *testing.T) {
+	in := map[string]interface{}{
+		"string":   "line\nbreak  ",
+		"int":      int64(-42),
+		"positive": int64(42),
+		"uint":     uint64(18446744073709551615),
+		"real32":   float32(0.1),
+		"real64":   0.1,
+		"inf":      math.Inf(-1),
+		"bool":     false,
+		"data":     []byte{0xde, 0xad, 0xbe, 0xef},
+		"date":     time.Date(2011, 5, 12, 1, 0, 0, 123456789, time.UTC),
+		"array":    []interface{}{"a", []interface{}{}},
+		"dict":     map[string]interface{}{"nested": map[string]interface{}{}},
+		"set":      Set{"a", int64(1)},
+		"ordered":  OrderedSet{},
+		"uid":      UID(3),
+		"null":     nil,
+	}
+	var buf bytes.Buffer
+	if err := NewTypedJSONEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	var out interface{}
+	if err := NewTypedJSONDecoder(&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("have %#v\nwant %#v", out, in)
+	}
+
+	for _, input := range []string{
+		`"plain"`,
+		`{"string": 1}`,
+		`{"int": "1.5"}`,
+		`{"uint": "-1"}`,
+		`{"bool": "true"}`,
+		`{"data": "!"}`,
+		`{"date": "yesterday"}`,
+		`{"string": "a", "int": "1"}`,
+		`{"array": {}}`,
+		`{"dict": []}`,
+		`{"set": {}}`,
+		`{"uid": "-1"}`,
+		`{"null": 0}`,
+		`{"unknown": "1"}`,
+	} {
+		var out interface{}
+		if err := NewTypedJSONDecoder(strings.NewReader(input)).Decode(&out); err == nil {
+			t.Errorf("expected error decoding %q, got %#v", input, out)
+		}
+	}
+}
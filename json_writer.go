@@ -0,0 +1,254 @@
+package plist
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// jsonEncoder writes a plistValue tree as a JSON plist.
+//
+// Plain JSON plists match the output of plutil -convert json. JSON has no
+// types for data and dates, so data is written as a base64 string and dates
+// as RFC 3339 strings, which loses their kind.
+//
+// Typed JSON plists wrap every value in an object whose single key names the
+// kind of the value, and keep everything needed to restore it exactly:
+//
+//	{"dict": {"key": {"string": "value"}}}
+//	{"array": [{"bool": true}, {"int": "-42"}, {"uint": "42"}]}
+//	{"real32": "0.5"}, {"real64": "nan"}
+//	{"data": "3q2+7w=="}, {"date": "2011-05-12T01:00:00Z"}
+//	{"set": [{"string": "a"}]}, {"orderedset": []}, {"uid": "1"}, {"null": null}
+//
+// Numbers are written as strings so that 64-bit values, NaN and infinities
+// survive JSON implementations that use float64 numbers.
+type jsonEncoder struct {
+	writer   *bufio.Writer
+	indent   string
+	keyOrder KeyOrder
+	depth    int
+	typed    bool
+}
+
+func newJSONEncoder(w io.Writer, typed bool) *jsonEncoder {
+	return &jsonEncoder{writer: bufio.NewWriter(w), typed: typed}
+}
+
+func (e *jsonEncoder) Indent(indent string) {
+	e.indent = indent
+}
+
+func (e *jsonEncoder) generateDocument(pval *plistValue) error {
+	if err := e.writePlistValue(pval); err != nil {
+		return err
+	}
+	// newline at the end of a plist document
+	e.writer.WriteByte('\n')
+	return e.writer.Flush()
+}
+
+func (e *jsonEncoder) writePlistValue(pval *plistValue) error {
+	if e.typed {
+		return e.writeTypedValue(pval)
+	}
+	switch pval.kind {
+	case String:
+		e.writeString(pval.value.(string))
+	case Integer:
+		e.writer.WriteString(formatInteger(pval.value.(signedInt)))
+	case Real:
+		f := pval.value.(sizedFloat)
+		if math.IsInf(f.value, 0) || math.IsNaN(f.value) {
+			return &UnsupportedValueError{Value: reflect.ValueOf(f.value), Str: formatReal(f)}
+		}
+		e.writer.WriteString(formatReal(f))
+	case Boolean:
+		e.writer.WriteString(strconv.FormatBool(pval.value.(bool)))
+	case Data:
+		e.writeString(base64.StdEncoding.EncodeToString(pval.value.([]byte)))
+	case Date:
+		e.writeString(pval.value.(time.Time).In(time.UTC).Format(time.RFC3339))
+	case Array, SetKind, OrderedSetKind:
+		// JSON has no sets.
+		return e.writeArray(pval.value.([]*plistValue))
+	case Dictionary:
+		return e.writeDictionary(pval.value.(*dictionary))
+	case UIDKind:
+		return e.writeDictionary(uidDictionary(pval.value.(UID)).value.(*dictionary))
+	case NullKind:
+		e.writer.WriteString("null")
+	default:
+		return kindError(pval)
+	}
+	return nil
+}
+
+func (e *jsonEncoder) writeTypedValue(pval *plistValue) error {
+	e.writer.WriteByte('{')
+	switch pval.kind {
+	case String:
+		e.writeTypedScalar("string", pval.value.(string))
+	case Integer:
+		if pval.value.(signedInt).signed {
+			e.writeTypedScalar("int", formatInteger(pval.value.(signedInt)))
+		} else {
+			e.writeTypedScalar("uint", formatInteger(pval.value.(signedInt)))
+		}
+	case Real:
+		f := pval.value.(sizedFloat)
+		if f.bits == 32 {
+			e.writeTypedScalar("real32", formatReal(f))
+		} else {
+			e.writeTypedScalar("real64", formatReal(f))
+		}
+	case Boolean:
+		e.writeKey("bool")
+		e.writer.WriteString(strconv.FormatBool(pval.value.(bool)))
+	case Data:
+		e.writeTypedScalar("data", base64.StdEncoding.EncodeToString(pval.value.([]byte)))
+	case Date:
+		e.writeTypedScalar("date", pval.value.(time.Time).In(time.UTC).Format(time.RFC3339Nano))
+	case Array:
+		e.writeKey("array")
+		if err := e.writeArray(pval.value.([]*plistValue)); err != nil {
+			return err
+		}
+	case Dictionary:
+		e.writeKey("dict")
+		if err := e.writeDictionary(pval.value.(*dictionary)); err != nil {
+			return err
+		}
+	case SetKind, OrderedSetKind:
+		if pval.kind == SetKind {
+			e.writeKey("set")
+		} else {
+			e.writeKey("orderedset")
+		}
+		if err := e.writeArray(pval.value.([]*plistValue)); err != nil {
+			return err
+		}
+	case UIDKind:
+		e.writeTypedScalar("uid", strconv.FormatUint(uint64(pval.value.(UID)), 10))
+	case NullKind:
+		e.writeKey("null")
+		e.writer.WriteString("null")
+	default:
+		return kindError(pval)
+	}
+	e.writer.WriteByte('}')
+	return nil
+}
+
+func (e *jsonEncoder) writeTypedScalar(typ, s string) {
+	e.writeKey(typ)
+	e.writeString(s)
+}
+
+// writeKey writes an object key and the colon following it.
+func (e *jsonEncoder) writeKey(key string) {
+	e.writeString(key)
+	e.writer.WriteByte(':')
+	if e.indent != "" {
+		e.writer.WriteByte(' ')
+	}
+}
+
+// writeNewline starts a new line at the current depth when indenting.
+func (e *jsonEncoder) writeNewline() {
+	if e.indent == "" {
+		return
+	}
+	e.writer.WriteByte('\n')
+	for i := 0; i < e.depth; i++ {
+		e.writer.WriteString(e.indent)
+	}
+}
+
+func (e *jsonEncoder) writeArray(values []*plistValue) error {
+	e.writer.WriteByte('[')
+	e.depth++
+	for i, v := range values {
+		if i > 0 {
+			e.writer.WriteByte(',')
+		}
+		e.writeNewline()
+		if err := e.writePlistValue(v); err != nil {
+			return prependPath(err, indexPath(i))
+		}
+	}
+	e.depth--
+	if len(values) > 0 {
+		e.writeNewline()
+	}
+	e.writer.WriteByte(']')
+	return nil
+}
+
+func (e *jsonEncoder) writeDictionary(dict *dictionary) error {
+	dict.populateArrays(e.keyOrder)
+	e.writer.WriteByte('{')
+	e.depth++
+	for i, k := range dict.keys {
+		if i > 0 {
+			e.writer.WriteByte(',')
+		}
+		e.writeNewline()
+		e.writeKey(k)
+		if err := e.writePlistValue(dict.values[i]); err != nil {
+			return prependPath(err, k)
+		}
+	}
+	e.depth--
+	if len(dict.keys) > 0 {
+		e.writeNewline()
+	}
+	e.writer.WriteByte('}')
+	return nil
+}
+
+// writeString writes s as a JSON string. Unlike encoding/json, it doesn't
+// escape HTML characters.
+func (e *jsonEncoder) writeString(s string) {
+	e.writer.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				e.writer.WriteByte('\\')
+				e.writer.WriteByte(c)
+			case c == '\n':
+				e.writer.WriteString(`\n`)
+			case c == '\r':
+				e.writer.WriteString(`\r`)
+			case c == '\t':
+				e.writer.WriteString(`\t`)
+			case c < 0x20:
+				fmt.Fprintf(e.writer, `\u%04x`, c)
+			default:
+				e.writer.WriteByte(c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			e.writer.WriteString(`\ufffd`)
+		case r == '\u2028' || r == '\u2029':
+			// valid JSON, but not valid JavaScript
+			fmt.Fprintf(e.writer, `\u%04x`, r)
+		default:
+			e.writer.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	e.writer.WriteByte('"')
+}
@@ -0,0 +1,423 @@
+package plist
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const keyedArchiveXML = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>$archiver</key>
+	<string>NSKeyedArchiver</string>
+	<key>$objects</key>
+	<array>
+		<string>$null</string>
+		<dict>
+			<key>$class</key>
+			<dict><key>CF$UID</key><integer>7</integer></dict>
+			<key>NS.keys</key>
+			<array>
+				<dict><key>CF$UID</key><integer>2</integer></dict>
+				<dict><key>CF$UID</key><integer>3</integer></dict>
+				<dict><key>CF$UID</key><integer>4</integer></dict>
+			</array>
+			<key>NS.objects</key>
+			<array>
+				<dict><key>CF$UID</key><integer>5</integer></dict>
+				<dict><key>CF$UID</key><integer>8</integer></dict>
+				<dict><key>CF$UID</key><integer>0</integer></dict>
+			</array>
+		</dict>
+		<string>name</string>
+		<string>created</string>
+		<string>nothing</string>
+		<dict>
+			<key>$class</key>
+			<dict><key>CF$UID</key><integer>6</integer></dict>
+			<key>NS.string</key>
+			<string>archive</string>
+		</dict>
+		<dict>
+			<key>$classes</key>
+			<array><string>NSMutableString</string><string>NSString</string><string>NSObject</string></array>
+			<key>$classname</key>
+			<string>NSMutableString</string>
+		</dict>
+		<dict>
+			<key>$classes</key>
+			<array><string>NSDictionary</string><string>NSObject</string></array>
+			<key>$classname</key>
+			<string>NSDictionary</string>
+		</dict>
+		<dict>
+			<key>$class</key>
+			<dict><key>CF$UID</key><integer>9</integer></dict>
+			<key>NS.time</key>
+			<real>327024000.5</real>
+		</dict>
+		<dict>
+			<key>$classes</key>
+			<array><string>NSDate</string><string>NSObject</string></array>
+			<key>$classname</key>
+			<string>NSDate</string>
+		</dict>
+	</array>
+	<key>$top</key>
+	<dict>
+		<key>root</key>
+		<dict><key>CF$UID</key><integer>1</integer></dict>
+	</dict>
+	<key>$version</key>
+	<integer>100000</integer>
+</dict>
+</plist>
+`
+
+func TestUnarchiveXML(t *testing.T) {
+	var root interface{}
+	if err := Unarchive([]byte(keyedArchiveXML), &root); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"name":    "archive",
+		"created": time.Date(2011, 5, 14, 0, 0, 0, 5e8, time.UTC),
+		"nothing": nil,
+	}
+	if !reflect.DeepEqual(root, want) {
+		t.Errorf("got %#v, want %#v", root, want)
+	}
+
+	var m map[string]interface{}
+	if err := Unarchive([]byte(keyedArchiveXML), &m); err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	if err := Unarchive([]byte(keyedArchiveXML), &s); err == nil {
+		t.Error("expected an error unarchiving a dictionary into a string")
+	}
+}
+
+// archiveClass returns an archived class dictionary for the given hierarchy.
+func archiveClass(classes ...string) map[string]interface{} {
+	return map[string]interface{}{
+		"$classname": classes[0],
+		"$classes":   classes,
+	}
+}
+
+func archive(objects ...interface{}) []byte {
+	b, err := MarshalBinary(map[string]interface{}{
+		"$archiver": "NSKeyedArchiver",
+		"$version":  100000,
+		"$top":      map[string]interface{}{"root": UID(1)},
+		"$objects":  append([]interface{}{"$null"}, objects...),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestUnarchiveBinary(t *testing.T) {
+	data := archive(
+		map[string]interface{}{"$class": UID(2), "NS.objects": []interface{}{UID(3), UID(4), UID(5), UID(7), UID(9), UID(11), UID(3)}},
+		archiveClass("NSMutableArray", "NSArray", "NSObject"),
+		"string",
+		[]byte{1, 2, 3},
+		map[string]interface{}{"$class": UID(6), "NS.intval": -42},
+		archiveClass("NSNumber", "NSValue", "NSObject"),
+		map[string]interface{}{"$class": UID(8), "NS.uuidbytes": []byte("0123456789abcdef")},
+		archiveClass("NSUUID", "NSObject"),
+		map[string]interface{}{"$class": UID(10), "NS.base": UID(0), "NS.relative": "https://example.com/a?b=c"},
+		archiveClass("NSURL", "NSObject"),
+		map[string]interface{}{"$class": UID(12), "NS.objects": []interface{}{UID(3)}},
+		archiveClass("NSSet", "NSObject"),
+	)
+
+	var root []interface{}
+	if err := Unarchive(data, &root); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("https://example.com/a?b=c")
+	var uuid [16]byte
+	copy(uuid[:], "0123456789abcdef")
+	want := []interface{}{"string", []byte{1, 2, 3}, int64(-42), uuid, u, []interface{}{"string"}, "string"}
+	if !reflect.DeepEqual(root, want) {
+		t.Errorf("got %#v, want %#v", root, want)
+	}
+}
+
+type point struct {
+	X, Y int64
+}
+
+func TestUnarchiveClassRegistry(t *testing.T) {
+	data := archive(
+		map[string]interface{}{"$class": UID(2), "x": 1, "y": UID(3)},
+		archiveClass("MyPoint", "NSObject"),
+		map[string]interface{}{"$class": UID(4), "NS.intval": 2},
+		archiveClass("NSNumber", "NSValue", "NSObject"),
+	)
+
+	var root interface{}
+	err := Unarchive(data, &root)
+	if uerr, ok := err.(*UnknownClassError); !ok || uerr.Classes[0] != "MyPoint" {
+		t.Fatalf("got %v, want UnknownClassError for MyPoint", err)
+	}
+
+	reg := NewClassRegistry()
+	reg.Register("MyPoint", func(obj *KeyedObject) (interface{}, error) {
+		if keys := obj.Keys(); !reflect.DeepEqual(keys, []string{"x", "y"}) {
+			return nil, fmt.Errorf("unexpected keys %v", keys)
+		}
+		x, err := obj.Decode("x")
+		if err != nil {
+			return nil, err
+		}
+		y, err := obj.Decode("y")
+		if err != nil {
+			return nil, err
+		}
+		return point{x.(int64), y.(int64)}, nil
+	})
+	var p point
+	u := NewKeyedUnarchiver(bytes.NewReader(data))
+	u.Classes(reg)
+	if err := u.Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if want := (point{1, 2}); p != want {
+		t.Errorf("got %v, want %v", p, want)
+	}
+}
+
+func TestUnarchiveErrors(t *testing.T) {
+	tests := map[string][]byte{
+		"not an archive": []byte(`<plist><array/></plist>`),
+		"bad reference": archive(
+			map[string]interface{}{"$class": UID(2), "NS.objects": []interface{}{UID(20)}},
+			archiveClass("NSArray", "NSObject"),
+		),
+		"cycle": archive(
+			map[string]interface{}{"x": UID(1)},
+		),
+	}
+	for name, data := range tests {
+		var root interface{}
+		if err := Unarchive(data, &root); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestUnarchiveCycle(t *testing.T) {
+	// An array holding itself and a dictionary which holds the array.
+	data := archive(
+		map[string]interface{}{"$class": UID(2), "NS.objects": []interface{}{UID(1), UID(3)}},
+		archiveClass("NSArray", "NSObject"),
+		map[string]interface{}{"$class": UID(5), "NS.keys": []interface{}{UID(4)}, "NS.objects": []interface{}{UID(1)}},
+		"array",
+		archiveClass("NSDictionary", "NSObject"),
+	)
+	var root []interface{}
+	if err := Unarchive(data, &root); err != nil {
+		t.Fatal(err)
+	}
+	if len(root) != 2 {
+		t.Fatalf("got %d objects, want 2", len(root))
+	}
+	if self, ok := root[0].([]interface{}); !ok || len(self) != 2 || &self[0] != &root[0] {
+		t.Errorf("got %T, want the array itself", root[0])
+	}
+	m, ok := root[1].(map[string]interface{})
+	if !ok {
+		t.Fatalf("got %T, want a dictionary", root[1])
+	}
+	if array, ok := m["array"].([]interface{}); !ok || len(array) != 2 || &array[0] != &root[0] {
+		t.Errorf("got %T, want the array holding the dictionary", m["array"])
+	}
+
+	// A node of a class with a decoder which sets its value up front.
+	data = archive(
+		map[string]interface{}{"$class": UID(2), "next": UID(1)},
+		archiveClass("MyNode", "NSObject"),
+	)
+	reg := NewClassRegistry()
+	reg.Register("MyNode", func(obj *KeyedObject) (interface{}, error) {
+		n := new(archivedNode)
+		obj.SetValue(n)
+		next, err := obj.Decode("next")
+		if err != nil {
+			return nil, err
+		}
+		n.Next = next.(*archivedNode)
+		return n, nil
+	})
+	var n *archivedNode
+	u := NewKeyedUnarchiver(bytes.NewReader(data))
+	u.Classes(reg)
+	if err := u.Decode(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n == nil || n.Next != n {
+		t.Errorf("got %p, want a node whose next node is itself", n)
+	}
+}
+
+// archivedChain returns an archive of n arrays, each holding the next one.
+func archivedChain(n int) []byte {
+	objects := make([]interface{}, 0, n+1)
+	for i := 1; i <= n; i++ {
+		next := []interface{}{UID(i + 1)}
+		if i == n {
+			next = []interface{}{}
+		}
+		objects = append(objects, map[string]interface{}{"$class": UID(n + 1), "NS.objects": next})
+	}
+	return archive(append(objects, archiveClass("NSArray", "NSObject"))...)
+}
+
+func TestUnarchiveDepthLimit(t *testing.T) {
+	// A long chain would overflow the stack if references were followed
+	// without a limit.
+	var root interface{}
+	var lerr *LimitError
+	if err := Unarchive(archivedChain(100000), &root); !errors.As(err, &lerr) || lerr.Limit != "MaxDepth" {
+		t.Fatalf("have error %v, want a MaxDepth *LimitError", err)
+	}
+
+	data := archivedChain(4)
+	if err := Unarchive(data, &root); err != nil {
+		t.Fatal(err)
+	}
+	u := NewKeyedUnarchiver(bytes.NewReader(data))
+	u.SetLimits(DecoderLimits{MaxDepth: 3})
+	if err := u.Decode(&root); !errors.As(err, &lerr) || lerr.Limit != "MaxDepth" {
+		t.Errorf("have error %v, want a MaxDepth *LimitError", err)
+	}
+}
+
+type archivedPoint struct {
+	X, Y  int64
+	Label string
+}
+
+func (p *archivedPoint) MarshalKeyed() ([]string, map[string]interface{}, error) {
+	return []string{"MyPoint", "NSObject"}, map[string]interface{}{
+		"x":     p.X,
+		"y":     p.Y,
+		"label": p.Label,
+	}, nil
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	shared := []string{"a", "b"}
+	type inner struct {
+		Name  string `plist:"name"`
+		Empty string `plist:"empty,omitempty"`
+	}
+	in := map[string]interface{}{
+		"string": "a",
+		"int":    -42,
+		"real":   1.5,
+		"bool":   true,
+		"data":   []byte{1, 2, 3},
+		"date":   time.Date(2011, 5, 14, 0, 0, 0, 5e8, time.UTC),
+		"array":  shared,
+		"again":  shared,
+		"struct": inner{Name: "b"},
+		"nil":    nil,
+		"point":  &archivedPoint{1, 2, "a"},
+	}
+	data, err := Archive(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := NewClassRegistry()
+	reg.Register("MyPoint", func(obj *KeyedObject) (interface{}, error) {
+		x, _ := obj.Decode("x")
+		y, _ := obj.Decode("y")
+		label, err := obj.Decode("label")
+		return archivedPoint{x.(int64), y.(int64), label.(string)}, err
+	})
+	var out interface{}
+	u := NewKeyedUnarchiver(bytes.NewReader(data))
+	u.Classes(reg)
+	if err := u.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"string": "a",
+		"int":    int64(-42),
+		"real":   1.5,
+		"bool":   true,
+		"data":   []byte{1, 2, 3},
+		"date":   time.Date(2011, 5, 14, 0, 0, 0, 5e8, time.UTC),
+		"array":  []interface{}{"a", "b"},
+		"again":  []interface{}{"a", "b"},
+		"struct": map[string]interface{}{"name": "b"},
+		"nil":    nil,
+		"point":  archivedPoint{1, 2, "a"},
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("got %#v, want %#v", out, want)
+	}
+}
+
+func TestArchiveDedup(t *testing.T) {
+	shared := &archivedPoint{1, 2, "a"}
+	data, err := Archive([]interface{}{"a", "a", shared, shared, []string{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive struct {
+		Objects []interface{}  `plist:"$objects"`
+		Top     map[string]UID `plist:"$top"`
+	}
+	if err := Unmarshal(data, &archive); err != nil {
+		t.Fatal(err)
+	}
+	// $null, the root array, NSArray, "a", the point, MyPoint, the inner
+	// array
+	if len(archive.Objects) != 7 {
+		t.Errorf("got %d objects, want 7: %v", len(archive.Objects), archive.Objects)
+	}
+	if archive.Top["root"] != 1 {
+		t.Errorf("got root %d, want 1", archive.Top["root"])
+	}
+}
+
+type archivedNode struct {
+	Next *archivedNode
+}
+
+func TestArchiveCycle(t *testing.T) {
+	n := &archivedNode{}
+	n.Next = n
+	data, err := Archive(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root interface{}
+	if err := Unarchive(data, &root); err != nil {
+		t.Fatal(err)
+	}
+	m, ok := root.(map[string]interface{})
+	if !ok {
+		t.Fatalf("got %T, want a dictionary", root)
+	}
+	if next, ok := m["Next"].(map[string]interface{}); !ok || reflect.ValueOf(next).Pointer() != reflect.ValueOf(m).Pointer() {
+		t.Errorf("got Next %T, want the dictionary itself", m["Next"])
+	}
+	if _, err := Archive(map[int]string{1: "a"}); err == nil {
+		t.Error("expected an error archiving a map with int keys")
+	}
+}
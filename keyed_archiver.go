@@ -0,0 +1,372 @@
+package plist
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KeyedMarshaler is implemented by types which archive themselves as an
+// object of their own class in a keyed archive.
+//
+// MarshalKeyed returns the class hierarchy of the object, most derived class
+// first, and its fields. Numbers and booleans are archived inline in the
+// object, like NSCoder's encodeInt:forKey:; all other values are archived as
+// references to objects, like encodeObject:forKey:.
+type KeyedMarshaler interface {
+	MarshalKeyed() (classes []string, fields map[string]interface{}, err error)
+}
+
+// A KeyedArchiver writes NSKeyedArchiver archives as binary plists, which
+// Foundation's NSKeyedUnarchiver can read.
+//
+// Values are archived the way Encoder maps them to plist values. Strings,
+// numbers, booleans and []byte are stored as plain values in the $objects
+// table, and are shared by all their occurrences. Slices and arrays are
+// archived as NSArray objects, Set and OrderedSet values as NSSet and
+// NSOrderedSet objects, maps and structs as NSDictionary objects and
+// time.Time as NSDate objects. Pointers, maps and slices referenced more than
+// once are archived once. Nil pointers and interfaces are archived as $null.
+type KeyedArchiver struct {
+	w io.Writer
+}
+
+// NewKeyedArchiver returns a new archiver that writes to w.
+func NewKeyedArchiver(w io.Writer) *KeyedArchiver {
+	return &KeyedArchiver{w: w}
+}
+
+// Archive returns the NSKeyedArchiver archive of v, with v as its root object.
+func Archive(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewKeyedArchiver(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Encode writes an archive with v as its root object to the stream.
+func (a *KeyedArchiver) Encode(v interface{}) error {
+	state := newKeyedEncodeState()
+	root, err := state.archive(reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+	archive := &dictionary{m: map[string]*plistValue{
+		"$archiver": {String, "NSKeyedArchiver"},
+		"$version":  {Integer, signedInt{100000, false}},
+		"$top": {Dictionary, &dictionary{m: map[string]*plistValue{
+			"root": {UIDKind, root},
+		}}},
+		"$objects": {Array, state.objects},
+	}}
+	return newBinaryEncoder(a.w).generateDocument(&plistValue{Dictionary, archive})
+}
+
+// objectRef identifies a pointer, map or slice, so that objects referenced
+// more than once are archived once.
+type objectRef struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+// scalarKey identifies a plain value in the $objects table.
+type scalarKey struct {
+	kind  Kind
+	value interface{}
+}
+
+type keyedEncodeState struct {
+	objects []*plistValue
+	refs    map[objectRef]UID
+	scalars map[scalarKey]UID
+	classes map[string]UID
+}
+
+func newKeyedEncodeState() *keyedEncodeState {
+	return &keyedEncodeState{
+		// UID 0 is always $null.
+		objects: []*plistValue{{String, "$null"}},
+		refs:    make(map[objectRef]UID),
+		scalars: make(map[scalarKey]UID),
+		classes: make(map[string]UID),
+	}
+}
+
+var (
+	keyedMarshalerType = reflect.TypeOf((*KeyedMarshaler)(nil)).Elem()
+	marshalerType      = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	timeType           = reflect.TypeOf(time.Time{})
+	uidType            = reflect.TypeOf(UID(0))
+)
+
+// archive adds v to the $objects table and returns its UID.
+func (s *keyedEncodeState) archive(v reflect.Value) (UID, error) {
+	return s.archiveRef(v, objectRef{})
+}
+
+// archiveRef archives v, which is referenced by ref if ref is set.
+// Objects are registered under ref before their contents are archived, so
+// that cyclic references resolve to them.
+func (s *keyedEncodeState) archiveRef(v reflect.Value, ref objectRef) (UID, error) {
+	if !v.IsValid() {
+		return 0, nil
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return 0, nil
+		}
+		if v.Kind() != reflect.Interface && ref.typ == nil {
+			ref = objectRef{v.Type(), v.Pointer(), 0}
+			if v.Kind() == reflect.Slice {
+				ref.len = v.Len()
+			}
+			if uid, ok := s.refs[ref]; ok {
+				return uid, nil
+			}
+		}
+	}
+
+	if v.CanInterface() && v.Type().Implements(keyedMarshalerType) {
+		return s.archiveKeyedMarshaler(v.Interface().(KeyedMarshaler), ref)
+	}
+	if v.CanAddr() && v.Addr().CanInterface() && v.Addr().Type().Implements(keyedMarshalerType) {
+		return s.archiveKeyedMarshaler(v.Addr().Interface().(KeyedMarshaler), ref)
+	}
+	if v.CanInterface() && v.Type().Implements(marshalerType) {
+		val, err := v.Interface().(Marshaler).MarshalPlist()
+		if err != nil {
+			return 0, err
+		}
+		return s.archive(reflect.ValueOf(val))
+	}
+	if v.CanAddr() && v.Addr().CanInterface() && v.Addr().Type().Implements(marshalerType) {
+		val, err := v.Addr().Interface().(Marshaler).MarshalPlist()
+		if err != nil {
+			return 0, err
+		}
+		return s.archive(reflect.ValueOf(val))
+	}
+
+	switch v.Type() {
+	case timeType:
+		return s.archiveDate(v.Interface().(time.Time), ref)
+	case uidType:
+		return 0, &UnsupportedTypeError{Type: v.Type()}
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		return s.archive(v.Elem())
+	case reflect.Ptr:
+		return s.archiveRef(v.Elem(), ref)
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return s.archiveScalar(v, ref)
+		}
+		switch v.Type() {
+		case reflect.TypeOf(Set(nil)):
+			return s.archiveArray(v, ref, "NSSet", "NSObject")
+		case reflect.TypeOf(OrderedSet(nil)):
+			return s.archiveArray(v, ref, "NSOrderedSet", "NSObject")
+		}
+		return s.archiveArray(v, ref, "NSArray", "NSObject")
+	case reflect.Map:
+		return s.archiveMap(v, ref)
+	case reflect.Struct:
+		return s.archiveStruct(v, ref)
+	default:
+		return s.archiveScalar(v, ref)
+	}
+}
+
+// reserve adds a placeholder for an object to the $objects table.
+func (s *keyedEncodeState) reserve(ref objectRef) UID {
+	uid := UID(len(s.objects))
+	s.objects = append(s.objects, nil)
+	if ref.typ != nil {
+		s.refs[ref] = uid
+	}
+	return uid
+}
+
+// archiveScalar archives a string, number, boolean or data as a plain value.
+func (s *keyedEncodeState) archiveScalar(v reflect.Value, ref objectRef) (UID, error) {
+	pval, err := (&Encoder{}).marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	key := scalarKey{pval.kind, pval.value}
+	switch pval.kind {
+	case Data:
+		key.value = string(pval.value.([]byte))
+	case Array, Dictionary, SetKind, OrderedSetKind:
+		// collections held by a Value
+		return 0, &UnsupportedTypeError{Type: v.Type()}
+	}
+	uid, ok := s.scalars[key]
+	if !ok {
+		uid = s.reserve(objectRef{})
+		s.objects[uid] = pval
+		s.scalars[key] = uid
+	}
+	if ref.typ != nil {
+		s.refs[ref] = uid
+	}
+	return uid, nil
+}
+
+// classUID returns the UID of the class dictionary for the given hierarchy.
+func (s *keyedEncodeState) classUID(classes []string) UID {
+	key := strings.Join(classes, "\x00")
+	if uid, ok := s.classes[key]; ok {
+		return uid
+	}
+	names := make([]*plistValue, len(classes))
+	for i, name := range classes {
+		names[i] = &plistValue{String, name}
+	}
+	uid := s.reserve(objectRef{})
+	s.objects[uid] = &plistValue{Dictionary, &dictionary{m: map[string]*plistValue{
+		"$classname": {String, classes[0]},
+		"$classes":   {Array, names},
+	}}}
+	s.classes[key] = uid
+	return uid
+}
+
+// newObject returns the fields of a new object of the given class, stored in
+// the $objects table at uid.
+func (s *keyedEncodeState) newObject(uid UID, classes ...string) map[string]*plistValue {
+	fields := map[string]*plistValue{
+		"$class": {UIDKind, s.classUID(classes)},
+	}
+	s.objects[uid] = &plistValue{Dictionary, &dictionary{m: fields}}
+	return fields
+}
+
+func (s *keyedEncodeState) archiveDate(t time.Time, ref objectRef) (UID, error) {
+	uid := s.reserve(ref)
+	fields := s.newObject(uid, "NSDate", "NSObject")
+	secs := float64(t.Unix()-978307200) + float64(t.Nanosecond())/1e9
+	fields["NS.time"] = &plistValue{Real, sizedFloat{secs, 64}}
+	return uid, nil
+}
+
+// archiveUIDs archives each of values and returns an array of their UIDs.
+func (s *keyedEncodeState) archiveUIDs(values []reflect.Value) (*plistValue, error) {
+	uids := make([]*plistValue, len(values))
+	for i, v := range values {
+		uid, err := s.archive(v)
+		if err != nil {
+			return nil, err
+		}
+		uids[i] = &plistValue{UIDKind, uid}
+	}
+	return &plistValue{Array, uids}, nil
+}
+
+// archiveArray archives the elements of v as an array of the given class.
+func (s *keyedEncodeState) archiveArray(v reflect.Value, ref objectRef, classes ...string) (UID, error) {
+	uid := s.reserve(ref)
+	fields := s.newObject(uid, classes...)
+	values := make([]reflect.Value, v.Len())
+	for i := range values {
+		values[i] = v.Index(i)
+	}
+	objects, err := s.archiveUIDs(values)
+	if err != nil {
+		return 0, err
+	}
+	fields["NS.objects"] = objects
+	return uid, nil
+}
+
+// archiveDictionary archives an NSDictionary with the given keys and values.
+func (s *keyedEncodeState) archiveDictionary(ref objectRef, keys []string, values []reflect.Value) (UID, error) {
+	uid := s.reserve(ref)
+	fields := s.newObject(uid, "NSDictionary", "NSObject")
+	keyValues := make([]reflect.Value, len(keys))
+	for i, k := range keys {
+		keyValues[i] = reflect.ValueOf(k)
+	}
+	var err error
+	if fields["NS.keys"], err = s.archiveUIDs(keyValues); err != nil {
+		return 0, err
+	}
+	if fields["NS.objects"], err = s.archiveUIDs(values); err != nil {
+		return 0, err
+	}
+	return uid, nil
+}
+
+func (s *keyedEncodeState) archiveMap(v reflect.Value, ref objectRef) (UID, error) {
+	if v.Type().Key().Kind() != reflect.String {
+		return 0, &UnsupportedTypeError{Type: v.Type()}
+	}
+	keys := make([]string, 0, v.Len())
+	for _, keyv := range v.MapKeys() {
+		keys = append(keys, keyv.String())
+	}
+	sort.Strings(keys)
+	values := make([]reflect.Value, len(keys))
+	for i, k := range keys {
+		values[i] = v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))
+	}
+	return s.archiveDictionary(ref, keys, values)
+}
+
+func (s *keyedEncodeState) archiveStruct(v reflect.Value, ref objectRef) (UID, error) {
+	var keys []string
+	var values []reflect.Value
+	for _, field := range cachedTypeFields(v.Type()) {
+		val := field.value(v)
+		if field.omitEmpty && isEmptyValue(val) {
+			continue
+		}
+		keys = append(keys, field.name)
+		values = append(values, val)
+	}
+	return s.archiveDictionary(ref, keys, values)
+}
+
+func (s *keyedEncodeState) archiveKeyedMarshaler(m KeyedMarshaler, ref objectRef) (UID, error) {
+	classes, values, err := m.MarshalKeyed()
+	if err != nil {
+		return 0, err
+	}
+	if len(classes) == 0 {
+		return 0, fmt.Errorf("plist: %T archived without a class name", m)
+	}
+	uid := s.reserve(ref)
+	fields := s.newObject(uid, classes...)
+	for k, fv := range values {
+		if k == "$class" {
+			return 0, fmt.Errorf("plist: %T archived a field named $class", m)
+		}
+		v := reflect.ValueOf(fv)
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Float32, reflect.Float64, reflect.Bool:
+			if v.Type() != uidType {
+				if fields[k], err = (&Encoder{}).marshal(v); err != nil {
+					return 0, err
+				}
+				continue
+			}
+		}
+		ref, err := s.archive(v)
+		if err != nil {
+			return 0, err
+		}
+		fields[k] = &plistValue{UIDKind, ref}
+	}
+	return uid, nil
+}
@@ -0,0 +1,591 @@
+package plist
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A KeyedUnarchiver decodes archives written by NSKeyedArchiver.
+//
+// An archive is a plist whose $objects array holds every archived object,
+// and whose $top dictionary references the root objects by UID. Objects which
+// aren't plain plist values are dictionaries with a $class UID, referencing a
+// dictionary which lists the class hierarchy of the object in $classes.
+// Each object is decoded by the ClassDecoder registered for the most derived
+// class of its hierarchy.
+type KeyedUnarchiver struct {
+	reader  io.Reader
+	classes *ClassRegistry
+	limits  DecoderLimits
+}
+
+// NewKeyedUnarchiver returns a new unarchiver that reads an archive from r,
+// using the DefaultClassRegistry to decode objects.
+// The archive may be in any plist format that NewDecoder detects.
+func NewKeyedUnarchiver(r io.Reader) *KeyedUnarchiver {
+	return &KeyedUnarchiver{reader: r, classes: DefaultClassRegistry}
+}
+
+// Unarchive decodes the root object of an NSKeyedArchiver archive and stores
+// it in the value pointed to by v.
+func Unarchive(data []byte, v interface{}) error {
+	return NewKeyedUnarchiver(bytes.NewReader(data)).Decode(v)
+}
+
+// Classes sets the registry used to decode objects.
+func (u *KeyedUnarchiver) Classes(reg *ClassRegistry) {
+	u.classes = reg
+}
+
+// SetLimits sets the limits on the resources spent reading an archive, like
+// Decoder.SetLimits. MaxDepth also bounds how many references to other
+// objects may be followed from the root object down.
+func (u *KeyedUnarchiver) SetLimits(limits DecoderLimits) {
+	u.limits = limits
+}
+
+// Decode reads an archive and stores its root object in the value pointed to
+// by v. The root object is the "root" entry of $top; archives without one
+// decode to a map of all the $top entries.
+//
+// The decoded object must be assignable to the value pointed to by v, which
+// is always the case for an empty interface. Built-in classes decode to the
+// following Go values:
+//
+//	NSArray, NSSet, NSOrderedSet  []interface{}
+//	NSDictionary                  map[string]interface{}, or map[interface{}]interface{}
+//	                              if it has keys which aren't strings
+//	NSString                      string
+//	NSData                        []byte
+//	NSDate                        time.Time
+//	NSNumber                      int64, float32, float64 or bool
+//	NSUUID                        [16]byte
+//	NSURL                         *url.URL
+//	NSNull, $null                 nil
+//
+// Archives may hold cycles. An NSArray or NSDictionary which contains itself
+// decodes to a slice or map which contains itself.
+func (u *KeyedUnarchiver) Decode(v interface{}) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return fmt.Errorf("plist: non-pointer passed to Unarchive")
+	}
+	d := NewDecoder(u.reader)
+	d.SetLimits(u.limits)
+	pval, err := d.parseDocument()
+	if err != nil {
+		return err
+	}
+	state, err := newKeyedDecodeState(pval, u.classes)
+	if err != nil {
+		return err
+	}
+	state.limits = newLimiter(u.limits)
+	root, err := state.decodeTop()
+	if err != nil {
+		return err
+	}
+
+	elem := val.Elem()
+	if root == nil {
+		elem.Set(reflect.Zero(elem.Type()))
+		return nil
+	}
+	rv := reflect.ValueOf(root)
+	if !rv.Type().AssignableTo(elem.Type()) {
+		return UnmarshalTypeError{Value: fmt.Sprintf("archived %T", root), Type: elem.Type()}
+	}
+	elem.Set(rv)
+	return nil
+}
+
+// keyedDecodeState holds the objects of an archive while it is decoded.
+type keyedDecodeState struct {
+	top     *dictionary
+	objects []*plistValue
+	classes *ClassRegistry
+
+	decoded  map[uint64]interface{} // decoded objects by UID, see KeyedObject.SetValue
+	decoding map[uint64]bool        // objects being decoded, to detect cycles
+	limits   *limiter               // bounds the depth of resolve
+}
+
+func newKeyedDecodeState(pval *plistValue, classes *ClassRegistry) (*keyedDecodeState, error) {
+	if pval.kind != Dictionary {
+		return nil, fmt.Errorf("plist: keyed archive is a %v, not a dictionary", pval.kind)
+	}
+	archive := pval.value.(*dictionary).m
+	top, objects := archive["$top"], archive["$objects"]
+	if top == nil || top.kind != Dictionary || objects == nil || objects.kind != Array {
+		return nil, fmt.Errorf("plist: not a keyed archive, $top or $objects is missing")
+	}
+	if classes == nil {
+		classes = DefaultClassRegistry
+	}
+	return &keyedDecodeState{
+		top:      top.value.(*dictionary),
+		objects:  objects.value.([]*plistValue),
+		classes:  classes,
+		decoded:  make(map[uint64]interface{}),
+		decoding: make(map[uint64]bool),
+		limits:   newLimiter(DecoderLimits{}),
+	}, nil
+}
+
+func (s *keyedDecodeState) decodeTop() (interface{}, error) {
+	if root, ok := s.top.m["root"]; ok {
+		return s.resolve(root)
+	}
+	return s.resolve(&plistValue{Dictionary, s.top})
+}
+
+// archiveUID returns the UID referenced by pval. Binary plists store UIDs
+// as their own kind, XML plists as dictionaries with a single CF$UID key.
+func archiveUID(pval *plistValue) (uint64, bool) {
+	switch pval.kind {
+	case UIDKind:
+		return uint64(pval.value.(UID)), true
+	case Dictionary:
+		dict := pval.value.(*dictionary).m
+		if ref, ok := dict["CF$UID"]; ok && len(dict) == 1 && ref.kind == Integer {
+			return ref.value.(signedInt).value, true
+		}
+	}
+	return 0, false
+}
+
+// resolve decodes pval, following UID references to the objects table.
+// Arrays and dictionaries which aren't archived objects themselves, such as
+// NS.objects, are resolved element by element.
+func (s *keyedDecodeState) resolve(pval *plistValue) (interface{}, error) {
+	// Objects referencing each other in a long chain would otherwise
+	// overflow the stack.
+	if err := s.limits.enter(); err != nil {
+		return nil, err
+	}
+	defer s.limits.leave()
+	if uid, ok := archiveUID(pval); ok {
+		return s.decodeObject(uid)
+	}
+	switch pval.kind {
+	case Array:
+		values := pval.value.([]*plistValue)
+		out := make([]interface{}, len(values))
+		for i, subv := range values {
+			var err error
+			if out[i], err = s.resolve(subv); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case Dictionary:
+		dict := pval.value.(*dictionary).m
+		out := make(map[string]interface{}, len(dict))
+		for k, subv := range dict {
+			var err error
+			if out[k], err = s.resolve(subv); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case Integer:
+		// NSKeyedArchiver only archives signed integers, but binary plists
+		// don't record the sign; see binaryParser.parseInteger.
+		return int64(pval.value.(signedInt).value), nil
+	default:
+		return (&Decoder{}).valueInterface(pval), nil
+	}
+}
+
+// decodeObject decodes the object with the given UID.
+func (s *keyedDecodeState) decodeObject(uid uint64) (interface{}, error) {
+	if v, ok := s.decoded[uid]; ok {
+		return v, nil
+	}
+	if uid >= uint64(len(s.objects)) {
+		return nil, fmt.Errorf("plist: keyed archive references object %d of %d", uid, len(s.objects))
+	}
+	if s.decoding[uid] {
+		return nil, fmt.Errorf("plist: keyed archive object %d references itself", uid)
+	}
+	pval := s.objects[uid]
+	if pval.kind == String && pval.value.(string) == "$null" {
+		return nil, nil
+	}
+	s.decoding[uid] = true
+	defer delete(s.decoding, uid)
+	if pval.kind != Dictionary || pval.value.(*dictionary).m["$class"] == nil {
+		// Strings, numbers and data are archived as plain plist values.
+		v, err := s.resolve(pval)
+		if err != nil {
+			return nil, err
+		}
+		s.decoded[uid] = v
+		return v, nil
+	}
+
+	obj, err := s.newKeyedObject(uid, pval.value.(*dictionary))
+	if err != nil {
+		return nil, err
+	}
+	dec, ok := s.classes.lookup(obj.Classes)
+	if !ok {
+		return nil, &UnknownClassError{Classes: obj.Classes}
+	}
+	v, err := dec(obj)
+	if err != nil {
+		delete(s.decoded, uid)
+		if _, ok := err.(*LimitError); ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("plist: decoding archived %s: %v", obj.ClassName(), err)
+	}
+	s.decoded[uid] = v
+	return v, nil
+}
+
+func (s *keyedDecodeState) newKeyedObject(uid uint64, fields *dictionary) (*KeyedObject, error) {
+	classUID, ok := archiveUID(fields.m["$class"])
+	if !ok || classUID >= uint64(len(s.objects)) {
+		return nil, fmt.Errorf("plist: keyed archive object %d has an invalid $class", uid)
+	}
+	class := s.objects[classUID]
+	var names []string
+	if class.kind == Dictionary {
+		cm := class.value.(*dictionary).m
+		if classes := cm["$classes"]; classes != nil && classes.kind == Array {
+			for _, name := range classes.value.([]*plistValue) {
+				if name.kind == String {
+					names = append(names, name.value.(string))
+				}
+			}
+		} else if name := cm["$classname"]; name != nil && name.kind == String {
+			names = []string{name.value.(string)}
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("plist: keyed archive object %d has no class name", uid)
+	}
+	return &KeyedObject{Classes: names, uid: uid, fields: fields.m, state: s}, nil
+}
+
+// A KeyedObject is an archived object, as handed to a ClassDecoder.
+type KeyedObject struct {
+	// Classes is the class hierarchy of the object, most derived class first.
+	Classes []string
+
+	uid    uint64
+	fields map[string]*plistValue
+	state  *keyedDecodeState
+}
+
+// ClassName returns the name of the class of the object.
+func (o *KeyedObject) ClassName() string {
+	return o.Classes[0]
+}
+
+// Keys returns the sorted keys of the archived fields of the object.
+func (o *KeyedObject) Keys() []string {
+	keys := make([]string, 0, len(o.fields))
+	for k := range o.fields {
+		if k != "$class" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Has reports whether the object has a field for key.
+func (o *KeyedObject) Has(key string) bool {
+	_, ok := o.fields[key]
+	return ok && key != "$class"
+}
+
+// SetValue sets the value which references to the object decode to while its
+// ClassDecoder runs. A ClassDecoder which calls SetValue with a pointer, map
+// or slice before decoding the fields of the object, and fills it in
+// afterwards, can decode objects which reference themselves, directly or
+// through other objects. Without it such references return an error.
+func (o *KeyedObject) SetValue(v interface{}) {
+	o.state.decoded[o.uid] = v
+}
+
+// Decode decodes the field for key. References to other objects are decoded
+// with the ClassDecoder for their class, and arrays of references, such as
+// NS.objects, decode to a []interface{} of the referenced objects.
+// Decode returns nil for missing fields.
+func (o *KeyedObject) Decode(key string) (interface{}, error) {
+	pval, ok := o.fields[key]
+	if !ok || key == "$class" {
+		return nil, nil
+	}
+	return o.state.resolve(pval)
+}
+
+// A ClassDecoder decodes an archived object of a registered class.
+type ClassDecoder func(obj *KeyedObject) (interface{}, error)
+
+// A ClassRegistry maps archived class names to the ClassDecoders that decode
+// them. It is safe for concurrent use.
+type ClassRegistry struct {
+	mu       sync.RWMutex
+	decoders map[string]ClassDecoder
+}
+
+// DefaultClassRegistry is the registry used by unarchivers unless they are
+// given another one.
+var DefaultClassRegistry = NewClassRegistry()
+
+// RegisterClass registers dec for className in the DefaultClassRegistry.
+func RegisterClass(className string, dec ClassDecoder) {
+	DefaultClassRegistry.Register(className, dec)
+}
+
+// NewClassRegistry returns a registry with decoders for the built-in
+// Foundation classes listed in KeyedUnarchiver.Decode.
+func NewClassRegistry() *ClassRegistry {
+	reg := &ClassRegistry{decoders: make(map[string]ClassDecoder)}
+	for _, name := range []string{"NSArray", "NSMutableArray", "NSSet", "NSMutableSet", "NSOrderedSet", "NSMutableOrderedSet"} {
+		reg.Register(name, decodeNSArray)
+	}
+	reg.Register("NSDictionary", decodeNSDictionary)
+	reg.Register("NSMutableDictionary", decodeNSDictionary)
+	reg.Register("NSString", decodeNSString)
+	reg.Register("NSMutableString", decodeNSString)
+	reg.Register("NSData", decodeNSData)
+	reg.Register("NSMutableData", decodeNSData)
+	reg.Register("NSDate", decodeNSDate)
+	reg.Register("NSNumber", decodeNSNumber)
+	reg.Register("NSUUID", decodeNSUUID)
+	reg.Register("NSURL", decodeNSURL)
+	reg.Register("NSNull", func(*KeyedObject) (interface{}, error) { return nil, nil })
+	return reg
+}
+
+// Register registers dec for className, replacing any previous decoder.
+func (r *ClassRegistry) Register(className string, dec ClassDecoder) {
+	r.mu.Lock()
+	r.decoders[className] = dec
+	r.mu.Unlock()
+}
+
+// lookup returns the decoder for the most derived class in classes which
+// has one.
+func (r *ClassRegistry) lookup(classes []string) (ClassDecoder, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, name := range classes {
+		if dec, ok := r.decoders[name]; ok {
+			return dec, true
+		}
+	}
+	return nil, false
+}
+
+// An UnknownClassError is returned when an archive contains an object of a
+// class for which no ClassDecoder is registered.
+type UnknownClassError struct {
+	Classes []string // class hierarchy of the object
+}
+
+func (e *UnknownClassError) Error() string {
+	return fmt.Sprintf("plist: no decoder registered for archived class %s %v", e.Classes[0], e.Classes)
+}
+
+func decodeNSArray(obj *KeyedObject) (interface{}, error) {
+	return decodeObjectList(obj, "NS.objects", true)
+}
+
+// decodeObjectList decodes the array of references in the field for key.
+// If setValue is true, the slice holding the objects is set as the value of
+// obj before they are decoded, so that objects which reference obj decode to
+// the slice.
+func decodeObjectList(obj *KeyedObject, key string, setValue bool) ([]interface{}, error) {
+	if pval, ok := obj.fields[key]; ok && pval.kind == Array {
+		refs := pval.value.([]*plistValue)
+		objects := make([]interface{}, len(refs))
+		if setValue {
+			obj.SetValue(objects)
+		}
+		for i, ref := range refs {
+			var err error
+			if objects[i], err = obj.state.resolve(ref); err != nil {
+				return nil, err
+			}
+		}
+		return objects, nil
+	}
+	v, err := obj.Decode(key)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return []interface{}{}, nil
+	}
+	objects, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is a %T", key, v)
+	}
+	return objects, nil
+}
+
+func decodeNSDictionary(obj *KeyedObject) (interface{}, error) {
+	keys, err := decodeObjectList(obj, "NS.keys", false)
+	if err != nil {
+		return nil, err
+	}
+	stringKeys := true
+	for _, k := range keys {
+		if _, ok := k.(string); !ok {
+			stringKeys = false
+		}
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, fmt.Errorf("key of type %T can't be used in a Go map", k)
+		}
+	}
+	// The map is set as the value of obj before the values are decoded, so
+	// that values which reference obj decode to it.
+	var m interface{}
+	if stringKeys {
+		m = make(map[string]interface{}, len(keys))
+	} else {
+		m = make(map[interface{}]interface{}, len(keys))
+	}
+	obj.SetValue(m)
+	values, err := decodeObjectList(obj, "NS.objects", false)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) != len(values) {
+		return nil, fmt.Errorf("%d keys for %d values", len(keys), len(values))
+	}
+	for i, k := range keys {
+		if stringKeys {
+			m.(map[string]interface{})[k.(string)] = values[i]
+		} else {
+			m.(map[interface{}]interface{})[k] = values[i]
+		}
+	}
+	return m, nil
+}
+
+func decodeNSString(obj *KeyedObject) (interface{}, error) {
+	v, err := obj.Decode("NS.string")
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		// Some strings are archived as UTF-8 bytes instead.
+		if v, err = obj.Decode("NS.bytes"); err != nil {
+			return nil, err
+		}
+		if b, ok := v.([]byte); ok {
+			return string(b), nil
+		}
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("NS.string is a %T", v)
+	}
+	return s, nil
+}
+
+func decodeNSData(obj *KeyedObject) (interface{}, error) {
+	v, err := obj.Decode("NS.data")
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return []byte(nil), nil
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("NS.data is a %T", v)
+	}
+	return b, nil
+}
+
+func decodeNSDate(obj *KeyedObject) (interface{}, error) {
+	v, err := obj.Decode("NS.time")
+	if err != nil {
+		return nil, err
+	}
+	var secs float64
+	switch v := v.(type) {
+	case float64:
+		secs = v
+	case float32:
+		secs = float64(v)
+	case int64:
+		secs = float64(v)
+	default:
+		return nil, fmt.Errorf("NS.time is a %T", v)
+	}
+	return appleEpochTime(secs), nil
+}
+
+// appleEpochTime converts seconds since the Apple epoch (Jan 1, 2001 GMT)
+// to a time.Time.
+func appleEpochTime(secs float64) time.Time {
+	secs += 978307200
+	whole := math.Floor(secs)
+	return time.Unix(int64(whole), int64((secs-whole)*1e9)).In(time.UTC)
+}
+
+func decodeNSNumber(obj *KeyedObject) (interface{}, error) {
+	for _, key := range []string{"NS.intval", "NS.dblval", "NS.boolval"} {
+		if obj.Has(key) {
+			return obj.Decode(key)
+		}
+	}
+	return nil, fmt.Errorf("no value")
+}
+
+func decodeNSUUID(obj *KeyedObject) (interface{}, error) {
+	v, err := obj.Decode("NS.uuidbytes")
+	if err != nil {
+		return nil, err
+	}
+	b, ok := v.([]byte)
+	if !ok || len(b) != 16 {
+		return nil, fmt.Errorf("NS.uuidbytes is not 16 bytes of data")
+	}
+	var uuid [16]byte
+	copy(uuid[:], b)
+	return uuid, nil
+}
+
+func decodeNSURL(obj *KeyedObject) (interface{}, error) {
+	v, err := obj.Decode("NS.relative")
+	if err != nil {
+		return nil, err
+	}
+	relative, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("NS.relative is a %T", v)
+	}
+	u, err := url.Parse(relative)
+	if err != nil {
+		return nil, err
+	}
+	base, err := obj.Decode("NS.base")
+	if err != nil {
+		return nil, err
+	}
+	switch base := base.(type) {
+	case nil:
+		return u, nil
+	case *url.URL:
+		return base.ResolveReference(u), nil
+	default:
+		return nil, fmt.Errorf("NS.base is a %T", base)
+	}
+}
@@ -0,0 +1,168 @@
+package plist
+
+import "fmt"
+
+// DecoderLimits bounds the resources a Decoder spends on a single plist, so
+// that hostile input can't exhaust memory or the stack. Binary plists are
+// affected the most, since objects may be referenced many times and a small
+// document can describe a huge tree.
+//
+// A zero field means the limit of DefaultDecoderLimits.
+//
+// The limits apply to plists of every format, including XML plists, which
+// earlier versions decoded without any limit. The defaults are high enough
+// for the plists Apple's tools write, but decoders of trusted documents with
+// more than four million values, more than a gigabyte of strings and data, or
+// more than 512 levels of nesting need to raise the limits with SetLimits.
+type DecoderLimits struct {
+	// MaxObjects is the number of values in the decoded plist. Values which
+	// a binary plist references more than once count every time.
+	MaxObjects int
+	// MaxDepth is how deeply values may be nested. The root value is at
+	// depth 1.
+	MaxDepth int
+	// MaxCollection is the number of elements of an array or set, or of
+	// entries of a dictionary.
+	MaxCollection int
+	// MaxString is the length in bytes of a string, dictionary key or data
+	// value.
+	MaxString int
+	// MaxTotal is the total length in bytes of all strings, dictionary keys
+	// and data values.
+	MaxTotal int64
+}
+
+// DefaultDecoderLimits returns the limits of decoders which don't set their
+// own. A string or data value may be as long as the input holding it, and
+// all of them together may hold a gigabyte, which bounds what binary plists
+// referencing the same objects many times expand to.
+func DefaultDecoderLimits() DecoderLimits {
+	return DecoderLimits{
+		MaxObjects:    4 << 20,
+		MaxDepth:      512,
+		MaxCollection: 4 << 20,
+		MaxString:     maxInt,
+		MaxTotal:      1 << 30,
+	}
+}
+
+// A LimitError is returned when a plist exceeds one of the DecoderLimits.
+type LimitError struct {
+	Limit string // name of the DecoderLimits field, such as "MaxDepth"
+	Max   int64  // value of the limit
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("plist: exceeds the %s limit of %d", e.Limit, e.Max)
+}
+
+// maxInt is the largest int.
+const maxInt = int(^uint(0) >> 1)
+
+// limiter keeps track of the resources a parser has used of its limits.
+type limiter struct {
+	DecoderLimits
+	objects int
+	depth   int
+	deepest int // greatest depth reached
+	total   int64
+}
+
+// newLimiter returns a limiter for limits, with defaults for its zero fields.
+func newLimiter(limits DecoderLimits) *limiter {
+	defaults := DefaultDecoderLimits()
+	if limits.MaxObjects == 0 {
+		limits.MaxObjects = defaults.MaxObjects
+	}
+	if limits.MaxDepth == 0 {
+		limits.MaxDepth = defaults.MaxDepth
+	}
+	if limits.MaxCollection == 0 {
+		limits.MaxCollection = defaults.MaxCollection
+	}
+	if limits.MaxString == 0 {
+		limits.MaxString = defaults.MaxString
+	}
+	if limits.MaxTotal == 0 {
+		limits.MaxTotal = defaults.MaxTotal
+	}
+	return &limiter{DecoderLimits: limits}
+}
+
+// enter counts a value whose parsing starts, one level deeper than the
+// value being parsed. Every successful call is paired with a call to leave.
+func (l *limiter) enter() error {
+	if l.objects >= l.MaxObjects {
+		return &LimitError{"MaxObjects", int64(l.MaxObjects)}
+	}
+	if l.depth >= l.MaxDepth {
+		return &LimitError{"MaxDepth", int64(l.MaxDepth)}
+	}
+	l.objects++
+	l.depth++
+	if l.depth > l.deepest {
+		l.deepest = l.depth
+	}
+	return nil
+}
+
+// leave ends the value started by the last call to enter.
+func (l *limiter) leave() {
+	l.depth--
+}
+
+// reuse counts a value which was parsed before, and which holds the given
+// number of values and bytes, nested height levels deep.
+func (l *limiter) reuse(values int, bytes int64, height int) error {
+	if values > l.MaxObjects-l.objects {
+		return &LimitError{"MaxObjects", int64(l.MaxObjects)}
+	}
+	if height > l.MaxDepth-l.depth {
+		return &LimitError{"MaxDepth", int64(l.MaxDepth)}
+	}
+	if bytes > l.MaxTotal-l.total {
+		return &LimitError{"MaxTotal", l.MaxTotal}
+	}
+	l.objects += values
+	l.total += bytes
+	if l.depth+height > l.deepest {
+		l.deepest = l.depth + height
+	}
+	return nil
+}
+
+// reset forgets the resources used so far, for parsers of a stream of
+// values which are limited one at a time.
+func (l *limiter) reset() {
+	l.objects, l.depth, l.deepest, l.total = 0, 0, 0, 0
+}
+
+// collection checks the number of elements of an array, set or dictionary.
+func (l *limiter) collection(n uint64) error {
+	if n > uint64(l.MaxCollection) {
+		return &LimitError{"MaxCollection", int64(l.MaxCollection)}
+	}
+	return nil
+}
+
+// remaining returns the most bytes a string, key or data value may hold
+// without exceeding the limits.
+func (l *limiter) remaining() int {
+	n := int64(l.MaxString)
+	if rest := l.MaxTotal - l.total; rest < n {
+		n = rest
+	}
+	return int(n)
+}
+
+// bytes counts a string, key or data value of n bytes.
+func (l *limiter) bytes(n uint64) error {
+	if n > uint64(l.MaxString) {
+		return &LimitError{"MaxString", int64(l.MaxString)}
+	}
+	if n > uint64(l.MaxTotal-l.total) {
+		return &LimitError{"MaxTotal", l.MaxTotal}
+	}
+	l.total += int64(n)
+	return nil
+}
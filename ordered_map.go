@@ -0,0 +1,123 @@
+package plist
+
+import "reflect"
+
+// An OrderedMap is a dictionary which keeps its keys in the order they were
+// decoded or set in. Decoding a dictionary into an OrderedMap keeps the order
+// of its keys in the document, and its values decode like empty interface
+// values, except that dictionaries decode into *OrderedMap values rather
+// than maps. An Encoder writes the keys of an OrderedMap in order if its key
+// order is InsertionOrder, so that a document decoded into an OrderedMap
+// encodes with its keys where they were.
+//
+// The zero OrderedMap is empty and ready to use.
+type OrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+var (
+	orderedMapType    = reflect.TypeOf(OrderedMap{})
+	orderedMapPtrType = reflect.TypeOf(&OrderedMap{})
+)
+
+// Len returns the number of keys of m.
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns the keys of m in order.
+func (m *OrderedMap) Keys() []string {
+	return append([]string(nil), m.keys...)
+}
+
+// Get returns the value for key, and whether m has the key.
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// Set sets the value for key. A new key is added after the existing ones,
+// and a key which m already has keeps its place.
+func (m *OrderedMap) Set(key string, value interface{}) {
+	if m.values == nil {
+		m.values = make(map[string]interface{})
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Delete removes key from m.
+func (m *OrderedMap) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// marshalOrderedMap encodes the OrderedMap v as a dictionary in its order.
+func (e *Encoder) marshalOrderedMap(v reflect.Value) (*plistValue, error) {
+	m := v.Interface().(OrderedMap)
+	dict := &dictionary{
+		m:     make(map[string]*plistValue, len(m.keys)),
+		order: make([]string, 0, len(m.keys)),
+	}
+	for _, k := range m.keys {
+		value, err := e.marshal(reflect.ValueOf(m.values[k]))
+		if err != nil {
+			return nil, prependPath(err, k)
+		}
+		dict.set(k, value)
+	}
+	return &plistValue{Dictionary, dict}, nil
+}
+
+// unmarshalOrderedMap decodes a dictionary into the OrderedMap v, adding its
+// keys in order.
+func (d *Decoder) unmarshalOrderedMap(pval *plistValue, v reflect.Value) error {
+	d.fillOrderedMap(v.Addr().Interface().(*OrderedMap), pval.value.(*dictionary))
+	return nil
+}
+
+// fillOrderedMap sets the keys of dict in m, in order.
+func (d *Decoder) fillOrderedMap(m *OrderedMap, dict *dictionary) {
+	dict.populateArrays(InsertionOrder)
+	for i, k := range dict.keys {
+		m.Set(k, d.orderedInterface(dict.values[i]))
+	}
+}
+
+// orderedInterface returns pval as an empty interface value like
+// valueInterface, except that dictionaries are returned as *OrderedMap.
+func (d *Decoder) orderedInterface(pval *plistValue) interface{} {
+	switch pval.kind {
+	case Dictionary:
+		m := new(OrderedMap)
+		d.fillOrderedMap(m, pval.value.(*dictionary))
+		return m
+	case Array:
+		return d.orderedArray(pval.value.([]*plistValue))
+	case SetKind:
+		return Set(d.orderedArray(pval.value.([]*plistValue)))
+	case OrderedSetKind:
+		return OrderedSet(d.orderedArray(pval.value.([]*plistValue)))
+	default:
+		return d.valueInterface(pval)
+	}
+}
+
+func (d *Decoder) orderedArray(subvalues []*plistValue) []interface{} {
+	out := make([]interface{}, len(subvalues))
+	for i, subv := range subvalues {
+		out[i] = d.orderedInterface(subv)
+	}
+	return out
+}
@@ -1,11 +1,17 @@
 package plist
 
-import "sort"
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
 
-type plistKind uint
+// Kind is the kind of a plist Value.
+type Kind uint
 
 const (
-	Invalid plistKind = iota
+	Invalid Kind = iota
 	Dictionary
 	Array
 	String
@@ -14,22 +20,102 @@ const (
 	Boolean
 	Data
 	Date
+	// UIDKind is the kind of UID values, which reference objects in keyed
+	// archives. It isn't named UID so that it doesn't clash with the UID type.
+	UIDKind
+	// SetKind and OrderedSetKind are the kinds of sets, which only binary
+	// plists store as such. Other formats write them as arrays.
+	SetKind
+	OrderedSetKind
+	// NullKind is the kind of null values, which only binary and JSON plists
+	// can hold. Other formats omit null dictionary values.
+	NullKind
 )
 
-var plistKindNames = map[plistKind]string{
-	Invalid:    "invalid",
-	Dictionary: "dictionary",
-	Array:      "array",
-	String:     "string",
-	Integer:    "integer",
-	Real:       "real",
-	Boolean:    "boolean",
-	Data:       "data",
-	Date:       "date",
+var kindNames = map[Kind]string{
+	Invalid:        "invalid",
+	Dictionary:     "dictionary",
+	Array:          "array",
+	String:         "string",
+	Integer:        "integer",
+	Real:           "real",
+	Boolean:        "boolean",
+	Data:           "data",
+	Date:           "date",
+	UIDKind:        "uid",
+	SetKind:        "set",
+	OrderedSetKind: "ordered set",
+	NullKind:       "null",
+}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("plist.Kind(%d)", uint(k))
+}
+
+// UID is a reference to an object in the $objects array of an NSKeyedArchiver
+// archive. Binary plists store it with its own type; XML plists store it as
+// a dictionary with a single CF$UID key.
+type UID uint64
+
+// Set is an unordered collection of distinct values. Sets decode into empty
+// interface values as a Set, and a Set encodes as a set in binary plists.
+// Other formats write sets as arrays.
+//
+// Sets can also be decoded into slices, or into maps with struct{} or bool
+// values, whose keys are the elements of the set.
+type Set []interface{}
+
+// OrderedSet is an ordered collection of distinct values, which decodes and
+// encodes like a Set.
+type OrderedSet []interface{}
+
+// uidDictionary returns the CF$UID dictionary which stands for uid in
+// formats without UIDs.
+func uidDictionary(uid UID) *plistValue {
+	return &plistValue{Dictionary, &dictionary{m: map[string]*plistValue{
+		"CF$UID": {Integer, signedInt{uint64(uid), false}},
+	}}}
+}
+
+// dictionaryUID returns the UID which dict stands for if it is a CF$UID
+// dictionary, and whether it is one. Such dictionaries stay dictionaries
+// when parsed, and are only taken for UIDs when decoded into UIDs, unsigned
+// integers and Values.
+func dictionaryUID(dict *dictionary) (UID, bool) {
+	if ref, ok := dict.m["CF$UID"]; ok && len(dict.m) == 1 && ref.kind == Integer {
+		if i := ref.value.(signedInt); !i.signed {
+			return UID(i.value), true
+		}
+	}
+	return 0, false
+}
+
+// dictionaryUIDs replaces the CF$UID dictionaries of the tree pval with the
+// UIDs they stand for, and returns the result.
+func dictionaryUIDs(pval *plistValue) *plistValue {
+	switch pval.kind {
+	case Dictionary:
+		dict := pval.value.(*dictionary)
+		if uid, ok := dictionaryUID(dict); ok {
+			return &plistValue{UIDKind, uid}
+		}
+		for k, subv := range dict.m {
+			dict.m[k] = dictionaryUIDs(subv)
+		}
+	case Array, SetKind, OrderedSetKind:
+		values := pval.value.([]*plistValue)
+		for i, subv := range values {
+			values[i] = dictionaryUIDs(subv)
+		}
+	}
+	return pval
 }
 
 type plistValue struct {
-	kind  plistKind
+	kind  Kind
 	value interface{}
 }
 
@@ -44,12 +130,64 @@ type sizedFloat struct {
 }
 
 type dictionary struct {
-	count  int
-	m      map[string]*plistValue
+	count int
+	m     map[string]*plistValue
+
+	// order holds the keys in the order they were decoded or set, if it is
+	// known, and declared is set when that is the declaration order of the
+	// fields of a struct.
+	order    []string
+	declared bool
+
 	keys   sort.StringSlice
 	values []*plistValue
 }
 
+// newDictionary returns an empty dictionary which keeps the order of its
+// keys.
+func newDictionary() *dictionary {
+	return &dictionary{m: make(map[string]*plistValue), order: []string{}}
+}
+
+// set sets the value of key. Keys which are new are added to the end of the
+// order.
+func (d *dictionary) set(key string, pval *plistValue) {
+	if _, ok := d.m[key]; !ok && d.order != nil {
+		d.order = append(d.order, key)
+	}
+	d.m[key] = pval
+}
+
+// delete removes key from the dictionary and its order.
+func (d *dictionary) delete(key string) {
+	if _, ok := d.m[key]; !ok {
+		return
+	}
+	delete(d.m, key)
+	for i, k := range d.order {
+		if k == key {
+			d.order = append(d.order[:i], d.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// ordered reports whether the keys of the dictionary are written in their
+// order rather than sorted, with the given key order.
+func (d *dictionary) ordered(order KeyOrder) bool {
+	if d.order == nil || len(d.order) != len(d.m) {
+		return false
+	}
+	switch order {
+	case InsertionOrder:
+		return true
+	case DeclarationOrder:
+		return d.declared
+	default:
+		return false
+	}
+}
+
 func (d *dictionary) Len() int {
 	return len(d.m)
 }
@@ -63,9 +201,18 @@ func (d *dictionary) Swap(i, j int) {
 	d.values[i], d.values[j] = d.values[j], d.values[i]
 }
 
-func (d *dictionary) populateArrays() {
+// populateArrays fills keys and values with the entries of the dictionary,
+// in the given key order.
+func (d *dictionary) populateArrays(order KeyOrder) {
 	d.keys = make([]string, len(d.m))
 	d.values = make([]*plistValue, len(d.m))
+	if d.ordered(order) {
+		for i, k := range d.order {
+			d.keys[i] = k
+			d.values[i] = d.m[k]
+		}
+		return
+	}
 	i := 0
 	for k, v := range d.m {
 		d.keys[i] = k
@@ -74,3 +221,40 @@ func (d *dictionary) populateArrays() {
 	}
 	sort.Sort(d)
 }
+
+// indexPath returns the path element of the i'th element of an array.
+func indexPath(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+// formatPath joins the keys and indices of a path, as in "Items[2].Name".
+func formatPath(path []string) string {
+	var sb strings.Builder
+	for _, elem := range path {
+		if sb.Len() > 0 && !strings.HasPrefix(elem, "[") {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(elem)
+	}
+	return sb.String()
+}
+
+// prependPath adds elem to the front of the path of an encoding error, as its
+// enclosing dictionary or array returns it. Other errors are returned as is.
+func prependPath(err error, elem string) error {
+	var path *string
+	switch err := err.(type) {
+	case *UnsupportedTypeError:
+		path = &err.Path
+	case *UnsupportedValueError:
+		path = &err.Path
+	default:
+		return err
+	}
+	if *path == "" {
+		*path = elem
+	} else {
+		*path = formatPath([]string{elem, *path})
+	}
+	return err
+}
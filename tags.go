@@ -64,6 +64,18 @@ func (f field) value(v reflect.Value) reflect.Value {
 	return v
 }
 
+// typeByIndex returns the type of the field of t with the given index
+// sequence, following embedded pointers like field.value.
+func typeByIndex(t reflect.Type, index []int) reflect.Type {
+	for _, i := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		t = t.Field(i).Type
+	}
+	return t
+}
+
 type byName []field
 
 func (x byName) Len() int { return len(x) }
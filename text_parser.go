@@ -0,0 +1,485 @@
+package plist
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// textParser parses an OpenStep (old-style ASCII) plist into the
+// corresponding plistValues. All scalars in an OpenStep plist are strings,
+// except for data which is written as hex bytes between angle brackets.
+//
+// See "Old-Style ASCII Property Lists" in Apple's Property List Programming
+// Guide, and CFOldStylePList.c in CoreFoundation.
+//
+// The parser also understands the typed literals GNUstep adds to the format:
+// <*I42>, <*R3.14>, <*BY>, <*BN> and <*D2001-01-01 00:00:00 +0000>.
+type textParser struct {
+	data    []byte
+	pos     int
+	line    int
+	gnustep bool // true once a GNUstep typed literal was parsed
+	strict  bool // true if duplicate dictionary keys are rejected
+	limits  *limiter
+}
+
+// newTextParser returns a new textParser for the given document.
+// UTF-16 documents with a byte order mark are converted to UTF-8 first.
+func newTextParser(data []byte) *textParser {
+	return &textParser{data: decodeUTF16(data), line: 1, limits: newLimiter(DecoderLimits{})}
+}
+
+func decodeUTF16(data []byte) []byte {
+	if len(data) < 2 || len(data)%2 != 0 {
+		return data
+	}
+	var order func(b []byte) uint16
+	switch {
+	case data[0] == 0xfe && data[1] == 0xff:
+		order = func(b []byte) uint16 { return uint16(b[0])<<8 | uint16(b[1]) }
+	case data[0] == 0xff && data[1] == 0xfe:
+		order = func(b []byte) uint16 { return uint16(b[1])<<8 | uint16(b[0]) }
+	default:
+		return data
+	}
+	uni := make([]uint16, 0, len(data)/2-1)
+	for i := 2; i < len(data); i += 2 {
+		uni = append(uni, order(data[i:]))
+	}
+	return []byte(string(utf16.Decode(uni)))
+}
+
+func (p *textParser) parseDocument() (*plistValue, error) {
+	p.skipUTF8BOM()
+	if err := p.skipWhitespace(); err != nil {
+		return nil, err
+	}
+	if p.pos == len(p.data) {
+		// An empty strings file is an empty dictionary.
+		return &plistValue{Dictionary, newDictionary()}, nil
+	}
+
+	// A strings file is a dictionary without the surrounding braces.
+	if c := p.data[p.pos]; c != '{' && c != '(' && c != '<' {
+		start, line := p.pos, p.line
+		if _, err := p.parseString(); err != nil {
+			return nil, err
+		}
+		if err := p.skipWhitespace(); err != nil {
+			return nil, err
+		}
+		isStringsFile := p.pos < len(p.data) && p.data[p.pos] == '='
+		p.pos, p.line = start, line
+		if isStringsFile {
+			return p.parseDictContents(0)
+		}
+	}
+
+	pval, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.skipWhitespace(); err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.data) {
+		return nil, p.errorf("unexpected %q after the root object", p.data[p.pos])
+	}
+	return pval, nil
+}
+
+func (p *textParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("plist: line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *textParser) skipUTF8BOM() {
+	if bytes.HasPrefix(p.data, []byte("\xef\xbb\xbf")) {
+		p.pos += 3
+	}
+}
+
+// skipWhitespace skips over white space and comments.
+func (p *textParser) skipWhitespace() error {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			p.pos++
+		case c == '/' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '/':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == '/' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '*':
+			end := bytes.Index(p.data[p.pos+2:], []byte("*/"))
+			if end < 0 {
+				return p.errorf("unterminated comment")
+			}
+			comment := p.data[p.pos : p.pos+2+end+2]
+			p.line += bytes.Count(comment, []byte("\n"))
+			p.pos += len(comment)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// next skips white space and returns the next byte without consuming it.
+func (p *textParser) next() (byte, error) {
+	if err := p.skipWhitespace(); err != nil {
+		return 0, err
+	}
+	if p.pos >= len(p.data) {
+		return 0, p.errorf("unexpected end of input")
+	}
+	return p.data[p.pos], nil
+}
+
+// expect consumes the next byte if it is c.
+func (p *textParser) expect(c byte, context string) error {
+	n, err := p.next()
+	if err != nil {
+		return err
+	}
+	if n != c {
+		return p.errorf("expected %q %s, found %q", c, context, n)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *textParser) parseValue() (*plistValue, error) {
+	c, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.limits.enter(); err != nil {
+		return nil, err
+	}
+	defer p.limits.leave()
+	switch c {
+	case '{':
+		p.pos++
+		return p.parseDictContents('}')
+	case '(':
+		p.pos++
+		return p.parseArray()
+	case '<':
+		p.pos++
+		if p.pos < len(p.data) && p.data[p.pos] == '*' {
+			p.pos++
+			return p.parseTypedLiteral()
+		}
+		return p.parseData()
+	default:
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.limits.bytes(uint64(len(s))); err != nil {
+			return nil, err
+		}
+		return &plistValue{String, s}, nil
+	}
+}
+
+// parseDictContents parses key = value; pairs up to and including the
+// closing byte. A closing byte of 0 parses up to the end of a strings file.
+func (p *textParser) parseDictContents(closing byte) (*plistValue, error) {
+	dict := newDictionary()
+	for {
+		if err := p.skipWhitespace(); err != nil {
+			return nil, err
+		}
+		if closing == 0 && p.pos == len(p.data) {
+			break
+		}
+		c, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if c == closing {
+			p.pos++
+			break
+		}
+		key, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.limits.collection(uint64(len(dict.m)) + 1); err != nil {
+			return nil, err
+		}
+		if err := p.limits.bytes(uint64(len(key))); err != nil {
+			return nil, err
+		}
+		if c, err := p.next(); closing == 0 && err == nil && c == ';' {
+			// A key without a value is its own value in strings files.
+			p.pos++
+			dict.set(key, &plistValue{String, key})
+			continue
+		}
+		if _, ok := dict.m[key]; ok && p.strict {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		if err := p.expect('=', "after dictionary key"); err != nil {
+			return nil, err
+		}
+		sval, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		dict.set(key, sval)
+		if err := p.expect(';', "after dictionary value"); err != nil {
+			return nil, err
+		}
+	}
+	return &plistValue{Dictionary, dict}, nil
+}
+
+func (p *textParser) parseArray() (*plistValue, error) {
+	var subvalues []*plistValue
+	for {
+		c, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if c == ')' {
+			p.pos++
+			break
+		}
+		if err := p.limits.collection(uint64(len(subvalues)) + 1); err != nil {
+			return nil, err
+		}
+		subv, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		subvalues = append(subvalues, subv)
+		// Values are separated by commas, and a trailing comma is allowed.
+		if c, err = p.next(); err != nil {
+			return nil, err
+		}
+		if c == ',' {
+			p.pos++
+		} else if c != ')' {
+			return nil, p.errorf("expected ',' or ')' in array, found %q", c)
+		}
+	}
+	return &plistValue{Array, subvalues}, nil
+}
+
+func (p *textParser) parseData() (*plistValue, error) {
+	var digits []byte
+	for {
+		c, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		p.pos++
+		if c == '>' {
+			break
+		}
+		if !isHexDigit(c) {
+			return nil, p.errorf("invalid character %q in data", c)
+		}
+		digits = append(digits, c)
+	}
+	if len(digits)%2 != 0 {
+		return nil, p.errorf("odd number of hex digits in data")
+	}
+	if err := p.limits.bytes(uint64(len(digits) / 2)); err != nil {
+		return nil, err
+	}
+	data := make([]byte, len(digits)/2)
+	if _, err := hex.Decode(data, digits); err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	if len(data) == 0 {
+		data = nil
+	}
+	return &plistValue{Data, data}, nil
+}
+
+// parseTypedLiteral parses a GNUstep typed literal following "<*".
+func (p *textParser) parseTypedLiteral() (*plistValue, error) {
+	end := bytes.IndexByte(p.data[p.pos:], '>')
+	if end < 1 {
+		return nil, p.errorf("unterminated typed literal")
+	}
+	typ, s := p.data[p.pos], string(p.data[p.pos+1:p.pos+end])
+	p.pos += end + 1
+	p.gnustep = true
+	switch typ {
+	case 'I':
+		s = strings.TrimSpace(s)
+		if strings.HasPrefix(s, "-") {
+			i, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			return &plistValue{Integer, signedInt{uint64(i), true}}, nil
+		}
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		return &plistValue{Integer, signedInt{u, false}}, nil
+	case 'R':
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		return &plistValue{Real, sizedFloat{f, 64}}, nil
+	case 'B':
+		switch s {
+		case "Y":
+			return &plistValue{Boolean, true}, nil
+		case "N":
+			return &plistValue{Boolean, false}, nil
+		}
+		return nil, p.errorf("invalid boolean %q", s)
+	case 'D':
+		t, err := time.Parse(textDateFormat, s)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		return &plistValue{Date, t}, nil
+	default:
+		return nil, p.errorf("unknown typed literal <*%c", typ)
+	}
+}
+
+func (p *textParser) parseString() (string, error) {
+	c, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	if c == '"' || c == '\'' {
+		p.pos++
+		return p.parseQuotedString(c)
+	}
+	start := p.pos
+	for p.pos < len(p.data) && isUnquotedStringByte(p.data[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("unexpected %q", c)
+	}
+	return string(p.data[start:p.pos]), nil
+}
+
+func (p *textParser) parseQuotedString(quote byte) (string, error) {
+	var sb strings.Builder
+	for {
+		if p.pos >= len(p.data) {
+			return "", p.errorf("unterminated quoted string")
+		}
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case quote:
+			return sb.String(), nil
+		case '\n':
+			p.line++
+			sb.WriteByte(c)
+		case '\\':
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+// parseEscape parses the escape sequence following a backslash.
+func (p *textParser) parseEscape(sb *strings.Builder) error {
+	if p.pos >= len(p.data) {
+		return p.errorf("unterminated quoted string")
+	}
+	c := p.data[p.pos]
+	p.pos++
+	switch c {
+	case 'a':
+		sb.WriteByte('\a')
+	case 'b':
+		sb.WriteByte('\b')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'r':
+		sb.WriteByte('\r')
+	case 't':
+		sb.WriteByte('\t')
+	case 'v':
+		sb.WriteByte('\v')
+	case 'U':
+		// \U is followed by up to four hex digits of a UTF-16 code unit.
+		u := p.parseEscapeDigits(4, 16)
+		if utf16.IsSurrogate(rune(u)) && bytes.HasPrefix(p.data[p.pos:], []byte(`\U`)) {
+			start := p.pos
+			p.pos += 2
+			if r := utf16.DecodeRune(rune(u), rune(p.parseEscapeDigits(4, 16))); r != utf8.RuneError {
+				sb.WriteRune(r)
+				return nil
+			}
+			p.pos = start
+		}
+		sb.WriteRune(rune(u))
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		// Octal escapes are NeXTSTEP-encoded bytes, which we treat as
+		// Latin-1 code points.
+		p.pos--
+		sb.WriteRune(rune(p.parseEscapeDigits(3, 8)))
+	case '\n':
+		p.line++
+		sb.WriteByte(c)
+	default:
+		sb.WriteByte(c)
+	}
+	return nil
+}
+
+// parseEscapeDigits parses up to max digits in the given base.
+func (p *textParser) parseEscapeDigits(max, base int) int {
+	n := 0
+	for i := 0; i < max && p.pos < len(p.data); i++ {
+		c := p.data[p.pos]
+		var d int
+		switch {
+		case c >= '0' && c <= '9':
+			d = int(c - '0')
+		case c >= 'a' && c <= 'f':
+			d = int(c-'a') + 10
+		case c >= 'A' && c <= 'F':
+			d = int(c-'A') + 10
+		default:
+			return n
+		}
+		if d >= base {
+			return n
+		}
+		n = n*base + d
+		p.pos++
+	}
+	return n
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// isUnquotedStringByte reports whether c may appear in an unquoted string.
+func isUnquotedStringByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '$' || c == '/' || c == ':' || c == '.' || c == '-'
+}
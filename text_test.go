@@ -0,0 +1,316 @@
+package plist
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+const openStepRef = `// Written by hand.
+{
+	CFBundleName = "Hello World";
+	CFBundleVersion = 1.0;
+	Count = 42;
+	Enabled = YES;
+	/* data is hex encoded */
+	Icon = <0fbd77 1c2735ae>;
+	Escaped = "tab\there \"quoted\" \U263c \101";
+	Items = (
+		one,
+		"two words",
+		(nested, array),
+		{ inner = value; },
+	);
+}
+`
+
+func TestDecodeOpenStep(t *testing.T) {
+	var out interface{}
+	d := NewDecoder(strings.NewReader(openStepRef))
+	if err := d.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := d.Format(), OpenStepFormat; have != want {
+		t.Errorf("have format %v, want %v", have, want)
+	}
+	want := map[string]interface{}{
+		"CFBundleName":    "Hello World",
+		"CFBundleVersion": "1.0",
+		"Count":           "42",
+		"Enabled":         "YES",
+		"Icon":            []byte{0x0f, 0xbd, 0x77, 0x1c, 0x27, 0x35, 0xae},
+		"Escaped":         "tab\there \"quoted\" ☼ A",
+		"Items": []interface{}{
+			"one",
+			"two words",
+			[]interface{}{"nested", "array"},
+			map[string]interface{}{"inner": "value"},
+		},
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("have %#v\nwant %#v", out, want)
+	}
+}
+
+func TestDecodeOpenStepTypes(t *testing.T) {
+	var out struct {
+		Version float64 `plist:"CFBundleVersion"`
+		Count   uint8
+		Enabled bool
+		Icon    []byte
+	}
+	if err := Unmarshal([]byte(openStepRef), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Version != 1.0 || out.Count != 42 || !out.Enabled || len(out.Icon) != 7 {
+		t.Errorf("unexpected result %+v", out)
+	}
+
+	var bad struct{ CFBundleName int }
+	if err := Unmarshal([]byte(openStepRef), &bad); err == nil {
+		t.Error("expected error decoding a non-numeric string into an int")
+	}
+}
+
+func TestDecodeStringsFile(t *testing.T) {
+	const input = "\ufeff/* Localizable.strings */\n\"greeting\" = \"Hello\";\nfarewell = \"Goodbye\";\n\"same\";\n"
+	var out map[string]string
+	if err := Unmarshal([]byte(input), &out); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"greeting": "Hello", "farewell": "Goodbye", "same": "same"}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("have %v, want %v", out, want)
+	}
+
+	// strings files are often UTF-16 encoded.
+	var utf16BE []byte
+	for _, u := range utf16.Encode([]rune(input)) {
+		utf16BE = append(utf16BE, byte(u>>8), byte(u))
+	}
+	out = nil
+	if err := Unmarshal(utf16BE, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("UTF-16: have %v, want %v", out, want)
+	}
+}
+
+func TestDecodeOpenStepErrors(t *testing.T) {
+	for _, input := range []string{
+		"{ a = b }",
+		"{ a b; }",
+		"( a b )",
+		"<0fb>",
+		"<0fbx>",
+		`"unterminated`,
+		"/* unterminated",
+		"{ a = b; } trailing",
+		"(",
+	} {
+		var out interface{}
+		if err := NewOpenStepDecoder(strings.NewReader(input)).Decode(&out); err == nil {
+			t.Errorf("expected error decoding %q, got %#v", input, out)
+		}
+	}
+}
+
+func TestEncodeOpenStep(t *testing.T) {
+	in := struct {
+		Name    string
+		Count   int
+		Ratio   float32
+		Enabled bool
+		Data    []byte
+		Date    time.Time
+		Items   []string
+		Empty   map[string]string
+	}{
+		Name:    "Hello World",
+		Count:   -42,
+		Ratio:   0.5,
+		Enabled: true,
+		Data:    []byte{0x0f, 0xbd, 0x77, 0x1c, 0x27, 0x35, 0xae},
+		Date:    time.Date(2011, 5, 12, 1, 0, 0, 0, time.UTC),
+		Items:   []string{"one", "two \"words\"\n", ""},
+		Empty:   map[string]string{},
+	}
+	const want = `{
+  Count = -42;
+  Data = <0fbd771c 2735ae>;
+  Date = "2011-05-12 01:00:00 +0000";
+  Empty = {};
+  Enabled = YES;
+  Items = (
+    one,
+    "two \"words\"\n",
+    ""
+  );
+  Name = "Hello World";
+  Ratio = 0.5;
+}
+`
+	var buf bytes.Buffer
+	enc := NewOpenStepEncoder(&buf)
+	enc.Indent("  ")
+	if err := enc.Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	if have := buf.String(); have != want {
+		t.Errorf("have\n%s\nwant\n%s", have, want)
+	}
+
+	buf.Reset()
+	if err := NewOpenStepEncoder(&buf).Encode(in.Items); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := buf.String(), "(one, \"two \\\"words\\\"\\n\", \"\")\n"; have != want {
+		t.Errorf("have %q, want %q", have, want)
+	}
+}
+
+func TestOpenStepCommentLikeStrings(t *testing.T) {
+	in := map[string]string{"k": "//x", "//": "/*", "a/b": "*/", "url": "https://example.com/a"}
+	var buf bytes.Buffer
+	if err := NewOpenStepEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]string
+	if err := Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("decoding %s: %v", buf.Bytes(), err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("have %q, want %q", out, in)
+	}
+	if !strings.Contains(buf.String(), "a/b = \"*/\"") {
+		t.Errorf("encoded %s, want a/b unquoted", buf.Bytes())
+	}
+}
+
+func TestOpenStepRoundTrip(t *testing.T) {
+	type sample struct {
+		Name    string
+		Count   int
+		Big     uint64
+		Ratio   float64
+		Enabled bool
+		Data    []byte
+		Date    time.Time
+		Nested  map[string][]string
+		Control string
+	}
+	in := sample{
+		Name:    "UTF-8 ☼ / こんにちは",
+		Count:   -42,
+		Big:     ^uint64(0),
+		Ratio:   -1234.5678,
+		Enabled: true,
+		Data:    []byte("data"),
+		Date:    time.Date(2011, 5, 12, 1, 0, 0, 0, time.UTC),
+		Nested:  map[string][]string{"a b": {"c", "d;e"}},
+		Control: "\x00\x01\x1f\x7f\\",
+	}
+	var buf bytes.Buffer
+	if err := NewOpenStepEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	var out sample
+	if err := Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Date.Equal(in.Date) {
+		t.Errorf("have date %v, want %v", out.Date, in.Date)
+	}
+	out.Date = in.Date
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("have %#v\nwant %#v", out, in)
+	}
+}
+
+const gnustepRef = `{
+  Count = <*I-42>;
+  Date = <*D2011-05-12 01:00:00 +0000>;
+  Enabled = <*BY>;
+  Name = "Hello World";
+  Ratio = <*R0.5>;
+  Size = <*I4398046511104>;
+}
+`
+
+type gnustepSample struct {
+	Name    string
+	Count   int
+	Size    uint64
+	Ratio   float64
+	Enabled bool
+	Date    time.Time
+}
+
+func TestDecodeGNUStep(t *testing.T) {
+	var out map[string]interface{}
+	d := NewDecoder(strings.NewReader(gnustepRef))
+	if err := d.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := d.Format(), GNUStepFormat; have != want {
+		t.Errorf("have format %v, want %v", have, want)
+	}
+	want := map[string]interface{}{
+		"Count":   int64(-42),
+		"Date":    time.Date(2011, 5, 12, 1, 0, 0, 0, time.FixedZone("", 0)),
+		"Enabled": true,
+		"Name":    "Hello World",
+		"Ratio":   0.5,
+		"Size":    uint64(4398046511104),
+	}
+	if !out["Date"].(time.Time).Equal(want["Date"].(time.Time)) {
+		t.Errorf("have date %v, want %v", out["Date"], want["Date"])
+	}
+	out["Date"] = want["Date"]
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("have %#v\nwant %#v", out, want)
+	}
+
+	for _, input := range []string{"<*I>", "<*Ix>", "<*BT>", "<*D2011>", "<*X1>", "<*I1"} {
+		var out interface{}
+		if err := NewGNUStepDecoder(strings.NewReader(input)).Decode(&out); err == nil {
+			t.Errorf("expected error decoding %q, got %#v", input, out)
+		}
+	}
+}
+
+func TestEncodeGNUStep(t *testing.T) {
+	in := gnustepSample{
+		Name:    "Hello World",
+		Count:   -42,
+		Size:    4398046511104,
+		Ratio:   0.5,
+		Enabled: true,
+		Date:    time.Date(2011, 5, 12, 1, 0, 0, 0, time.UTC),
+	}
+	var buf bytes.Buffer
+	enc := NewGNUStepEncoder(&buf)
+	enc.Indent("  ")
+	if err := enc.Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	if have := buf.String(); have != gnustepRef {
+		t.Errorf("have\n%s\nwant\n%s", have, gnustepRef)
+	}
+
+	var out gnustepSample
+	if err := NewGNUStepDecoder(&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Date.Equal(in.Date) {
+		t.Errorf("have date %v, want %v", out.Date, in.Date)
+	}
+	out.Date = in.Date
+	if out != in {
+		t.Errorf("have %+v, want %+v", out, in)
+	}
+}
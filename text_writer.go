@@ -0,0 +1,251 @@
+package plist
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// textDateFormat is the format NSDate uses to describe itself, which is how
+// dates appear in OpenStep plists.
+const textDateFormat = "2006-01-02 15:04:05 -0700"
+
+// textEncoder writes a plistValue tree as an OpenStep or GNUstep plist.
+// OpenStep plists can only hold strings, data, arrays and dictionaries, so
+// all other scalars are written as their string representation. GNUstep
+// plists write them as typed literals instead.
+type textEncoder struct {
+	writer   *bufio.Writer
+	indent   string
+	keyOrder KeyOrder
+	depth    int
+	gnustep  bool
+}
+
+func newTextEncoder(w io.Writer, gnustep bool) *textEncoder {
+	return &textEncoder{writer: bufio.NewWriter(w), gnustep: gnustep}
+}
+
+func (e *textEncoder) Indent(indent string) {
+	e.indent = indent
+}
+
+func (e *textEncoder) generateDocument(pval *plistValue) error {
+	if err := e.writePlistValue(pval); err != nil {
+		return err
+	}
+	// newline at the end of a plist document
+	e.writer.WriteByte('\n')
+	return e.writer.Flush()
+}
+
+func (e *textEncoder) writePlistValue(pval *plistValue) error {
+	switch pval.kind {
+	case String:
+		e.writeString(pval.value.(string))
+	case Integer:
+		e.writeScalar('I', formatInteger(pval.value.(signedInt)))
+	case Real:
+		e.writeScalar('R', formatReal(pval.value.(sizedFloat)))
+	case Boolean:
+		switch b := pval.value.(bool); {
+		case e.gnustep && b:
+			e.writeScalar('B', "Y")
+		case e.gnustep:
+			e.writeScalar('B', "N")
+		case b:
+			e.writeString("YES")
+		default:
+			e.writeString("NO")
+		}
+	case Date:
+		e.writeScalar('D', pval.value.(time.Time).In(time.UTC).Format(textDateFormat))
+	case Data:
+		e.writeData(pval.value.([]byte))
+	case Array, SetKind, OrderedSetKind:
+		// Text plists have no sets.
+		return e.writeArray(pval.value.([]*plistValue))
+	case Dictionary:
+		return e.writeDictionary(pval.value.(*dictionary))
+	case UIDKind:
+		return e.writeDictionary(uidDictionary(pval.value.(UID)).value.(*dictionary))
+	case NullKind:
+		return &UnsupportedValueError{Str: "null"}
+	default:
+		return kindError(pval)
+	}
+	return nil
+}
+
+// writeNewline starts a new line at the current depth when indenting,
+// or writes sep otherwise.
+func (e *textEncoder) writeNewline(sep string) {
+	if e.indent == "" {
+		e.writer.WriteString(sep)
+		return
+	}
+	e.writer.WriteByte('\n')
+	for i := 0; i < e.depth; i++ {
+		e.writer.WriteString(e.indent)
+	}
+}
+
+func (e *textEncoder) writeArray(values []*plistValue) error {
+	e.writer.WriteByte('(')
+	e.depth++
+	for i, v := range values {
+		if i > 0 {
+			e.writer.WriteByte(',')
+			e.writeNewline(" ")
+		} else {
+			e.writeNewline("")
+		}
+		if err := e.writePlistValue(v); err != nil {
+			return prependPath(err, indexPath(i))
+		}
+	}
+	e.depth--
+	if len(values) > 0 {
+		e.writeNewline("")
+	}
+	e.writer.WriteByte(')')
+	return nil
+}
+
+func (e *textEncoder) writeDictionary(dict *dictionary) error {
+	dict.populateArrays(e.keyOrder)
+	e.writer.WriteByte('{')
+	e.depth++
+	n := 0
+	for i, k := range dict.keys {
+		// Text plists have no null, so leave out null values.
+		if dict.values[i].kind == NullKind {
+			continue
+		}
+		if n > 0 {
+			e.writeNewline(" ")
+		} else {
+			e.writeNewline("")
+		}
+		n++
+		e.writeString(k)
+		e.writer.WriteString(" = ")
+		if err := e.writePlistValue(dict.values[i]); err != nil {
+			return prependPath(err, k)
+		}
+		e.writer.WriteByte(';')
+	}
+	e.depth--
+	if n > 0 {
+		e.writeNewline("")
+	}
+	e.writer.WriteByte('}')
+	return nil
+}
+
+func (e *textEncoder) writeData(data []byte) {
+	// Like CoreFoundation, group the hex digits in blocks of four bytes.
+	e.writer.WriteByte('<')
+	for i := 0; i < len(data); i += 4 {
+		if i > 0 {
+			e.writer.WriteByte(' ')
+		}
+		end := i + 4
+		if end > len(data) {
+			end = len(data)
+		}
+		e.writer.WriteString(hex.EncodeToString(data[i:end]))
+	}
+	e.writer.WriteByte('>')
+}
+
+// writeScalar writes s as a GNUstep typed literal of the given type, or as a
+// string in OpenStep plists.
+func (e *textEncoder) writeScalar(typ byte, s string) {
+	if !e.gnustep {
+		e.writeString(s)
+		return
+	}
+	e.writer.WriteString("<*")
+	e.writer.WriteByte(typ)
+	e.writer.WriteString(s)
+	e.writer.WriteByte('>')
+}
+
+// writeString writes s unquoted if possible, and quoted and escaped otherwise.
+// Strings holding // or /* are quoted, since they would start a comment.
+func (e *textEncoder) writeString(s string) {
+	quote := s == "" || strings.Contains(s, "//") || strings.Contains(s, "/*")
+	for i := 0; i < len(s); i++ {
+		if !isUnquotedStringByte(s[i]) {
+			quote = true
+			break
+		}
+	}
+	if !quote {
+		e.writer.WriteString(s)
+		return
+	}
+	e.writer.WriteString(quoteTextString(s))
+}
+
+// quoteTextString returns s as a double-quoted OpenStep string.
+// Non-ASCII characters are written as UTF-8, control characters are escaped.
+func quoteTextString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case '\a':
+			sb.WriteString(`\a`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\v':
+			sb.WriteString(`\v`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\U%04x`, r)
+				continue
+			}
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func formatInteger(i signedInt) string {
+	if i.signed {
+		return strconv.FormatInt(int64(i.value), 10)
+	}
+	return strconv.FormatUint(i.value, 10)
+}
+
+func formatReal(f sizedFloat) string {
+	switch {
+	case math.IsInf(f.value, 1):
+		return "inf"
+	case math.IsInf(f.value, -1):
+		return "-inf"
+	case math.IsNaN(f.value):
+		return "nan"
+	default:
+		return strconv.FormatFloat(f.value, 'g', -1, f.bits)
+	}
+}
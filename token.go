@@ -0,0 +1,155 @@
+package plist
+
+import (
+	"errors"
+	"io"
+	"reflect"
+)
+
+// A Token is returned by TokenReader.Token. It is one of StartDict,
+// StartArray, Key, End, or a *Value holding a string, integer, real,
+// boolean, data or date.
+type Token interface{}
+
+// StartDict starts a dictionary, whose Key and value tokens follow up to the
+// matching End.
+type StartDict struct{}
+
+// StartArray starts an array, whose elements follow up to the matching End.
+type StartArray struct{}
+
+// A Key is a dictionary key. The value of the key follows it.
+type Key string
+
+// End ends the innermost dictionary or array.
+type End struct{}
+
+// A TokenReader reads an XML plist as a stream of tokens, so that plists
+// too big to hold in memory can be processed one value at a time:
+//
+//	r := plist.NewXMLTokenReader(f)
+//	if _, err := r.Token(); err != nil { // StartArray
+//		return err
+//	}
+//	for {
+//		tok, err := r.Token()
+//		if err != nil {
+//			return err
+//		}
+//		if tok == (plist.End{}) {
+//			return nil
+//		}
+//		var track Track
+//		if err := r.DecodeElement(&track); err != nil {
+//			return err
+//		}
+//	}
+//
+// UIDs are read as dictionaries, as they are written in XML.
+type TokenReader struct {
+	p     *xmlParser
+	depth int // number of open dictionaries and arrays
+
+	// start or value is the last token read, for DecodeElement.
+	start    string      // name of the element
+	startPos xmlPosition // position of its start element
+	value    *plistValue
+}
+
+// NewXMLTokenReader returns a TokenReader reading the XML plist from r.
+func NewXMLTokenReader(r io.Reader) *TokenReader {
+	return &TokenReader{p: newXMLParser(r)}
+}
+
+// Token returns the next token. At the end of the input it returns io.EOF.
+// The <plist> element around the root value is left out, and a stream of
+// several plists reads as their root values one after the other. Errors in
+// the input are returned as a *SyntaxError.
+func (r *TokenReader) Token() (Token, error) {
+	r.start, r.value = "", nil
+	for {
+		tok, err := r.p.token()
+		if err == io.EOF && r.depth == 0 {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, r.p.syntaxError(r.p.tokenStart, err)
+		}
+		switch tok.kind {
+		case xmlStartElement:
+			switch tok.name {
+			case "plist":
+				continue
+			case "dict", "array":
+				r.depth++
+				r.start, r.startPos = tok.name, r.p.tokenStart
+				if tok.name == "dict" {
+					return StartDict{}, nil
+				}
+				return StartArray{}, nil
+			case "key":
+				k, err := r.p.parseKey()
+				if err != nil {
+					return nil, r.p.syntaxError(r.p.tokenStart, err)
+				}
+				return Key(k), nil
+			}
+			r.p.limits.reset()
+			pval, err := r.p.parseXMLElement(tok.name)
+			if err != nil {
+				return nil, err
+			}
+			r.value = pval
+			return (*Value)(pval), nil
+		case xmlEndElement:
+			if tok.name == "plist" {
+				continue
+			}
+			r.depth--
+			return End{}, nil
+		}
+	}
+}
+
+// DecodeElement decodes the value started by the last token read into the
+// value pointed to by v, like Decoder.Decode. After a StartDict or
+// StartArray it reads the rest of the dictionary or array, up to and
+// including its End.
+func (r *TokenReader) DecodeElement(v interface{}) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr {
+		return errors.New("plist: non-pointer passed to DecodeElement")
+	}
+	pval := r.value
+	if r.start != "" {
+		r.p.limits.reset()
+		r.p.tokenStart = r.startPos
+		var err error
+		if pval, err = r.p.parseXMLElement(r.start); err != nil {
+			return err
+		}
+		r.depth--
+	}
+	if pval == nil {
+		return errors.New("plist: DecodeElement called without a value token")
+	}
+	r.start, r.value = "", nil
+	d := &Decoder{format: XMLFormat}
+	return d.unmarshal(pval, val.Elem())
+}
+
+// Skip reads tokens up to and including the End of the innermost open
+// dictionary or array. Called right after StartDict or StartArray, it skips
+// that dictionary or array. It does nothing outside of dictionaries and
+// arrays.
+func (r *TokenReader) Skip() error {
+	r.start, r.value = "", nil
+	if r.depth == 0 {
+		return nil
+	}
+	if err := r.p.skip(); err != nil {
+		return r.p.syntaxError(r.p.tokenStart, err)
+	}
+	r.depth--
+	return nil
+}
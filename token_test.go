@@ -0,0 +1,271 @@
+package plist
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const tokenTestPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Tracks</key>
+	<array>
+		<dict><key>Name</key><string>one</string><key>Plays</key><integer>1</integer></dict>
+		<dict><key>Name</key><string>two</string><key>Plays</key><integer>2</integer></dict>
+		<dict><key>Name</key><string>three</string><key>Plays</key><integer>3</integer></dict>
+	</array>
+	<key>Version</key>
+	<real>1.5</real>
+</dict>
+</plist>
+`
+
+func TestTokenReader(t *testing.T) {
+	r := NewXMLTokenReader(strings.NewReader(tokenTestPlist))
+	var got []Token
+	for {
+		tok, err := r.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, ok := tok.(*Value); ok {
+			tok = (&Decoder{}).valueInterface((*plistValue)(v))
+		}
+		got = append(got, tok)
+		switch len(got) {
+		case 4:
+			// skip the first track
+			if err := r.Skip(); err != nil {
+				t.Fatal(err)
+			}
+		case 6:
+			// skip the rest of the second track
+			if err := r.Skip(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	want := []Token{
+		StartDict{}, Key("Tracks"), StartArray{},
+		StartDict{},
+		StartDict{}, Key("Name"),
+		StartDict{}, Key("Name"), "three", Key("Plays"), uint64(3), End{},
+		End{},
+		Key("Version"), 1.5,
+		End{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens =\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestTokenReaderDecodeElement(t *testing.T) {
+	type track struct {
+		Name  string
+		Plays int
+	}
+	r := NewXMLTokenReader(strings.NewReader(tokenTestPlist))
+	for _, want := range []Token{StartDict{}, Key("Tracks"), StartArray{}} {
+		if tok, err := r.Token(); err != nil || tok != want {
+			t.Fatalf("token = %#v, %v, want %#v", tok, err, want)
+		}
+	}
+	var tracks []track
+	for {
+		tok, err := r.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok == (End{}) {
+			break
+		}
+		var tr track
+		if err := r.DecodeElement(&tr); err != nil {
+			t.Fatal(err)
+		}
+		tracks = append(tracks, tr)
+	}
+	want := []track{{"one", 1}, {"two", 2}, {"three", 3}}
+	if !reflect.DeepEqual(tracks, want) {
+		t.Errorf("tracks = %v, want %v", tracks, want)
+	}
+
+	if tok, err := r.Token(); err != nil || tok != Key("Version") {
+		t.Fatalf("token = %#v, %v, want Version key", tok, err)
+	}
+	if _, err := r.Token(); err != nil {
+		t.Fatal(err)
+	}
+	var version float64
+	if err := r.DecodeElement(&version); err != nil || version != 1.5 {
+		t.Errorf("version = %v, %v, want 1.5", version, err)
+	}
+	if err := r.DecodeElement(&version); err == nil {
+		t.Error("second DecodeElement succeeded")
+	}
+}
+
+func TestTokenReaderSyntaxError(t *testing.T) {
+	r := NewXMLTokenReader(strings.NewReader("<plist><array><integer>x</integer></array></plist>"))
+	var err error
+	for err == nil {
+		_, err = r.Token()
+	}
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("error = %v, want a *SyntaxError", err)
+	}
+}
+
+func TestTokenReaderConstantMemory(t *testing.T) {
+	const lines = 1 << 20
+	line := "<integer>1</integer>\n"
+	r := NewXMLTokenReader(&repeatReader{prefix: "<plist><array>\n", chunk: line, n: lines * int64(len(line))})
+	var before runtime.MemStats
+	for i := 0; ; i++ {
+		if i == 1000 {
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+		}
+		if _, err := r.Token(); err != nil {
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) || syntaxErr.Line != lines+2 {
+				t.Fatalf("have error %v, want an unexpected EOF on line %d", err, lines+2)
+			}
+			break
+		}
+	}
+	var after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if grown := int64(after.HeapAlloc) - int64(before.HeapAlloc); grown > 1<<20 {
+		t.Errorf("heap grew by %d bytes reading %d lines", grown, lines)
+	}
+	runtime.KeepAlive(r)
+}
+
+func TestTokenWriter(t *testing.T) {
+	type item struct {
+		Name  string
+		Count int
+		Tags  []string
+	}
+	items := []item{
+		{Name: "a", Count: 1, Tags: []string{"x", "y"}},
+		{Name: "b", Count: 2},
+	}
+	for _, indent := range []string{"", "\t", "  "} {
+		want, err := MarshalIndent(map[string]interface{}{
+			"Active":  true,
+			"Items":   items,
+			"Payload": []byte("hello"),
+			"Version": 3,
+		}, indent)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		w := NewXMLTokenWriter(&buf)
+		w.Indent(indent)
+		w.BeginDict()
+		w.Key("Active")
+		w.Encode(true)
+		w.Key("Items")
+		w.BeginArray()
+		for _, it := range items {
+			w.Encode(it)
+		}
+		w.End()
+		w.Key("Missing")
+		w.Encode(nil)
+		w.Key("Payload")
+		w.Value(NewData([]byte("hello")))
+		w.Key("Version")
+		w.Encode(3)
+		w.End()
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != string(want) {
+			t.Errorf("indent %q: output =\n%s\nwant\n%s", indent, got, want)
+		}
+	}
+}
+
+func TestTokenWriterErrors(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewXMLTokenWriter(&buf)
+	w.BeginDict()
+	w.Key("Items")
+	w.BeginArray()
+	w.Encode(1)
+	err := w.Encode(map[string]interface{}{"Bad": make(chan int)})
+	var typeErr *UnsupportedTypeError
+	if !errors.As(err, &typeErr) || typeErr.Path != "Items[1].Bad" {
+		t.Errorf("error = %v, want an unsupported type at Items[1].Bad", err)
+	}
+	if w.End() != err || w.Close() != err {
+		t.Error("error isn't kept by later calls")
+	}
+
+	tests := []struct {
+		name  string
+		write func(w *TokenWriter) error
+	}{
+		{"key outside of dict", func(w *TokenWriter) error {
+			w.BeginArray()
+			return w.Key("k")
+		}},
+		{"missing key", func(w *TokenWriter) error {
+			w.BeginDict()
+			return w.Encode(1)
+		}},
+		{"missing value", func(w *TokenWriter) error {
+			w.BeginDict()
+			w.Key("k")
+			return w.End()
+		}},
+		{"two roots", func(w *TokenWriter) error {
+			w.Encode(1)
+			return w.Encode(2)
+		}},
+		{"unclosed array", func(w *TokenWriter) error {
+			w.BeginArray()
+			return w.Close()
+		}},
+		{"empty document", func(w *TokenWriter) error {
+			return w.Close()
+		}},
+	}
+	for _, tt := range tests {
+		if err := tt.write(NewXMLTokenWriter(&buf)); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
+
+func TestTokenWriterFlush(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewXMLTokenWriter(&buf)
+	w.BeginArray()
+	w.Encode("first")
+	if buf.Len() != 0 {
+		t.Errorf("output before Flush = %q, want none", buf.String())
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(buf.String(), "<array><string>first</string>") {
+		t.Errorf("output after Flush = %q", buf.String())
+	}
+}
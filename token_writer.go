@@ -0,0 +1,231 @@
+package plist
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// A TokenWriter writes an XML plist token by token, through a small buffer
+// to its writer, so that plists too big to hold in memory can be written one
+// value at a time:
+//
+//	w := plist.NewXMLTokenWriter(f)
+//	w.BeginArray()
+//	for rows.Next() {
+//		var item Item
+//		// scan the row into item
+//		if err := w.Encode(item); err != nil {
+//			return err
+//		}
+//	}
+//	w.End()
+//	return w.Close()
+//
+// The output is formatted exactly like the output of Encoder, except that
+// dictionary keys are written in the order they are given instead of being
+// sorted. Once a method returns an error, all later calls return it as well.
+// Errors of the underlying writer are returned once the buffer is flushed,
+// by Flush or Close.
+type TokenWriter struct {
+	enc    *xmlEncoder
+	frames []tokenFrame // open dictionaries and arrays
+	key    *string      // key waiting for its value
+
+	started bool // whether the document was started
+	root    bool // whether the root value was written
+	err     error
+}
+
+// tokenFrame is a dictionary or array which is being written.
+type tokenFrame struct {
+	dict bool
+	n    int    // number of values written
+	key  string // key of the last value of a dictionary
+}
+
+// NewXMLTokenWriter returns a TokenWriter writing an XML plist to w.
+func NewXMLTokenWriter(w io.Writer) *TokenWriter {
+	return &TokenWriter{enc: newXMLEncoder(w)}
+}
+
+// Indent sets the indentation, like Encoder.Indent. It must be called before
+// the first token is written.
+func (w *TokenWriter) Indent(indent string) {
+	w.enc.Indent(indent)
+}
+
+// BeginDict starts a dictionary, whose entries are written with Key and
+// a value each, up to the matching End.
+func (w *TokenWriter) BeginDict() error {
+	return w.begin("dict")
+}
+
+// BeginArray starts an array, whose elements are written up to the matching
+// End.
+func (w *TokenWriter) BeginArray() error {
+	return w.begin("array")
+}
+
+// Key writes a dictionary key. The value of the key is written next.
+func (w *TokenWriter) Key(key string) error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.frames) == 0 || !w.frames[len(w.frames)-1].dict {
+		return w.fail(errors.New("plist: key outside of a dictionary"))
+	}
+	if w.key != nil {
+		return w.fail(fmt.Errorf("plist: missing value for key %q", *w.key))
+	}
+	w.key = &key
+	return nil
+}
+
+// Value writes v at the current position.
+func (w *TokenWriter) Value(v *Value) error {
+	return w.Encode(v)
+}
+
+// Encode writes the plist encoding of v at the current position, like
+// Encoder.Encode. As in dictionaries written by Encoder, a key whose value
+// is nil is left out.
+func (w *TokenWriter) Encode(v interface{}) error {
+	pval, err := (&Encoder{format: XMLFormat}).marshal(reflect.ValueOf(v))
+	if err != nil {
+		return w.fail(w.prependPath(err, true))
+	}
+	if pval.kind == NullKind && w.key != nil {
+		// XML plists have no null, so leave out null values.
+		w.key = nil
+		return nil
+	}
+	if err := w.value(); err != nil {
+		return err
+	}
+	if err := w.enc.writePlistValue(pval); err != nil {
+		return w.fail(w.prependPath(err, false))
+	}
+	return nil
+}
+
+// End ends the innermost dictionary or array.
+func (w *TokenWriter) End() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.frames) == 0 {
+		return w.fail(errors.New("plist: End outside of a dictionary or array"))
+	}
+	if w.key != nil {
+		return w.fail(fmt.Errorf("plist: missing value for key %q", *w.key))
+	}
+	frame := w.frames[len(w.frames)-1]
+	w.frames = w.frames[:len(w.frames)-1]
+	name := "array"
+	if frame.dict {
+		name = "dict"
+	}
+	w.enc.writeEnd(name)
+	return nil
+}
+
+// Flush writes any buffered output to the underlying writer.
+func (w *TokenWriter) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.fail(w.enc.writer.Flush())
+}
+
+// Close ends the document. It doesn't close the underlying writer.
+func (w *TokenWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.frames) > 0 {
+		return w.fail(fmt.Errorf("plist: Close with %d dictionaries or arrays open", len(w.frames)))
+	}
+	if !w.root {
+		return w.fail(errors.New("plist: Close before writing a value"))
+	}
+	w.enc.writeEnd("plist")
+	w.enc.writeFooter()
+	if err := w.fail(w.enc.writer.Flush()); err != nil {
+		return err
+	}
+	w.err = errors.New("plist: TokenWriter is closed")
+	return nil
+}
+
+func (w *TokenWriter) begin(name string) error {
+	if err := w.value(); err != nil {
+		return err
+	}
+	w.enc.writeStart(name)
+	w.frames = append(w.frames, tokenFrame{dict: name == "dict"})
+	return nil
+}
+
+// value starts a value at the current position, writing the document header
+// or the key of the value as needed.
+func (w *TokenWriter) value() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.frames) == 0 {
+		if w.root {
+			return w.fail(errors.New("plist: more than one root value"))
+		}
+		w.root = true
+		if !w.started {
+			w.started = true
+			w.enc.writeHeader()
+			w.enc.writeStart("plist")
+		}
+		return nil
+	}
+	frame := &w.frames[len(w.frames)-1]
+	if frame.dict {
+		if w.key == nil {
+			return w.fail(errors.New("plist: missing key in dict"))
+		}
+		frame.key = *w.key
+		w.key = nil
+		w.enc.writeKey(frame.key)
+	}
+	frame.n++
+	return nil
+}
+
+// prependPath adds the keys and indices leading to the value being written
+// to the path of err. The value has been started by value unless pending is
+// set.
+func (w *TokenWriter) prependPath(err error, pending bool) error {
+	for i := len(w.frames) - 1; i >= 0; i-- {
+		frame := w.frames[i]
+		last := i == len(w.frames)-1
+		switch {
+		case last && pending && frame.dict:
+			if w.key != nil {
+				err = prependPath(err, *w.key)
+			}
+		case last && pending:
+			err = prependPath(err, indexPath(frame.n))
+		case frame.dict:
+			err = prependPath(err, frame.key)
+		default:
+			err = prependPath(err, indexPath(frame.n-1))
+		}
+	}
+	return err
+}
+
+// fail records err, if any, as the error of all later calls.
+func (w *TokenWriter) fail(err error) error {
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return err
+}
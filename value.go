@@ -0,0 +1,399 @@
+package plist
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// A Value is a plist value of any kind, which keeps everything a plist
+// records about it: integers remember whether they are signed, reals whether
+// they are 32 or 64 bits wide, and data stays distinct from strings.
+//
+// Decoding into a Value or *Value stores the decoded plist as is, and encoding
+// a Value writes it back without loss. Values are built with the New
+// functions; the zero Value is invalid and can't be encoded.
+//
+// The accessors return ok = false when the Value is of another kind. Methods
+// which modify arrays and dictionaries panic when called on other kinds, like
+// the methods of reflect.Value.
+type Value plistValue
+
+var (
+	valueType    = reflect.TypeOf(Value{})
+	valuePtrType = reflect.TypeOf(&Value{})
+)
+
+// NewString returns a string Value.
+func NewString(s string) *Value {
+	return &Value{String, s}
+}
+
+// NewInt returns a signed integer Value.
+func NewInt(i int64) *Value {
+	return &Value{Integer, signedInt{uint64(i), true}}
+}
+
+// NewUint returns an unsigned integer Value.
+func NewUint(u uint64) *Value {
+	return &Value{Integer, signedInt{u, false}}
+}
+
+// NewReal returns a 64-bit real Value.
+func NewReal(f float64) *Value {
+	return &Value{Real, sizedFloat{f, 64}}
+}
+
+// NewReal32 returns a 32-bit real Value.
+func NewReal32(f float32) *Value {
+	return &Value{Real, sizedFloat{float64(f), 32}}
+}
+
+// NewBool returns a boolean Value.
+func NewBool(b bool) *Value {
+	return &Value{Boolean, b}
+}
+
+// NewData returns a data Value.
+func NewData(b []byte) *Value {
+	return &Value{Data, b}
+}
+
+// NewDate returns a date Value.
+func NewDate(t time.Time) *Value {
+	return &Value{Date, t}
+}
+
+// NewUID returns a UID Value.
+func NewUID(uid UID) *Value {
+	return &Value{UIDKind, uid}
+}
+
+// NewNull returns a null Value.
+func NewNull() *Value {
+	return &Value{NullKind, nil}
+}
+
+// NewArray returns an array Value holding elems.
+// It panics if an element is nil; NewNull returns a null Value.
+func NewArray(elems ...*Value) *Value {
+	return &Value{Array, plistValues(elems)}
+}
+
+// NewSet returns a set Value holding elems.
+// It panics if an element is nil.
+func NewSet(elems ...*Value) *Value {
+	return &Value{SetKind, plistValues(elems)}
+}
+
+// NewOrderedSet returns an ordered set Value holding elems.
+// It panics if an element is nil.
+func NewOrderedSet(elems ...*Value) *Value {
+	return &Value{OrderedSetKind, plistValues(elems)}
+}
+
+// NewDict returns an empty dictionary Value.
+func NewDict() *Value {
+	return &Value{Dictionary, newDictionary()}
+}
+
+func plistValues(elems []*Value) []*plistValue {
+	values := make([]*plistValue, len(elems))
+	for i, elem := range elems {
+		values[i] = nonNil(elem)
+	}
+	return values
+}
+
+// nonNil returns v as a *plistValue, and panics if it is nil, so that a nil
+// element is reported where it is added rather than when it is encoded.
+func nonNil(v *Value) *plistValue {
+	if v == nil {
+		panic("plist: nil *Value added to a Value")
+	}
+	return (*plistValue)(v)
+}
+
+// Kind returns the kind of v.
+func (v *Value) Kind() Kind {
+	return v.kind
+}
+
+// AsString returns the string held by v.
+func (v *Value) AsString() (string, bool) {
+	s, ok := v.value.(string)
+	return s, ok && v.kind == String
+}
+
+// AsInt returns the integer held by v, if it fits in an int64.
+func (v *Value) AsInt() (int64, bool) {
+	i, ok := v.value.(signedInt)
+	if !ok || !i.signed && i.value > math.MaxInt64 {
+		return 0, false
+	}
+	return int64(i.value), true
+}
+
+// AsUint returns the integer held by v, if it isn't negative.
+func (v *Value) AsUint() (uint64, bool) {
+	i, ok := v.value.(signedInt)
+	if !ok || i.signed && int64(i.value) < 0 {
+		return 0, false
+	}
+	return i.value, true
+}
+
+// IsSigned reports whether v is a signed integer. Plists only record the sign
+// of negative integers, so positive integers decode as unsigned.
+func (v *Value) IsSigned() bool {
+	i, ok := v.value.(signedInt)
+	return ok && i.signed
+}
+
+// AsReal returns the real held by v.
+func (v *Value) AsReal() (float64, bool) {
+	f, ok := v.value.(sizedFloat)
+	return f.value, ok
+}
+
+// RealBits returns the size of the real held by v, 32 or 64, or 0 if v isn't
+// a real.
+func (v *Value) RealBits() int {
+	f, _ := v.value.(sizedFloat)
+	return f.bits
+}
+
+// AsBool returns the boolean held by v.
+func (v *Value) AsBool() (bool, bool) {
+	b, ok := v.value.(bool)
+	return b, ok
+}
+
+// AsData returns the data held by v.
+func (v *Value) AsData() ([]byte, bool) {
+	b, ok := v.value.([]byte)
+	return b, ok && v.kind == Data
+}
+
+// AsDate returns the date held by v.
+func (v *Value) AsDate() (time.Time, bool) {
+	t, ok := v.value.(time.Time)
+	return t, ok
+}
+
+// AsUID returns the UID held by v.
+func (v *Value) AsUID() (UID, bool) {
+	uid, ok := v.value.(UID)
+	return uid, ok
+}
+
+// Len returns the number of elements of an array or set, or the number of
+// entries of a dictionary. It returns 0 for other kinds.
+func (v *Value) Len() int {
+	switch v.kind {
+	case Array, SetKind, OrderedSetKind:
+		return len(v.value.([]*plistValue))
+	case Dictionary:
+		return len(v.value.(*dictionary).m)
+	}
+	return 0
+}
+
+func (v *Value) elems() []*plistValue {
+	switch v.kind {
+	case Array, SetKind, OrderedSetKind:
+		return v.value.([]*plistValue)
+	}
+	panic("plist: Value of kind " + v.kind.String() + " is not an array or set")
+}
+
+// Index returns the i'th element of an array or set.
+// It panics if v isn't an array or set, or i is out of range.
+func (v *Value) Index(i int) *Value {
+	return (*Value)(v.elems()[i])
+}
+
+// SetIndex replaces the i'th element of an array or set with elem.
+// It panics if v isn't an array or set, i is out of range, or elem is nil.
+func (v *Value) SetIndex(i int, elem *Value) {
+	v.elems()[i] = nonNil(elem)
+}
+
+// Append appends elems to an array or set.
+// It panics if v isn't an array or set, or an element is nil.
+func (v *Value) Append(elems ...*Value) {
+	v.value = append(v.elems(), plistValues(elems)...)
+}
+
+func (v *Value) dict() *dictionary {
+	if v.kind != Dictionary {
+		panic("plist: Value of kind " + v.kind.String() + " is not a dictionary")
+	}
+	return v.value.(*dictionary)
+}
+
+// Keys returns the sorted keys of a dictionary, see OrderedKeys for its order.
+// It panics if v isn't a dictionary.
+func (v *Value) Keys() []string {
+	m := v.dict().m
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// OrderedKeys returns the keys of a dictionary in the order an Encoder writes
+// them with InsertionOrder: the order they were decoded or set in, or sorted
+// if the dictionary has no order, as those made by NewMapDict don't.
+// It panics if v isn't a dictionary.
+func (v *Value) OrderedKeys() []string {
+	d := v.dict()
+	if !d.ordered(InsertionOrder) {
+		return v.Keys()
+	}
+	return append([]string(nil), d.order...)
+}
+
+// Get returns the value for key in a dictionary, or nil if it has none.
+// It panics if v isn't a dictionary.
+func (v *Value) Get(key string) *Value {
+	return (*Value)(v.dict().m[key])
+}
+
+// Set sets the value for key in a dictionary. A new key is added after the
+// existing ones, which is where an Encoder writes it with InsertionOrder.
+// It panics if v isn't a dictionary, or value is nil.
+func (v *Value) Set(key string, value *Value) {
+	v.dict().set(key, nonNil(value))
+}
+
+// Delete removes key from a dictionary.
+// It panics if v isn't a dictionary.
+func (v *Value) Delete(key string) {
+	v.dict().delete(key)
+}
+
+// Copy returns a deep copy of v.
+func (v *Value) Copy() *Value {
+	return (*Value)((*plistValue)(v).copy())
+}
+
+func (pval *plistValue) copy() *plistValue {
+	switch pval.kind {
+	case Array, SetKind, OrderedSetKind:
+		values := pval.value.([]*plistValue)
+		copied := make([]*plistValue, len(values))
+		for i, subv := range values {
+			copied[i] = subv.copy()
+		}
+		return &plistValue{pval.kind, copied}
+	case Dictionary:
+		dict := pval.value.(*dictionary)
+		copied := &dictionary{
+			m:        make(map[string]*plistValue, len(dict.m)),
+			declared: dict.declared,
+		}
+		if dict.order != nil {
+			copied.order = append([]string{}, dict.order...)
+		}
+		for k, subv := range dict.m {
+			copied.m[k] = subv.copy()
+		}
+		return &plistValue{Dictionary, copied}
+	case Data:
+		data := pval.value.([]byte)
+		if data != nil {
+			data = append([]byte{}, data...)
+		}
+		return &plistValue{Data, data}
+	default:
+		copied := *pval
+		return &copied
+	}
+}
+
+// Equal reports whether v and other hold the same plist. Integers are equal
+// when they hold the same number, whether signed or not, and reals when they
+// hold the same number or are both NaN, whatever their size. Sets are equal
+// when they hold the same elements the same number of times, in any order.
+func (v *Value) Equal(other *Value) bool {
+	return (*plistValue)(v).equal((*plistValue)(other))
+}
+
+func (pval *plistValue) equal(other *plistValue) bool {
+	if pval == nil || other == nil {
+		return pval == other
+	}
+	if pval.kind != other.kind {
+		return false
+	}
+	switch pval.kind {
+	case Integer:
+		a, b := pval.value.(signedInt), other.value.(signedInt)
+		if a.value != b.value {
+			return false
+		}
+		// The same bits are different numbers if one is negative.
+		return a.signed == b.signed || int64(a.value) >= 0
+	case Real:
+		a, b := pval.value.(sizedFloat).value, other.value.(sizedFloat).value
+		return a == b || math.IsNaN(a) && math.IsNaN(b)
+	case Data:
+		return bytes.Equal(pval.value.([]byte), other.value.([]byte))
+	case Date:
+		return pval.value.(time.Time).Equal(other.value.(time.Time))
+	case SetKind:
+		return equalSets(pval.value.([]*plistValue), other.value.([]*plistValue))
+	case Array, OrderedSetKind:
+		a, b := pval.value.([]*plistValue), other.value.([]*plistValue)
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !a[i].equal(b[i]) {
+				return false
+			}
+		}
+		return true
+	case Dictionary:
+		a, b := pval.value.(*dictionary).m, other.value.(*dictionary).m
+		if len(a) != len(b) {
+			return false
+		}
+		for k, subv := range a {
+			if !subv.equal(b[k]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return pval.value == other.value
+	}
+}
+
+// equalSets reports whether a and b hold the same elements the same number
+// of times, matching each element of a with an equal element of b not yet
+// matched.
+func equalSets(a, b []*plistValue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	matched := make([]bool, len(b))
+	for _, subv := range a {
+		found := false
+		for j, other := range b {
+			if !matched[j] && subv.equal(other) {
+				matched[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
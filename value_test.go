@@ -0,0 +1,221 @@
+package plist
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValueDecodeEncode(t *testing.T) {
+	date := time.Date(2011, 5, 12, 1, 0, 0, 0, time.UTC)
+	doc := NewDict()
+	doc.Set("int", NewInt(42))
+	doc.Set("uint", NewUint(42))
+	doc.Set("real32", NewReal32(0.5))
+	doc.Set("real64", NewReal(0.5))
+	doc.Set("data", NewData([]byte("abc")))
+	doc.Set("string", NewString("abc"))
+	doc.Set("date", NewDate(date))
+	doc.Set("uid", NewUID(3))
+	doc.Set("set", NewSet(NewString("a"), NewBool(true)))
+	doc.Set("array", NewArray(NewNull(), NewOrderedSet()))
+
+	var buf bytes.Buffer
+	if err := NewTypedJSONEncoder(&buf).Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Value
+	if err := NewTypedJSONDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(doc) {
+		t.Errorf("decoded %#v, want %#v", decoded, doc)
+	}
+	if !decoded.Get("int").IsSigned() || decoded.Get("uint").IsSigned() {
+		t.Error("decoded integers lost their sign")
+	}
+	if have := decoded.Get("real32").RealBits(); have != 32 {
+		t.Errorf("decoded real32 has %d bits", have)
+	}
+	if _, ok := decoded.Get("data").AsString(); ok {
+		t.Error("decoded data is a string")
+	}
+
+	// A *Value field holds the subtree as is.
+	b, err := MarshalBinary(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var partial struct {
+		Set   *Value `plist:"set"`
+		Array Value  `plist:"array"`
+		Date  time.Time
+	}
+	if err := Unmarshal(b, &partial); err != nil {
+		t.Fatal(err)
+	}
+	if partial.Set.Kind() != SetKind || partial.Set.Len() != 2 {
+		t.Errorf("decoded set %#v", partial.Set)
+	}
+	if partial.Array.Index(0).Kind() != NullKind {
+		t.Errorf("decoded array %#v", partial.Array)
+	}
+}
+
+func TestValueAccessors(t *testing.T) {
+	if i, ok := NewUint(math.MaxUint64).AsInt(); ok {
+		t.Errorf("AsInt of MaxUint64 returned %d", i)
+	}
+	if u, ok := NewInt(-1).AsUint(); ok {
+		t.Errorf("AsUint of -1 returned %d", u)
+	}
+	if i, ok := NewUint(7).AsInt(); !ok || i != 7 {
+		t.Errorf("AsInt of 7 returned %d, %v", i, ok)
+	}
+	if _, ok := NewString("1").AsReal(); ok {
+		t.Error("AsReal of a string succeeded")
+	}
+	if !NewInt(7).Equal(NewUint(7)) || NewInt(-1).Equal(NewUint(math.MaxUint64)) {
+		t.Error("integers compare by number")
+	}
+	if !NewReal(math.NaN()).Equal(NewReal32(float32(math.NaN()))) {
+		t.Error("NaN doesn't equal NaN")
+	}
+	a, b, one := NewString("a"), NewString("b"), NewInt(1)
+	if !NewSet(a, b, one).Equal(NewSet(one, b, a)) {
+		t.Error("sets compare in order")
+	}
+	if NewSet(a, a, b).Equal(NewSet(a, b, b)) || NewSet(a, b).Equal(NewSet(a, a)) {
+		t.Error("sets don't compare the number of times elements occur")
+	}
+	if NewArray(a, b).Equal(NewArray(b, a)) || NewOrderedSet(a, b).Equal(NewOrderedSet(b, a)) {
+		t.Error("arrays and ordered sets don't compare in order")
+	}
+
+	arr := NewArray(NewString("a"))
+	arr.Append(NewString("b"), NewString("c"))
+	arr.SetIndex(0, NewString("z"))
+	if s, _ := arr.Index(0).AsString(); arr.Len() != 3 || s != "z" {
+		t.Errorf("array is %#v", arr)
+	}
+
+	dict := NewDict()
+	dict.Set("b", NewBool(true))
+	dict.Set("a", arr)
+	dict.Set("c", NewNull())
+	dict.Delete("c")
+	if keys := dict.Keys(); len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("keys are %v", keys)
+	}
+	if dict.Get("c") != nil {
+		t.Error("deleted key is still present")
+	}
+
+	copied := dict.Copy()
+	copied.Get("a").SetIndex(1, NewString("changed"))
+	if !dict.Equal(dict.Copy()) || dict.Equal(copied) {
+		t.Error("Copy isn't deep")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Get on an array didn't panic")
+		}
+	}()
+	arr.Get("a")
+}
+
+func TestValueKeyOrder(t *testing.T) {
+	doc := `<plist><dict><key>c</key><integer>1</integer><key>a</key><integer>2</integer><key>b</key><integer>3</integer></dict></plist>`
+	var v Value
+	if err := Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatal(err)
+	}
+	v.Set("a", NewInt(4))
+	v.Delete("c")
+	v.Set("0", NewInt(5))
+	copied := v.Copy()
+	if keys := copied.OrderedKeys(); !reflect.DeepEqual(keys, []string{"a", "b", "0"}) {
+		t.Errorf("ordered keys are %v", keys)
+	}
+	if keys := copied.Keys(); !reflect.DeepEqual(keys, []string{"0", "a", "b"}) {
+		t.Errorf("keys are %v", keys)
+	}
+	unordered := NewMapDict(2)
+	unordered.Set("b", NewInt(1))
+	unordered.Set("a", NewInt(2))
+	if keys := unordered.OrderedKeys(); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("ordered keys of a map dictionary are %v", keys)
+	}
+
+	for _, tt := range []struct {
+		order KeyOrder
+		want  string
+	}{
+		{SortedKeys, "<key>0</key><integer>5</integer><key>a</key><integer>4</integer><key>b</key><integer>3</integer>"},
+		{InsertionOrder, "<key>a</key><integer>4</integer><key>b</key><integer>3</integer><key>0</key><integer>5</integer>"},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetKeyOrder(tt.order)
+		if err := enc.Encode(copied); err != nil {
+			t.Fatal(err)
+		}
+		if have := buf.String(); !strings.Contains(have, "<dict>"+tt.want+"</dict>") {
+			t.Errorf("key order %d: encoded %s, want %s", tt.order, have, tt.want)
+		}
+	}
+}
+
+func TestValueInvalid(t *testing.T) {
+	nested := NewDict()
+	nested.Set("a", &Value{})
+	encoders := map[string]func(io.Writer) *Encoder{
+		"xml":        NewEncoder,
+		"binary":     NewBinaryEncoder,
+		"openstep":   NewOpenStepEncoder,
+		"gnustep":    NewGNUStepEncoder,
+		"json":       NewJSONEncoder,
+		"typed json": NewTypedJSONEncoder,
+	}
+	for name, newEncoder := range encoders {
+		for _, v := range []interface{}{Value{}, &Value{}, nested} {
+			err := newEncoder(ioutil.Discard).Encode(v)
+			if _, ok := err.(*UnsupportedValueError); !ok {
+				t.Errorf("%s: encoding %#v: have error %v, want *UnsupportedValueError", name, v, err)
+			}
+		}
+	}
+}
+
+func TestValueNilElements(t *testing.T) {
+	tests := map[string]func(){
+		"NewArray":      func() { NewArray(NewInt(1), nil) },
+		"NewSet":        func() { NewSet(nil) },
+		"NewOrderedSet": func() { NewOrderedSet(nil) },
+		"Append":        func() { NewArray().Append(nil) },
+		"SetIndex":      func() { NewArray(NewInt(1)).SetIndex(0, nil) },
+		"Set":           func() { NewDict().Set("a", nil) },
+	}
+	for name, f := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("%s with a nil *Value didn't panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+	// A null element is added with NewNull.
+	v := NewDict()
+	v.Set("a", NewNull())
+	if err := NewJSONEncoder(ioutil.Discard).Encode(v); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,218 @@
+package plist
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// decodeXML decodes the next XML plist into v in a single pass. Dictionaries
+// decoded into structs and arrays decoded into slices are read straight into
+// their fields and elements, and the values of keys which don't match any
+// field are skipped without being parsed, unless the decoder is strict. Other values are parsed into
+// plistValues and decoded with unmarshal, so that both ways decode the same.
+func (d *Decoder) decodeXML(v reflect.Value) error {
+	p := d.newXMLParser()
+	return p.document(func(name string) error {
+		return d.collect(d.decodeXMLElement(p, name, v))
+	})
+}
+
+// decodeXMLElement decodes the element name, whose start element was the last
+// token read by p, into v.
+func (d *Decoder) decodeXMLElement(p *xmlParser, name string, v reflect.Value) error {
+	if !d.decodesXMLStream(name, v) {
+		pval, err := p.parseXMLElement(name)
+		if err != nil {
+			return err
+		}
+		return d.unmarshal(pval, v)
+	}
+
+	// Follow pointers like unmarshal does.
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	start := p.tokenStart
+	if err := p.limits.enter(); err != nil {
+		return p.syntaxError(start, err)
+	}
+	defer p.limits.leave()
+	if name == "dict" {
+		return d.decodeXMLStruct(p, start, v)
+	}
+	return d.decodeXMLSlice(p, start, v)
+}
+
+// decodesXMLStream reports whether the element name decoded into v is
+// decoded in a single pass, which dictionaries decoded into structs and
+// arrays decoded into slices are. Values, OrderedMaps, interfaces and
+// Unmarshalers go through unmarshal instead.
+func (d *Decoder) decodesXMLStream(name string, v reflect.Value) bool {
+	if d.disallowUnknownFields {
+		// Unknown fields are reported before any field is decoded.
+		return false
+	}
+	t := v.Type()
+	for {
+		switch {
+		case t == valueType || t == valuePtrType,
+			t == orderedMapType,
+			t.Kind() == reflect.Interface,
+			t.Implements(unmarshalerType),
+			reflect.PtrTo(t).Implements(unmarshalerType):
+			return false
+		case t.Kind() == reflect.Struct:
+			return name == "dict"
+		case t.Kind() == reflect.Slice:
+			return name == "array"
+		case t.Kind() != reflect.Ptr:
+			return false
+		}
+		t = t.Elem()
+	}
+}
+
+// decodeXMLStruct decodes the rest of a dict element into the struct v.
+func (d *Decoder) decodeXMLStruct(p *xmlParser, start xmlPosition, v reflect.Value) error {
+	fields := cachedTypeFields(v.Type())
+	var key *string
+	var keys map[string]bool // keys read so far, for strict decoders
+	n := 0
+	for {
+		tok, err := p.token()
+		if err != nil {
+			return p.syntaxError(start, err)
+		}
+		if tok.kind == xmlEndElement {
+			if key != nil && p.strict {
+				return p.syntaxError(start, fmt.Errorf("plist: missing value for key %q", *key))
+			}
+			return nil
+		}
+		if err := p.checkText(tok); err != nil {
+			return err
+		}
+		if tok.kind != xmlStartElement {
+			continue
+		}
+		if tok.name == "key" {
+			offset := p.tokenStart
+			k, err := p.parseKey()
+			if err != nil {
+				return p.syntaxError(offset, err)
+			}
+			if p.strict {
+				if key != nil {
+					return p.syntaxError(offset, fmt.Errorf("plist: missing value for key %q", *key))
+				}
+				if keys[k] {
+					return p.syntaxError(offset, fmt.Errorf("plist: duplicate key %q", k))
+				}
+				if keys == nil {
+					keys = make(map[string]bool)
+				}
+				keys[k] = true
+			}
+			if err := p.limits.bytes(uint64(len(k))); err != nil {
+				return p.syntaxError(offset, err)
+			}
+			key = &k
+			continue
+		}
+		if key == nil {
+			return p.syntaxError(p.tokenStart, errors.New("plist: missing key in dict"))
+		}
+		n++
+		if err := p.limits.collection(uint64(n)); err != nil {
+			return p.syntaxError(p.tokenStart, err)
+		}
+		p.path = append(p.path, *key)
+		if f, ok := findField(fields, *key); ok {
+			err = d.decodeXMLElem(p, tok.name, f.value(v), *key)
+		} else if p.strict {
+			// Strict decoders reject malformed values even where they're
+			// ignored, so they're parsed and dropped.
+			_, err = p.parseXMLElement(tok.name)
+		} else if err = p.skip(); err != nil {
+			err = p.syntaxError(p.tokenStart, err)
+		}
+		if err != nil {
+			return err
+		}
+		p.path = p.path[:len(p.path)-1]
+		key = nil
+	}
+}
+
+// decodeXMLSlice decodes the rest of an array element into the slice v.
+func (d *Decoder) decodeXMLSlice(p *xmlParser, start xmlPosition, v reflect.Value) error {
+	n := 0
+	for {
+		tok, err := p.token()
+		if err != nil {
+			return p.syntaxError(start, err)
+		}
+		if tok.kind == xmlEndElement {
+			break
+		}
+		if err := p.checkText(tok); err != nil {
+			return err
+		}
+		if tok.kind != xmlStartElement {
+			continue
+		}
+		if err := p.limits.collection(uint64(n) + 1); err != nil {
+			return p.syntaxError(p.tokenStart, err)
+		}
+		// Like newArrayDecoder, decode into the elements of a non-empty
+		// slice, and grow it as needed.
+		if n >= v.Cap() {
+			ncap := 2 * n
+			if ncap < 4 {
+				ncap = 4
+			}
+			new := reflect.MakeSlice(v.Type(), v.Len(), ncap)
+			reflect.Copy(new, v)
+			v.Set(new)
+		}
+		if n >= v.Len() {
+			v.SetLen(n + 1)
+		}
+		elem := indexPath(n)
+		p.path = append(p.path, elem)
+		if err := d.decodeXMLElem(p, tok.name, v.Index(n), elem); err != nil {
+			return err
+		}
+		p.path = p.path[:len(p.path)-1]
+		n++
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeSlice(v.Type(), 0, 4))
+	}
+	v.SetLen(n)
+	return nil
+}
+
+// decodeXMLElem decodes an element of a dictionary or array, which elem names
+// in the path of errors, like unmarshalElem.
+func (d *Decoder) decodeXMLElem(p *xmlParser, name string, v reflect.Value, elem string) error {
+	d.path = append(d.path, elem)
+	err := d.collect(d.decodeXMLElement(p, name, v))
+	d.path = d.path[:len(d.path)-1]
+	return err
+}
+
+// findField returns the field of fields with the given name.
+func findField(fields []field, name string) (field, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return field{}, false
+}
@@ -1,8 +1,8 @@
 package plist
 
 import (
+	"bytes"
 	"encoding/base64"
-	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
@@ -11,163 +11,373 @@ import (
 	"time"
 )
 
-// xmlParser uses xml.Decoder to parse an xml plist into the corresponding plistValues
+// xmlParser parses an xml plist into the corresponding plistValues
 type xmlParser struct {
-	*xml.Decoder
+	*xmlScanner
+
+	// path holds the keys and indices leading to the value being parsed.
+	path []string
+	// strict rejects documents which Apple's tools wouldn't write, see
+	// Decoder.Strict.
+	strict bool
+	limits *limiter
 }
 
 // newXMLParser returns a new xmlParser
 func newXMLParser(r io.Reader) *xmlParser {
-	return &xmlParser{xml.NewDecoder(r)}
+	return &xmlParser{
+		xmlScanner: newXMLScanner(r),
+		limits:     newLimiter(DecoderLimits{}),
+	}
 }
 
-func (p *xmlParser) parseDocument(start *xml.StartElement) (*plistValue, error) {
-	if start == nil {
-		for {
-			tok, err := p.Token()
-			if err != nil {
-				return nil, err
-			}
-			if t, ok := tok.(xml.StartElement); ok {
-				start = &t
-				break
-			}
+func (p *xmlParser) parseDocument() (*plistValue, error) {
+	var pval *plistValue
+	err := p.document(func(name string) (err error) {
+		pval, err = p.parseXMLElement(name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pval, nil
+}
+
+// document reads the next plist document, and calls value with the name of
+// its root value, whose start element was the last token read. value must
+// read the rest of the element.
+func (p *xmlParser) document(value func(name string) error) error {
+	p.path = p.path[:0]
+	// Consume what was read of the input, so that the next document
+	// starts after it.
+	defer p.release()
+	var start xmlToken
+	for {
+		tok, err := p.token()
+		if err == io.EOF {
+			// no document left in the stream
+			return err
+		}
+		if err != nil {
+			return p.syntaxError(p.tokenStart, err)
+		}
+		if tok.kind == xmlStartElement {
+			start = tok
+			break
+		}
+		if err := p.checkText(tok); err != nil {
+			return err
+		}
+	}
+	if p.strict {
+		if start.name != "plist" {
+			return p.syntaxError(p.tokenStart, fmt.Errorf("plist: root element is %s, not plist", start.name))
+		}
+		if version := p.attr("version"); version != "1.0" {
+			return p.syntaxError(p.tokenStart, fmt.Errorf("plist: plist version is %q, not \"1.0\"", version))
+		}
+	}
+	var err error
+	if start.name == "plist" {
+		err = p.plistBody(value)
+	} else {
+		err = value(start.name)
+	}
+	if err != nil || !p.strict {
+		return err
+	}
+	// Only comments and processing instructions may follow the document.
+	for {
+		tok, err := p.token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return p.syntaxError(p.tokenStart, err)
+		}
+		if tok.kind == xmlStartElement {
+			return p.syntaxError(p.tokenStart, errors.New("plist: trailing data after the document"))
+		}
+		if err := p.checkText(tok); err != nil {
+			return err
 		}
 	}
-	return p.parseXMLElement(start)
 }
 
-func (p *xmlParser) parseXMLElement(element *xml.StartElement) (*plistValue, error) {
-	switch element.Name.Local {
+// checkText returns an error if the parser is strict and tok is text other
+// than whitespace, which plists only hold inside of elements such as string.
+func (p *xmlParser) checkText(tok xmlToken) error {
+	if tok.kind == xmlCharData && p.strict && len(bytes.TrimSpace(tok.text)) > 0 {
+		return p.syntaxError(p.tokenStart, fmt.Errorf("plist: unexpected text %q", string(tok.text)))
+	}
+	return nil
+}
+
+// parseXMLElement parses the value of the element name, whose start element
+// was the last token read. Errors are returned as a *SyntaxError.
+func (p *xmlParser) parseXMLElement(name string) (*plistValue, error) {
+	start := p.tokenStart
+	if name != "plist" {
+		if err := p.limits.enter(); err != nil {
+			return nil, p.syntaxError(start, err)
+		}
+		defer p.limits.leave()
+	}
+	pval, err := p.parseElement(name)
+	if err != nil {
+		return nil, p.syntaxError(start, err)
+	}
+	return pval, nil
+}
+
+func (p *xmlParser) parseElement(name string) (*plistValue, error) {
+	switch name {
 	case "plist":
-		return p.parsePlist(element)
+		return p.parsePlist()
 	case "dict":
-		return p.parseDict(element)
+		return p.parseDict()
 	case "string":
-		return p.parseString(element)
+		return p.parseString()
 	case "true", "false":
-		return p.parseBoolean(element)
+		return p.parseBoolean(name)
 	case "array":
-		return p.parseArray(element)
+		return p.parseArray()
 	case "real":
-		return p.parseReal(element)
+		return p.parseReal()
 	case "integer":
-		return p.parseInteger(element)
+		return p.parseInteger()
 	case "data":
-		return p.parseData(element)
+		return p.parseData()
 	case "date":
-		return p.parseDate(element)
+		return p.parseDate()
 	default:
-		return nil, fmt.Errorf("plist: Unknown plist element %s", element.Name.Local)
+		return nil, fmt.Errorf("plist: Unknown plist element %s", name)
 	}
 }
 
-func (p *xmlParser) parsePlist(element *xml.StartElement) (*plistValue, error) {
+func (p *xmlParser) parsePlist() (*plistValue, error) {
+	var pval *plistValue
+	err := p.plistBody(func(name string) (err error) {
+		pval, err = p.parseXMLElement(name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pval, nil
+}
+
+// plistBody reads the rest of a plist element, whose start element was the
+// last token read, and calls value with the name of the value it holds.
+// Errors other than those of value are returned as a *SyntaxError.
+func (p *xmlParser) plistBody(value func(name string) error) error {
+	start := p.tokenStart
 	for {
-		token, err := p.Token()
+		tok, err := p.token()
 		if err != nil {
-			return nil, err
+			return p.syntaxError(p.tokenStart, err)
 		}
-		if el, ok := token.(xml.EndElement); ok && el.Name.Local == "plist" {
+		if tok.kind == xmlEndElement {
 			break
 		}
-		if el, ok := token.(xml.StartElement); ok {
-			return p.parseXMLElement(&el)
+		if tok.kind == xmlStartElement {
+			if err := value(tok.name); err != nil {
+				return err
+			}
+			if p.strict {
+				return p.parsePlistEnd()
+			}
+			// consume the rest of the document up to and including </plist>
+			// so that a following Decode starts at the next document.
+			if err := p.skip(); err != nil {
+				if err == io.ErrUnexpectedEOF {
+					// The input ends after the root value without
+					// </plist>, which ends the document as well.
+					p.stack = p.stack[:0]
+					return nil
+				}
+				return p.syntaxError(p.tokenStart, err)
+			}
+			return nil
+		}
+		if err := p.checkText(tok); err != nil {
+			return err
+		}
+	}
+	return p.syntaxError(start, errors.New("plist: Invalid plist"))
+}
+
+// parsePlistEnd reads up to and including </plist>, and returns an error if
+// the plist holds more than one root object.
+func (p *xmlParser) parsePlistEnd() error {
+	for {
+		tok, err := p.token()
+		if err != nil {
+			return p.syntaxError(p.tokenStart, err)
+		}
+		switch tok.kind {
+		case xmlEndElement:
+			return nil
+		case xmlStartElement:
+			return p.syntaxError(p.tokenStart, fmt.Errorf("plist: multiple root objects, found %s", tok.name))
+		}
+		if err := p.checkText(tok); err != nil {
+			return err
 		}
 	}
-	return nil, errors.New("plist: Invalid plist")
 }
 
-func (p *xmlParser) parseDict(element *xml.StartElement) (*plistValue, error) {
+func (p *xmlParser) parseDict() (*plistValue, error) {
 	var key *string
-	var subvalues = make(map[string]*plistValue)
+	dict := newDictionary()
 	for {
-		token, err := p.Token()
+		tok, err := p.token()
 		if err != nil {
 			return nil, err
 		}
-		if el, ok := token.(xml.EndElement); ok && el.Name.Local == "dict" {
+		if tok.kind == xmlEndElement {
+			if key != nil && p.strict {
+				return nil, fmt.Errorf("plist: missing value for key %q", *key)
+			}
 			break
 		}
-		if el, ok := token.(xml.StartElement); ok {
-			if el.Name.Local == "key" {
-				var k string
-				if err := p.DecodeElement(&k, &el); err != nil {
-					return nil, err
-				}
-				key = &k
-				continue
+		if err := p.checkText(tok); err != nil {
+			return nil, err
+		}
+		if tok.kind != xmlStartElement {
+			continue
+		}
+		if tok.name == "key" {
+			offset := p.tokenStart
+			k, err := p.parseKey()
+			if err != nil {
+				return nil, p.syntaxError(offset, err)
 			}
-			if key == nil {
-				return nil, errors.New("plist: missing key in dict")
+			if p.strict && key != nil {
+				return nil, p.syntaxError(offset, fmt.Errorf("plist: missing value for key %q", *key))
 			}
-			subvalues[*key], err = p.parseXMLElement(&el)
-			if err != nil {
-				return nil, err
+			if _, ok := dict.m[k]; ok && p.strict {
+				return nil, p.syntaxError(offset, fmt.Errorf("plist: duplicate key %q", k))
 			}
-			key = nil
+			if err := p.limits.bytes(uint64(len(k))); err != nil {
+				return nil, p.syntaxError(offset, err)
+			}
+			key = &k
+			continue
+		}
+		if key == nil {
+			return nil, p.syntaxError(p.tokenStart, errors.New("plist: missing key in dict"))
 		}
+		if err := p.limits.collection(uint64(len(dict.m)) + 1); err != nil {
+			return nil, p.syntaxError(p.tokenStart, err)
+		}
+		p.path = append(p.path, *key)
+		sval, err := p.parseXMLElement(tok.name)
+		if err != nil {
+			return nil, err
+		}
+		dict.set(*key, sval)
+		p.path = p.path[:len(p.path)-1]
+		key = nil
+	}
+	return &plistValue{Dictionary, dict}, nil
+}
+
+// parseKey reads the rest of a key element, whose start element was the last
+// token read, and returns the key.
+func (p *xmlParser) parseKey() (string, error) {
+	text, err := p.limitedText(String)
+	if err != nil {
+		return "", err
 	}
-	return &plistValue{Dictionary, &dictionary{m: subvalues}}, nil
+	return string(text), nil
 }
 
-func (p *xmlParser) parseString(element *xml.StartElement) (*plistValue, error) {
-	var value string
-	if err := p.DecodeElement(&value, element); err != nil {
+func (p *xmlParser) parseString() (*plistValue, error) {
+	text, err := p.limitedText(String)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.limits.bytes(uint64(len(text))); err != nil {
 		return nil, err
 	}
-	return &plistValue{String, value}, nil
+	return &plistValue{String, string(text)}, nil
 }
 
-func (p *xmlParser) parseBoolean(element *xml.StartElement) (*plistValue, error) {
-	if err := p.Skip(); err != nil {
+func (p *xmlParser) parseBoolean(name string) (*plistValue, error) {
+	if p.strict {
+		// Booleans are empty elements, as in <true/>.
+		tok, err := p.token()
+		if err != nil {
+			return nil, err
+		}
+		if tok.kind != xmlEndElement {
+			return nil, fmt.Errorf("plist: %s element isn't empty", name)
+		}
+	} else if err := p.skip(); err != nil {
 		return nil, err
 	}
-	plistBoolean := element.Name.Local == "true"
+	plistBoolean := name == "true"
 	return &plistValue{Boolean, plistBoolean}, nil
 }
 
-func (p *xmlParser) parseArray(element *xml.StartElement) (*plistValue, error) {
+func (p *xmlParser) parseArray() (*plistValue, error) {
 	var subvalues []*plistValue
 	for {
-		token, err := p.Token()
+		tok, err := p.token()
 		if err != nil {
 			return nil, err
 		}
-		if el, ok := token.(xml.EndElement); ok && el.Name.Local == "array" {
+		if tok.kind == xmlEndElement {
 			break
 		}
-		if el, ok := token.(xml.StartElement); ok {
-			subv, err := p.parseXMLElement(&el)
+		if err := p.checkText(tok); err != nil {
+			return nil, err
+		}
+		if tok.kind == xmlStartElement {
+			if err := p.limits.collection(uint64(len(subvalues)) + 1); err != nil {
+				return nil, p.syntaxError(p.tokenStart, err)
+			}
+			p.path = append(p.path, indexPath(len(subvalues)))
+			subv, err := p.parseXMLElement(tok.name)
 			if err != nil {
 				return nil, err
 			}
+			p.path = p.path[:len(p.path)-1]
 			subvalues = append(subvalues, subv)
 		}
 	}
 	return &plistValue{Array, subvalues}, nil
 }
 
-func (p *xmlParser) parseReal(element *xml.StartElement) (*plistValue, error) {
-	var n float64
-	if err := p.DecodeElement(&n, element); err != nil {
+func (p *xmlParser) parseReal() (*plistValue, error) {
+	text, err := p.limitedText(Real)
+	if err != nil {
 		return nil, err
 	}
+	// An empty real is zero, as it was when reals were decoded by
+	// encoding/xml.
+	var n float64
+	if len(text) > 0 {
+		if n, err = strconv.ParseFloat(strings.TrimSpace(string(text)), 64); err != nil {
+			return nil, err
+		}
+	}
 	return &plistValue{Real, sizedFloat{n, 64}}, nil
 }
 
-func (p *xmlParser) parseInteger(element *xml.StartElement) (*plistValue, error) {
+func (p *xmlParser) parseInteger() (*plistValue, error) {
 	// Based on testing with plutil -lint, the largest positive integer
 	// that you can store in an XML plist is 2^64 - 1 (in a uint64)
 	// and the largest negative integer you can store is -2^63 (in an int64)
 	// Since we need to know the sign before we can know what integer type
-	// to decode into, first decode into a string to check for "-".
-	var s string
-	if err := p.DecodeElement(&s, element); err != nil {
+	// to decode into, first read the text to check for "-".
+	text, err := p.limitedText(Integer)
+	if err != nil {
 		return nil, err
 	}
 	// Determine if this is a negative number by checking for minus sign.
-	s = strings.TrimSpace(s)
+	s := strings.TrimSpace(string(text))
 	if strings.HasPrefix(s, "-") {
 		i, err := strconv.ParseInt(s, 10, 64)
 		if err != nil {
@@ -183,28 +393,115 @@ func (p *xmlParser) parseInteger(element *xml.StartElement) (*plistValue, error)
 	return &plistValue{Integer, signedInt{u, false}}, nil
 }
 
-func (p *xmlParser) parseData(element *xml.StartElement) (*plistValue, error) {
-	replacer := strings.NewReplacer("\t", "", "\n", "", " ", "", "\r", "")
-	var data []byte
-	if err := p.DecodeElement(&data, element); err != nil {
+func (p *xmlParser) parseData() (*plistValue, error) {
+	text, err := p.limitedText(Data)
+	if err != nil {
 		return nil, err
 	}
-	if len(data) == 0 {
+	if len(text) == 0 && !p.dropped {
 		return &plistValue{Data, []byte(nil)}, nil
 	}
-	str := replacer.Replace(string(data))
-	decoded, err := base64.StdEncoding.DecodeString(str)
+	// Leave out the whitespace which data is wrapped with, which the
+	// scanner leaves in when it comes from entities.
+	n := 0
+	for _, b := range text {
+		if !isXMLSpace(b) {
+			text[n] = b
+			n++
+		}
+	}
+	data := make([]byte, base64.StdEncoding.DecodedLen(n))
+	n, err = base64.StdEncoding.Decode(data, text[:n])
 	if err != nil {
 		return nil, err
 	}
-	data = []byte(decoded)
-	return &plistValue{Data, data}, nil
+	if err := p.limits.bytes(uint64(n)); err != nil {
+		return nil, err
+	}
+	return &plistValue{Data, data[:n]}, nil
 }
 
-func (p *xmlParser) parseDate(element *xml.StartElement) (*plistValue, error) {
+func (p *xmlParser) parseDate() (*plistValue, error) {
+	text, err := p.limitedText(Date)
+	if err != nil {
+		return nil, err
+	}
 	var date time.Time
-	if err := p.DecodeElement(&date, element); err != nil {
+	if err := date.UnmarshalText(text); err != nil {
 		return nil, err
 	}
 	return &plistValue{Date, date}, nil
 }
+
+// limitedText reads the text of the element whose start element was the last
+// token read, of a value of the given kind, with elementText. Rather than
+// buffering more text than the limits allow, it returns a *LimitError:
+// strings, keys and data may hold what MaxString and MaxTotal leave, though
+// they aren't counted here, and other values MaxString bytes. Data is read
+// without its whitespace, and limited by the length of its base64 encoding.
+func (p *xmlParser) limitedText(kind Kind) ([]byte, error) {
+	max := p.limits.MaxString
+	if kind == String || kind == Data {
+		max = p.limits.remaining()
+	}
+	textMax := max
+	if kind == Data && max <= maxInt/4*3-3 {
+		textMax = base64.StdEncoding.EncodedLen(max)
+	}
+	text, err := p.elementText(textMax, kind == Data)
+	if err == errTextLimit {
+		return nil, p.limits.bytes(uint64(max) + 1)
+	}
+	return text, err
+}
+
+// syntaxError returns err as a *SyntaxError at the given position, unless it
+// already is one. Malformed XML and unexpected ends of the input are reported
+// where the scanner found them instead.
+func (p *xmlParser) syntaxError(pos xmlPosition, err error) error {
+	msg := strings.TrimPrefix(err.Error(), "plist: ")
+	switch e := err.(type) {
+	case *SyntaxError:
+		return err
+	case *xmlSyntaxError:
+		msg = e.msg
+		pos = e.pos
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == io.ErrUnexpectedEOF {
+		msg = err.Error()
+		pos = p.position()
+	}
+	return &SyntaxError{
+		Msg:    msg,
+		Line:   pos.line,
+		Column: pos.column,
+		Offset: pos.offset,
+		Path:   formatPath(p.path),
+		Err:    err,
+	}
+}
+
+// A SyntaxError describes malformed plist input, and where it is.
+type SyntaxError struct {
+	Msg    string // description of the error
+	Line   int    // line of the error, starting at 1
+	Column int    // column of the error in bytes, starting at 1
+	Offset int64  // byte offset of the error in the document
+	Path   string // keys and indices of the enclosing value, as in "Items[2].Name"
+	Err    error  // underlying error, if any
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("plist: line %d, column %d: %s", e.Line, e.Column, e.Msg)
+	}
+	return fmt.Sprintf("plist: line %d, column %d: %s (in %s)", e.Line, e.Column, e.Msg, e.Path)
+}
+
+// Unwrap returns the underlying error.
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
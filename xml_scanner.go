@@ -0,0 +1,930 @@
+package plist
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// xmlTokenKind is the kind of an xmlToken.
+type xmlTokenKind int
+
+const (
+	xmlStartElement xmlTokenKind = iota + 1
+	xmlEndElement
+	xmlCharData
+)
+
+// An xmlToken is a token read by xmlScanner. Comments, processing
+// instructions and directives such as <!DOCTYPE> are left out.
+type xmlToken struct {
+	kind xmlTokenKind
+	name string // local name of a start or end element
+	text []byte // character data, valid until the next token is read
+}
+
+// An xmlAttr is an attribute of the last start element read.
+type xmlAttr struct {
+	name, value string
+}
+
+// xmlScanner reads the XML of plists. It handles the subset of XML which
+// plists are written in: elements and attributes, the predefined and
+// character entities and CDATA sections. Comments, processing instructions
+// and directives such as <!DOCTYPE>, including an internal DTD subset, are
+// skipped. Like encoding/xml, which plists used to be
+// read with, it normalizes line endings in text, checks that end elements
+// match their start elements, and rejects text which isn't valid UTF-8 or
+// holds characters XML doesn't allow.
+//
+// It reads through a bufio.Reader, and only consumes the bytes it has
+// scanned, so that the next document in a stream can be read after it.
+type xmlScanner struct {
+	src *bufio.Reader
+	buf []byte // bytes buffered by src, which are consumed up to pos
+	pos int
+	err error // read error of src other than io.EOF
+
+	base      int64 // input offset of buf[0]
+	lines     int   // number of bytes of buf searched for line feeds
+	line      int   // number of line feeds found so far
+	lineStart int64 // input offset of the line after the last line feed
+
+	// tokenStart is the position of the last token read.
+	tokenStart xmlPosition
+
+	stack      []string // names of the open elements
+	attrs      []xmlAttr
+	pendingEnd bool   // whether the last start element was self-closing
+	text       []byte // character data of the last token
+	keepText   bool   // whether character data is appended to text
+	maxText    int    // most bytes elementText reads, see errTextLimit
+	dropSpace  bool   // whether elementText leaves out whitespace
+	dropped    bool   // whether elementText left out any whitespace
+	name       []byte // scratch space for names and character entities
+}
+
+// newXMLScanner returns a scanner reading from r, which is wrapped in a
+// bufio.Reader unless it is one.
+func newXMLScanner(r io.Reader) *xmlScanner {
+	src, ok := r.(*bufio.Reader)
+	if !ok {
+		src = bufio.NewReader(r)
+	}
+	return &xmlScanner{src: src}
+}
+
+// An xmlPosition is a position in the input of an xmlScanner. Positions are
+// worked out as the input is scanned, since the scanner doesn't keep track
+// of where lines started before the current one.
+type xmlPosition struct {
+	offset       int64
+	line, column int
+}
+
+// An xmlSyntaxError is malformed XML found at a position in the input.
+type xmlSyntaxError struct {
+	msg string
+	pos xmlPosition
+}
+
+func (e *xmlSyntaxError) Error() string {
+	return "xml: " + e.msg
+}
+
+// syntaxError returns an error about malformed XML at the current position.
+func (s *xmlScanner) syntaxError(format string, args ...interface{}) error {
+	return &xmlSyntaxError{msg: fmt.Sprintf(format, args...), pos: s.position()}
+}
+
+// offset returns the input offset of the next byte to be scanned.
+func (s *xmlScanner) offset() int64 {
+	return s.base + int64(s.pos)
+}
+
+// ensure reports whether at least n bytes are buffered past pos, reading
+// more if needed. n must not exceed the size of the bufio.Reader.
+func (s *xmlScanner) ensure(n int) bool {
+	if len(s.buf)-s.pos >= n {
+		return true
+	}
+	if s.err != nil {
+		return false
+	}
+	s.release()
+	if _, err := s.src.Peek(n); err != nil && err != io.EOF {
+		s.err = err
+	}
+	s.buf, _ = s.src.Peek(s.src.Buffered())
+	return len(s.buf) >= n
+}
+
+// release consumes the bytes scanned from the bufio.Reader.
+func (s *xmlScanner) release() {
+	s.countLines()
+	s.src.Discard(s.pos)
+	s.base += int64(s.pos)
+	s.buf = s.buf[s.pos:]
+	s.pos, s.lines = 0, 0
+}
+
+// countLines counts the line feeds scanned since it was last called.
+func (s *xmlScanner) countLines() {
+	for s.lines < s.pos {
+		i := bytes.IndexByte(s.buf[s.lines:s.pos], '\n')
+		if i < 0 {
+			s.lines = s.pos
+			break
+		}
+		s.lines += i + 1
+		s.line++
+		s.lineStart = s.base + int64(s.lines)
+	}
+}
+
+// position returns the position of the next byte to be scanned.
+func (s *xmlScanner) position() xmlPosition {
+	s.countLines()
+	offset := s.offset()
+	return xmlPosition{offset, s.line + 1, int(offset-s.lineStart) + 1}
+}
+
+// eof returns the error for input which ends inside of a construct.
+func (s *xmlScanner) eof() error {
+	if s.err != nil {
+		return s.err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// peek returns the next byte without consuming it.
+func (s *xmlScanner) peek() (byte, bool) {
+	if s.pos == len(s.buf) && !s.ensure(1) {
+		return 0, false
+	}
+	return s.buf[s.pos], true
+}
+
+// readByte consumes and returns the next byte.
+func (s *xmlScanner) readByte() (byte, bool) {
+	if s.pos == len(s.buf) && !s.ensure(1) {
+		return 0, false
+	}
+	b := s.buf[s.pos]
+	s.pos++
+	return b, true
+}
+
+// skipPrefix consumes prefix if the input continues with it.
+func (s *xmlScanner) skipPrefix(prefix string) bool {
+	if !s.ensure(len(prefix)) || string(s.buf[s.pos:s.pos+len(prefix)]) != prefix {
+		return false
+	}
+	s.pos += len(prefix)
+	return true
+}
+
+// skipSpace consumes white space.
+func (s *xmlScanner) skipSpace() {
+	for {
+		b, ok := s.peek()
+		if !ok || !isXMLSpace(b) {
+			return
+		}
+		s.pos++
+	}
+}
+
+func isXMLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+// token returns the next token. At the end of the input it returns io.EOF,
+// or io.ErrUnexpectedEOF if elements are left open.
+func (s *xmlScanner) token() (xmlToken, error) {
+	if s.pendingEnd {
+		s.pendingEnd = false
+		s.tokenStart = s.position()
+		name := s.stack[len(s.stack)-1]
+		s.stack = s.stack[:len(s.stack)-1]
+		return xmlToken{kind: xmlEndElement, name: localName(name)}, nil
+	}
+	if !s.keepText {
+		s.text = s.text[:0]
+	}
+	for {
+		s.tokenStart = s.position()
+		b, ok := s.peek()
+		if !ok {
+			if s.err != nil {
+				return xmlToken{}, s.err
+			}
+			if len(s.stack) > 0 {
+				return xmlToken{}, io.ErrUnexpectedEOF
+			}
+			return xmlToken{}, io.EOF
+		}
+		if b != '<' {
+			var err error
+			if s.text, err = s.scanText(s.text, 0); err != nil {
+				return xmlToken{}, err
+			}
+			return xmlToken{kind: xmlCharData, text: s.text}, nil
+		}
+		s.pos++
+		b, ok = s.readByte()
+		if !ok {
+			return xmlToken{}, s.eof()
+		}
+		switch b {
+		case '/':
+			return s.scanEndElement()
+		case '?':
+			if err := s.scanProcInst(); err != nil {
+				return xmlToken{}, err
+			}
+		case '!':
+			text, err := s.scanMarkup()
+			if err != nil {
+				return xmlToken{}, err
+			}
+			if text {
+				return xmlToken{kind: xmlCharData, text: s.text}, nil
+			}
+		default:
+			s.pos--
+			return s.scanStartElement()
+		}
+	}
+}
+
+// skip reads tokens up to and including the end of the innermost open
+// element.
+func (s *xmlScanner) skip() error {
+	depth := len(s.stack)
+	for {
+		tok, err := s.token()
+		if err != nil {
+			return err
+		}
+		if tok.kind == xmlEndElement && len(s.stack) < depth {
+			return nil
+		}
+	}
+}
+
+// errTextLimit is returned by elementText when the text is longer than it
+// may be.
+var errTextLimit = errors.New("xml: text exceeds its limit")
+
+// elementText reads the rest of the element whose start was the last token
+// read, and returns its character data. Like encoding/xml's DecodeElement
+// into a string, it leaves out the text of child elements. The text is
+// valid until the next token is read.
+//
+// Reading stops with errTextLimit as soon as the text holds more than max
+// bytes, so that no more than about max bytes are buffered. If dropSpace is
+// set, whitespace is left out of the text, and doesn't count.
+func (s *xmlScanner) elementText(max int, dropSpace bool) ([]byte, error) {
+	s.text = s.text[:0]
+	s.keepText, s.maxText, s.dropSpace, s.dropped = true, max, dropSpace, false
+	defer func() { s.keepText, s.dropSpace = false, false }()
+	for {
+		tok, err := s.token()
+		if err != nil {
+			return nil, err
+		}
+		switch tok.kind {
+		case xmlEndElement:
+			return s.text, nil
+		case xmlStartElement:
+			n := len(s.text)
+			if err := s.skip(); err != nil {
+				return nil, err
+			}
+			s.text = s.text[:n]
+		}
+	}
+}
+
+// attr returns the value of the attribute name of the last start element.
+func (s *xmlScanner) attr(name string) string {
+	for _, attr := range s.attrs {
+		if localName(attr.name) == name {
+			return attr.value
+		}
+	}
+	return ""
+}
+
+// scanStartElement scans a start element after its <.
+func (s *xmlScanner) scanStartElement() (xmlToken, error) {
+	name, err := s.expectName("expected element name after <", true)
+	if err != nil {
+		return xmlToken{}, err
+	}
+	s.attrs = s.attrs[:0]
+	for {
+		s.skipSpace()
+		b, ok := s.readByte()
+		if !ok {
+			return xmlToken{}, s.eof()
+		}
+		if b == '/' {
+			if b, ok = s.readByte(); !ok {
+				return xmlToken{}, s.eof()
+			}
+			if b != '>' {
+				return xmlToken{}, s.syntaxError("expected /> in element")
+			}
+			s.pendingEnd = true
+			break
+		}
+		if b == '>' {
+			break
+		}
+		s.pos--
+		attr, err := s.scanAttr()
+		if err != nil {
+			return xmlToken{}, err
+		}
+		s.attrs = append(s.attrs, attr)
+	}
+	s.stack = append(s.stack, name)
+	return xmlToken{kind: xmlStartElement, name: localName(name)}, nil
+}
+
+// scanAttr scans an attribute of a start element.
+func (s *xmlScanner) scanAttr() (xmlAttr, error) {
+	name, err := s.expectName("expected attribute name in element", true)
+	if err != nil {
+		return xmlAttr{}, err
+	}
+	s.skipSpace()
+	b, ok := s.readByte()
+	if !ok {
+		return xmlAttr{}, s.eof()
+	}
+	if b != '=' {
+		return xmlAttr{}, s.syntaxError("attribute name without = in element")
+	}
+	s.skipSpace()
+	quote, ok := s.readByte()
+	if !ok {
+		return xmlAttr{}, s.eof()
+	}
+	if quote != '"' && quote != '\'' {
+		return xmlAttr{}, s.syntaxError("unquoted or missing attribute value in element")
+	}
+	value, err := s.scanText(nil, quote)
+	if err != nil {
+		return xmlAttr{}, err
+	}
+	if b, ok = s.readByte(); !ok {
+		return xmlAttr{}, s.eof()
+	}
+	if b == '<' {
+		return xmlAttr{}, s.syntaxError("unescaped < inside quoted string")
+	}
+	return xmlAttr{name, string(value)}, nil
+}
+
+// scanEndElement scans an end element after its </.
+func (s *xmlScanner) scanEndElement() (xmlToken, error) {
+	name, err := s.expectName("expected element name after </", true)
+	if err != nil {
+		return xmlToken{}, err
+	}
+	s.skipSpace()
+	b, ok := s.readByte()
+	if !ok {
+		return xmlToken{}, s.eof()
+	}
+	space, local := splitName(name)
+	if b != '>' {
+		return xmlToken{}, s.syntaxError("invalid characters between </%s and >", local)
+	}
+	if len(s.stack) == 0 {
+		return xmlToken{}, s.syntaxError("unexpected end element </%s>", local)
+	}
+	openSpace, openLocal := splitName(s.stack[len(s.stack)-1])
+	if openLocal != local {
+		return xmlToken{}, s.syntaxError("element <%s> closed by </%s>", openLocal, local)
+	}
+	if openSpace != space {
+		if space == "" {
+			space = `""`
+		}
+		return xmlToken{}, s.syntaxError("element <%s> in space %s closed by </%s> in space %s", openLocal, openSpace, local, space)
+	}
+	s.stack = s.stack[:len(s.stack)-1]
+	return xmlToken{kind: xmlEndElement, name: local}, nil
+}
+
+// scanProcInst scans a processing instruction after its <?. The
+// declaration <?xml ...?> must declare version 1.0 and UTF-8, if anything.
+func (s *xmlScanner) scanProcInst() error {
+	target, err := s.expectName("expected target name after <?", false)
+	if err != nil {
+		return err
+	}
+	var content []byte
+	for !s.skipPrefix("?>") {
+		b, ok := s.readByte()
+		if !ok {
+			return s.eof()
+		}
+		if target == "xml" {
+			content = append(content, b)
+		}
+	}
+	if target != "xml" {
+		return nil
+	}
+	// Like encoding/xml, report a bad declaration where it starts.
+	if version := procInstParam("version", string(content)); version != "" && version != "1.0" {
+		return &xmlSyntaxError{fmt.Sprintf("unsupported version %q; only version 1.0 is supported", version), s.tokenStart}
+	}
+	if encoding := procInstParam("encoding", string(content)); encoding != "" && !strings.EqualFold(encoding, "utf-8") {
+		return &xmlSyntaxError{fmt.Sprintf("unsupported encoding %q", encoding), s.tokenStart}
+	}
+	return nil
+}
+
+// procInstParam returns the value of the parameter param of the content of
+// a processing instruction, as in version="1.0". It is encoding/xml's
+// procInst.
+func procInstParam(param, content string) string {
+	param += "="
+	i := 0
+	var quote byte
+	for i < len(content) {
+		rest := content[i:]
+		k := strings.Index(rest, param)
+		if k < 0 || len(param)+k >= len(rest) {
+			return ""
+		}
+		i += len(param) + k + 1
+		if c := rest[len(param)+k]; c == '\'' || c == '"' {
+			quote = c
+			break
+		}
+	}
+	if quote == 0 {
+		return ""
+	}
+	j := strings.IndexByte(content[i:], quote)
+	if j < 0 {
+		return ""
+	}
+	return content[i : i+j]
+}
+
+// scanMarkup scans a comment, CDATA section or directive after its <!, and
+// reports whether it was a CDATA section, whose text it appends to text.
+func (s *xmlScanner) scanMarkup() (bool, error) {
+	b, ok := s.readByte()
+	if !ok {
+		return false, s.eof()
+	}
+	switch b {
+	case '-':
+		if b, ok = s.readByte(); !ok {
+			return false, s.eof()
+		}
+		if b != '-' {
+			return false, s.syntaxError("invalid sequence <!- not part of <!--")
+		}
+		return false, s.scanComment()
+	case '[':
+		for i := 0; i < len("CDATA["); i++ {
+			if b, ok = s.readByte(); !ok {
+				return false, s.eof()
+			}
+			if b != "CDATA["[i] {
+				return false, s.syntaxError("invalid <![ sequence")
+			}
+		}
+		var err error
+		s.text, err = s.scanCDATA(s.text)
+		return true, err
+	}
+	// The byte after <! is part of the directive, whatever it is.
+	return false, s.scanDirective()
+}
+
+// scanComment scans the rest of a comment after its <!--. Comments may not
+// hold "--".
+func (s *xmlScanner) scanComment() error {
+	for {
+		if !s.ensure(1) {
+			return s.eof()
+		}
+		i := bytes.IndexByte(s.buf[s.pos:], '-')
+		if i < 0 {
+			s.pos = len(s.buf)
+			continue
+		}
+		s.pos += i + 1
+		if !s.ensure(2) {
+			return s.eof()
+		}
+		if s.buf[s.pos] != '-' {
+			continue
+		}
+		if s.buf[s.pos+1] != '>' {
+			s.pos += 2
+			return s.syntaxError(`invalid sequence "--" not allowed in comments`)
+		}
+		s.pos += 2
+		return nil
+	}
+}
+
+// scanDirective scans the rest of a directive such as <!DOCTYPE ...> after
+// the byte following its <!. Like encoding/xml, it skips over quoted
+// strings, comments, and nested markup such as the declarations of an
+// internal DTD subset.
+func (s *xmlScanner) scanDirective() error {
+	var quote byte
+	depth := 0
+	for {
+		b, ok := s.readByte()
+		if !ok {
+			return s.eof()
+		}
+		switch {
+		case b == quote:
+			quote = 0
+		case quote != 0:
+		case b == '\'' || b == '"':
+			quote = b
+		case b == '>':
+			if depth == 0 {
+				return nil
+			}
+			depth--
+		case b == '<':
+			if !s.skipPrefix("!--") {
+				depth++
+				break
+			}
+			// Unlike other comments, those in directives may hold "--".
+			for !s.skipPrefix("-->") {
+				if _, ok := s.readByte(); !ok {
+					return s.eof()
+				}
+			}
+		}
+	}
+}
+
+// scanCDATA appends the text of a CDATA section after its <![CDATA[ to
+// text.
+func (s *xmlScanner) scanCDATA(text []byte) ([]byte, error) {
+	for {
+		if !s.ensure(1) {
+			if s.err != nil {
+				return text, s.err
+			}
+			return text, s.syntaxError("unexpected EOF in CDATA section")
+		}
+		if s.keepText && len(text) > s.maxText {
+			return text, errTextLimit
+		}
+		start := s.pos
+		i := start
+		for i < len(s.buf) && plainText[s.buf[i]] {
+			i++
+		}
+		text = s.appendText(text, s.buf[start:i])
+		s.pos = i
+		if i == len(s.buf) {
+			continue
+		}
+		if s.skipPrefix("]]>") {
+			return text, nil
+		}
+		var err error
+		if text, err = s.scanSpecial(text); err != nil {
+			return text, err
+		}
+	}
+}
+
+// plainText holds the bytes which text holds as they are.
+var plainText [256]bool
+
+func init() {
+	for b := ' '; b < utf8.RuneSelf; b++ {
+		plainText[b] = b != '<' && b != '&' && b != ']'
+	}
+	plainText['\t'] = true
+	plainText['\n'] = true
+}
+
+// scanText appends the text up to the next < to text, with its entities
+// replaced. The text of attribute values also ends at their quote.
+func (s *xmlScanner) scanText(text []byte, quote byte) ([]byte, error) {
+	for {
+		if !s.ensure(1) {
+			return text, s.err
+		}
+		if quote == 0 && s.keepText && len(text) > s.maxText {
+			return text, errTextLimit
+		}
+		start := s.pos
+		i := start
+		for i < len(s.buf) && plainText[s.buf[i]] && s.buf[i] != quote {
+			i++
+		}
+		if quote == 0 {
+			text = s.appendText(text, s.buf[start:i])
+		} else {
+			text = append(text, s.buf[start:i]...)
+		}
+		s.pos = i
+		if i == len(s.buf) {
+			continue
+		}
+		b := s.buf[i]
+		if b == '<' || b == quote && quote != 0 {
+			return text, nil
+		}
+		var err error
+		switch {
+		case b == '&':
+			text, err = s.scanEntity(text)
+		case b == ']' && quote == 0 && s.skipPrefix("]]>"):
+			err = s.syntaxError("unescaped ]]> not in CDATA section")
+		default:
+			text, err = s.scanSpecial(text)
+		}
+		if err != nil {
+			return text, err
+		}
+	}
+}
+
+// appendText appends the plain text b to text, without its whitespace if
+// elementText is leaving it out.
+func (s *xmlScanner) appendText(text, b []byte) []byte {
+	if !s.dropSpace {
+		return append(text, b...)
+	}
+	for _, c := range b {
+		if !isXMLSpace(c) {
+			text = append(text, c)
+		} else {
+			s.dropped = true
+		}
+	}
+	return text
+}
+
+// scanSpecial appends the next character of text, which isn't plain, to
+// text. Carriage returns are turned into line feeds, as are carriage return
+// and line feed pairs.
+func (s *xmlScanner) scanSpecial(text []byte) ([]byte, error) {
+	if b := s.buf[s.pos]; b < utf8.RuneSelf {
+		s.pos++
+		if b == '\r' {
+			if next, ok := s.peek(); ok && next == '\n' {
+				s.pos++
+			}
+			return append(text, '\n'), nil
+		}
+		if !isInCharacterRange(rune(b)) {
+			return text, s.syntaxError("illegal character code %U", rune(b))
+		}
+		return append(text, b), nil
+	}
+	s.ensure(utf8.UTFMax)
+	r, size := utf8.DecodeRune(s.buf[s.pos:])
+	if r == utf8.RuneError && size == 1 {
+		return text, s.syntaxError("invalid UTF-8")
+	}
+	s.pos += size
+	if !isInCharacterRange(r) {
+		return text, s.syntaxError("illegal character code %U", r)
+	}
+	return append(text, s.buf[s.pos-size:s.pos]...), nil
+}
+
+// scanEntity appends the character referred to by the entity at pos, as in
+// &amp; or &#38;, to text.
+func (s *xmlScanner) scanEntity(text []byte) ([]byte, error) {
+	s.pos++ // &
+	b, ok := s.readByte()
+	if !ok {
+		return text, s.eof()
+	}
+	var entity string
+	if b == '#' {
+		s.name = append(s.name[:0], '#')
+		if b, ok = s.readByte(); !ok {
+			return text, s.eof()
+		}
+		base := 10
+		if b == 'x' {
+			base = 16
+			s.name = append(s.name, 'x')
+			if b, ok = s.readByte(); !ok {
+				return text, s.eof()
+			}
+		}
+		digits := len(s.name)
+		for '0' <= b && b <= '9' || base == 16 && ('a' <= b && b <= 'f' || 'A' <= b && b <= 'F') {
+			s.name = append(s.name, b)
+			if b, ok = s.readByte(); !ok {
+				return text, s.eof()
+			}
+		}
+		entity = string(s.name)
+		if b == ';' {
+			n, err := strconv.ParseUint(entity[digits:], base, 64)
+			if err == nil && n <= unicode.MaxRune {
+				// Surrogates turn into U+FFFD, as they did in encoding/xml.
+				r, _ := utf8.DecodeRuneInString(string(rune(n)))
+				if !isInCharacterRange(r) {
+					return text, s.syntaxError("illegal character code %U", r)
+				}
+				return append(text, string(r)...), nil
+			}
+		}
+	} else {
+		s.pos--
+		entity = s.readName()
+		if b, ok = s.readByte(); !ok {
+			return text, s.eof()
+		}
+		if b == ';' {
+			switch entity {
+			case "lt":
+				return append(text, '<'), nil
+			case "gt":
+				return append(text, '>'), nil
+			case "amp":
+				return append(text, '&'), nil
+			case "apos":
+				return append(text, '\''), nil
+			case "quot":
+				return append(text, '"'), nil
+			}
+		}
+	}
+	if b != ';' {
+		s.pos--
+		return text, s.syntaxError("invalid character entity &%s (no semicolon)", entity)
+	}
+	return text, s.syntaxError("invalid character entity &%s;", entity)
+}
+
+// expectName scans a name, and returns an error with msg if there is none.
+// Names of elements and attributes may hold a single colon, after their
+// namespace prefix.
+func (s *xmlScanner) expectName(msg string, prefixed bool) (string, error) {
+	name, err := s.scanName()
+	if err != nil {
+		return "", err
+	}
+	if prefixed && strings.Count(name, ":") > 1 {
+		return "", s.syntaxError("%s", msg)
+	}
+	if name == "" {
+		if _, ok := s.peek(); !ok {
+			return "", s.eof()
+		}
+		return "", s.syntaxError("%s", msg)
+	}
+	return name, nil
+}
+
+// scanName scans a name, which is empty if there is none.
+func (s *xmlScanner) scanName() (string, error) {
+	name := s.readName()
+	if _, ok := s.peek(); !ok {
+		// Names are followed by something, at least by >.
+		return "", s.eof()
+	}
+	if name != "" && !isXMLName(name) {
+		return "", s.syntaxError("invalid XML name: %s", name)
+	}
+	return name, nil
+}
+
+// readName reads the bytes which may be part of a name, which the caller
+// must check, as encoding/xml does.
+func (s *xmlScanner) readName() string {
+	start := s.pos
+	i := start
+	for i < len(s.buf) && isNameByte(s.buf[i]) {
+		i++
+	}
+	if i < len(s.buf) {
+		s.pos = i
+		return internName(s.buf[start:i])
+	}
+	// The name may go on past the buffered input.
+	s.name = append(s.name[:0], s.buf[start:i]...)
+	s.pos = i
+	for {
+		b, ok := s.peek()
+		if !ok || !isNameByte(b) {
+			return string(s.name)
+		}
+		s.name = append(s.name, b)
+		s.pos++
+	}
+}
+
+// isNameByte reports whether b may be part of a name. Bytes of multi-byte
+// characters are checked by isXMLName.
+func isNameByte(b byte) bool {
+	return 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' ||
+		'0' <= b && b <= '9' || b == '_' || b == ':' || b == '.' || b == '-' ||
+		b >= utf8.RuneSelf
+}
+
+// isXMLName reports whether name, made of name bytes, is a valid name. Names
+// start with a letter, _ or :, and go on with those, digits, marks, . and -.
+func isXMLName(name string) bool {
+	ascii := true
+	for i := 0; i < len(name); i++ {
+		ascii = ascii && name[i] < utf8.RuneSelf
+	}
+	if ascii {
+		b := name[0]
+		return !('0' <= b && b <= '9' || b == '.' || b == '-')
+	}
+	for i, r := range name {
+		switch {
+		case r == utf8.RuneError:
+			return false
+		case r < utf8.RuneSelf:
+			if i == 0 && ('0' <= r && r <= '9' || r == '.' || r == '-') {
+				return false
+			}
+		case unicode.IsLetter(r):
+		case i == 0:
+			return false
+		case !unicode.IsDigit(r) && !unicode.IsMark(r) && r != '·':
+			return false
+		}
+	}
+	return true
+}
+
+// internName returns name as a string, without allocating for the names of
+// plist elements.
+func internName(name []byte) string {
+	switch string(name) {
+	case "plist":
+		return "plist"
+	case "dict":
+		return "dict"
+	case "key":
+		return "key"
+	case "string":
+		return "string"
+	case "array":
+		return "array"
+	case "integer":
+		return "integer"
+	case "real":
+		return "real"
+	case "true":
+		return "true"
+	case "false":
+		return "false"
+	case "data":
+		return "data"
+	case "date":
+		return "date"
+	case "":
+		return ""
+	}
+	return string(name)
+}
+
+// splitName splits name into its namespace prefix and local name, as
+// encoding/xml does.
+func splitName(name string) (space, local string) {
+	i := strings.IndexByte(name, ':')
+	if i < 1 || i == len(name)-1 {
+		return "", name
+	}
+	return name[:i], name[i+1:]
+}
+
+// localName returns name without its namespace prefix.
+func localName(name string) string {
+	_, local := splitName(name)
+	return local
+}
@@ -1,237 +1,274 @@
 package plist
 
 import (
+	"bufio"
 	"encoding/base64"
 	"encoding/xml"
-	"fmt"
 	"io"
 	"math"
-	"reflect"
+	"strconv"
 	"time"
+	"unicode/utf8"
 )
 
 const xmlDOCTYPE = `<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">`
 
+// xmlEncoder writes a plistValue tree as an XML plist. It does its own
+// escaping and indentation, which match those of encoding/xml's Encoder,
+// which it used to write with, so that the output stays the same.
 type xmlEncoder struct {
-	writer io.Writer
-	*xml.Encoder
+	writer   *bufio.Writer
+	indent   string
+	keyOrder KeyOrder
+	scratch  []byte // for formatting numbers and data
+
+	// Indentation state, as in encoding/xml.
+	depth      int
+	indentedIn bool // whether the last tag written was a start tag
+	putNewline bool // whether the next indented tag starts a new line
 }
 
 func newXMLEncoder(w io.Writer) *xmlEncoder {
-	return &xmlEncoder{w, xml.NewEncoder(w)}
+	return &xmlEncoder{writer: bufio.NewWriter(w)}
+}
+
+func (e *xmlEncoder) Indent(indent string) {
+	e.indent = indent
 }
 
 func (e *xmlEncoder) generateDocument(pval *plistValue) error {
-	// xml version=1.0
-	_, err := e.writer.Write([]byte(xml.Header))
-	if err != nil {
+	e.writeHeader()
+	e.writeStart("plist")
+	if err := e.writePlistValue(pval); err != nil {
 		return err
 	}
+	e.writeEnd("plist")
+	e.writeFooter()
+	return e.writer.Flush()
+}
+
+// writeHeader writes the XML declaration and doctype of a plist document.
+func (e *xmlEncoder) writeHeader() {
+	// xml version=1.0
+	e.writer.WriteString(xml.Header)
 
 	//!DOCTYPE plist
-	_, err = e.writer.Write([]byte(xmlDOCTYPE))
-	if err != nil {
-		return err
-	}
+	e.writer.WriteString(xmlDOCTYPE)
 
 	// newline after doctype
 	// <plist> tag starts on new line
-	_, err = e.writer.Write([]byte("\n"))
-	if err != nil {
-		return err
-	}
+	e.writer.WriteByte('\n')
+}
 
-	tokenFunc := func(pval *plistValue) error {
-		if err := e.writePlistValue(pval); err != nil {
-			return err
-		}
-		return nil
-	}
-	err = e.writeElement("plist", pval, tokenFunc)
-	if err != nil {
-		return err
-	}
+// writeFooter ends a plist document after its </plist> tag.
+func (e *xmlEncoder) writeFooter() {
 	// newline at the end of a plist document
-	_, err = e.writer.Write([]byte("\n"))
-	if err != nil {
-		return err
-	}
-	return nil
+	e.writer.WriteByte('\n')
 }
 
 func (e *xmlEncoder) writePlistValue(pval *plistValue) error {
 	switch pval.kind {
 	case String:
-		return e.writeStringValue(pval)
+		// Strings are written like xml.CharData, which doesn't escape
+		// newlines. See https://github.com/golang/go/issues/9204
+		e.writeStart("string")
+		e.writeEscaped(pval.value.(string), false)
+		e.writeEnd("string")
 	case Boolean:
-		return e.writeBoolValue(pval)
+		// Booleans are self closing tags, which were always written
+		// without indentation.
+		if pval.value.(bool) {
+			e.writer.WriteString("<true/>")
+		} else {
+			e.writer.WriteString("<false/>")
+		}
 	case Integer:
-		return e.writeIntegerValue(pval)
+		if pval.value.(signedInt).signed {
+			e.scratch = strconv.AppendInt(e.scratch[:0], int64(pval.value.(signedInt).value), 10)
+		} else {
+			e.scratch = strconv.AppendUint(e.scratch[:0], pval.value.(signedInt).value, 10)
+		}
+		e.writeScalar("integer", e.scratch)
 	case Dictionary:
-		return e.writeDictionaryValue(pval)
+		return e.writeDictionary(pval.value.(*dictionary))
 	case Date:
-		return e.writeDateValue(pval)
-	case Array:
-		return e.writeArrayValue(pval)
+		e.scratch = pval.value.(time.Time).In(time.UTC).AppendFormat(e.scratch[:0], time.RFC3339)
+		e.writeScalar("date", e.scratch)
+	case Array, SetKind, OrderedSetKind:
+		// XML plists have no sets.
+		return e.writeArray(pval.value.([]*plistValue))
+	case UIDKind:
+		return e.writeDictionary(uidDictionary(pval.value.(UID)).value.(*dictionary))
+	case NullKind:
+		return &UnsupportedValueError{Str: "null"}
 	case Real:
-		return e.writeRealValue(pval)
+		switch f := pval.value.(sizedFloat).value; {
+		case math.IsInf(f, 1):
+			e.scratch = append(e.scratch[:0], "inf"...)
+		case math.IsInf(f, -1):
+			e.scratch = append(e.scratch[:0], "-inf"...)
+		case math.IsNaN(f):
+			e.scratch = append(e.scratch[:0], "nan"...)
+		default:
+			e.scratch = strconv.AppendFloat(e.scratch[:0], f, 'g', -1, 64)
+		}
+		e.writeScalar("real", e.scratch)
 	case Data:
-		return e.writeDataValue(pval)
+		data := pval.value.([]byte)
+		n := base64.StdEncoding.EncodedLen(len(data))
+		if cap(e.scratch) < n {
+			e.scratch = make([]byte, n)
+		}
+		e.scratch = e.scratch[:n]
+		base64.StdEncoding.Encode(e.scratch, data)
+		e.writeScalar("data", e.scratch)
 	default:
-		return &UnsupportedTypeError{reflect.ValueOf(pval.value).Type()}
+		return kindError(pval)
 	}
+	return nil
 }
 
-func (e *xmlEncoder) writeDataValue(pval *plistValue) error {
-	encodedValue := base64.StdEncoding.EncodeToString(pval.value.([]byte))
-	return e.EncodeElement(encodedValue, xml.StartElement{Name: xml.Name{Local: "data"}})
-}
-
-func (e *xmlEncoder) writeRealValue(pval *plistValue) error {
-	encodedValue := pval.value
-	switch {
-	case math.IsInf(pval.value.(sizedFloat).value, 1):
-		encodedValue = "inf"
-	case math.IsInf(pval.value.(sizedFloat).value, -1):
-		encodedValue = "-inf"
-	case math.IsNaN(pval.value.(sizedFloat).value):
-		encodedValue = "nan"
-	default:
-		encodedValue = pval.value.(sizedFloat).value
-	}
-	return e.EncodeElement(encodedValue, xml.StartElement{Name: xml.Name{Local: "real"}})
+// writeScalar writes an element holding text, which needs no escaping.
+func (e *xmlEncoder) writeScalar(name string, text []byte) {
+	e.writeStart(name)
+	e.writer.Write(text)
+	e.writeEnd(name)
 }
 
-// writeElement writes an xml element like <plist>, <array> or <dict>
-func (e *xmlEncoder) writeElement(name string, pval *plistValue, valFunc func(*plistValue) error) error {
-	startElement := xml.StartElement{
-		Name: xml.Name{
-			Space: "",
-			Local: name,
-		}}
-
-	if name == "plist" {
-		startElement.Attr = []xml.Attr{{
-			Name: xml.Name{
-				Space: "",
-				Local: "version"},
-			Value: "1.0"},
+func (e *xmlEncoder) writeArray(values []*plistValue) error {
+	e.writeStart("array")
+	for i, v := range values {
+		if err := e.writePlistValue(v); err != nil {
+			return prependPath(err, indexPath(i))
 		}
 	}
-
-	// Encode xml.StartElement token
-	if err := e.EncodeToken(startElement); err != nil {
-		return err
-	}
-
-	// flush
-	if err := e.Flush(); err != nil {
-		return err
-	}
-
-	// execute valFunc()
-	if err := valFunc(pval); err != nil {
-		return err
-	}
-
-	// Encode xml.EndElement token
-	if err := e.EncodeToken(startElement.End()); err != nil {
-		return err
-	}
-
-	// flush
-	return e.Flush()
+	e.writeEnd("array")
+	return nil
 }
 
-func (e *xmlEncoder) writeArrayValue(pval *plistValue) error {
-	tokenFunc := func(pval *plistValue) error {
-		encodedValue := pval.value
-		values := encodedValue.([]*plistValue)
-		for _, v := range values {
-			if err := e.writePlistValue(v); err != nil {
-				return err
-			}
+func (e *xmlEncoder) writeDictionary(dict *dictionary) error {
+	e.writeStart("dict")
+	dict.populateArrays(e.keyOrder)
+	for i, k := range dict.keys {
+		// XML plists have no null, so leave out null values.
+		if dict.values[i].kind == NullKind {
+			continue
+		}
+		e.writeKey(k)
+		if err := e.writePlistValue(dict.values[i]); err != nil {
+			return prependPath(err, k)
 		}
-		return nil
 	}
-	return e.writeElement("array", pval, tokenFunc)
+	e.writeEnd("dict")
+	return nil
+}
 
+// writeKey writes a dictionary key. Unlike strings, keys have their newlines
+// escaped.
+func (e *xmlEncoder) writeKey(k string) {
+	e.writeStart("key")
+	e.writeEscaped(k, true)
+	e.writeEnd("key")
 }
 
-func (e *xmlEncoder) writeDictionaryValue(pval *plistValue) error {
-	tokenFunc := func(pval *plistValue) error {
-		encodedValue := pval.value
-		dict := encodedValue.(*dictionary)
-		dict.populateArrays()
-		for i, k := range dict.keys {
-			if err := e.EncodeElement(k, xml.StartElement{Name: xml.Name{Local: "key"}}); err != nil {
-				return err
-			}
-			if err := e.writePlistValue(dict.values[i]); err != nil {
-				return err
-			}
-		}
-		return nil
+// writeStart writes the start tag of an element.
+func (e *xmlEncoder) writeStart(name string) {
+	e.writeIndent(1)
+	e.writer.WriteByte('<')
+	e.writer.WriteString(name)
+	if name == "plist" {
+		e.writer.WriteString(` version="1.0"`)
 	}
-	return e.writeElement("dict", pval, tokenFunc)
+	e.writer.WriteByte('>')
 }
 
-// encode strings as CharData, which doesn't escape newline
-// see https://github.com/golang/go/issues/9204
-func (e *xmlEncoder) writeStringValue(pval *plistValue) error {
-	startElement := xml.StartElement{Name: xml.Name{Local: "string"}}
-	// Encode xml.StartElement token
-	if err := e.EncodeToken(startElement); err != nil {
-		return err
-	}
+// writeEnd writes the end tag of an element.
+func (e *xmlEncoder) writeEnd(name string) {
+	e.writeIndent(-1)
+	e.writer.WriteString("</")
+	e.writer.WriteString(name)
+	e.writer.WriteByte('>')
+}
 
-	// flush
-	if err := e.Flush(); err != nil {
-		return err
+// writeIndent starts a tag on a new line, indented by its depth, if the
+// encoder indents. The end tags of elements without child elements stay on
+// the line of their start tag.
+func (e *xmlEncoder) writeIndent(depthDelta int) {
+	if e.indent == "" {
+		return
 	}
-
-	stringValue := pval.value.(string)
-	if err := e.EncodeToken(xml.CharData(stringValue)); err != nil {
-		return err
+	if depthDelta < 0 {
+		e.depth--
+		if e.indentedIn {
+			e.indentedIn = false
+			return
+		}
 	}
-
-	// flush
-	if err := e.Flush(); err != nil {
-		return err
+	if e.putNewline {
+		e.writer.WriteByte('\n')
+	} else {
+		e.putNewline = true
 	}
-
-	// Encode xml.EndElement token
-	if err := e.EncodeToken(startElement.End()); err != nil {
-		return err
+	for i := 0; i < e.depth; i++ {
+		e.writer.WriteString(e.indent)
 	}
-
-	// flush
-	return e.Flush()
-
-}
-
-func (e *xmlEncoder) writeBoolValue(pval *plistValue) error {
-	// EncodeElement results in <true></true> instead of <true/>
-	// use writer to write self closing tags
-	b := pval.value.(bool)
-	_, err := e.writer.Write([]byte(fmt.Sprintf("<%t/>", b)))
-	if err != nil {
-		return err
+	if depthDelta > 0 {
+		e.depth++
+		e.indentedIn = true
 	}
-	return nil
 }
 
-func (e *xmlEncoder) writeIntegerValue(pval *plistValue) error {
-	encodedValue := pval.value
-	if pval.value.(signedInt).signed {
-		encodedValue = int64(pval.value.(signedInt).value)
-	} else {
-		encodedValue = pval.value.(signedInt).value
+// writeEscaped writes s escaped like xml.EscapeText, which escapes newlines
+// only if escapeNewline is set.
+func (e *xmlEncoder) writeEscaped(s string, escapeNewline bool) {
+	last := 0
+	for i := 0; i < len(s); {
+		r, width := rune(s[i]), 1
+		if r >= utf8.RuneSelf {
+			r, width = utf8.DecodeRuneInString(s[i:])
+		}
+		i += width
+		var esc string
+		switch r {
+		case '"':
+			esc = "&#34;"
+		case '\'':
+			esc = "&#39;"
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			esc = "&gt;"
+		case '\t':
+			esc = "&#x9;"
+		case '\n':
+			if !escapeNewline {
+				continue
+			}
+			esc = "&#xA;"
+		case '\r':
+			esc = "&#xD;"
+		default:
+			if !isInCharacterRange(r) || (r == utf8.RuneError && width == 1) {
+				esc = "\uFFFD"
+				break
+			}
+			continue
+		}
+		e.writer.WriteString(s[last : i-width])
+		e.writer.WriteString(esc)
+		last = i
 	}
-	return e.EncodeElement(encodedValue, xml.StartElement{Name: xml.Name{Local: "integer"}})
+	e.writer.WriteString(s[last:])
 }
 
-func (e *xmlEncoder) writeDateValue(pval *plistValue) error {
-	encodedValue := pval.value.(time.Time).In(time.UTC).Format(time.RFC3339)
-	return e.EncodeElement(encodedValue, xml.StartElement{Name: xml.Name{Local: "date"}})
+// isInCharacterRange reports whether r may appear in an XML document.
+func isInCharacterRange(r rune) bool {
+	return r == 0x09 ||
+		r == 0x0A ||
+		r == 0x0D ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
 }